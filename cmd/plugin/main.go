@@ -6,8 +6,9 @@ package main
 
 import (
 	"context"
-	"fmt"
 	"os"
+	"os/signal"
+	"syscall"
 
 	"github.com/cultureamp/terraform-buildkite-plugin/internal/common"
 	"github.com/cultureamp/terraform-buildkite-plugin/internal/plugin"
@@ -31,8 +32,10 @@ var (
 // main is the entry point for the plugin.
 //
 // It sets up logging, loads configuration, handles test mode, and runs the plugin.
+// SIGTERM and SIGINT, sent when Buildkite cancels the job, cancel the context so
+// that running terraform processes are interrupted and release their state locks.
 func main() {
-	ctx := context.Background()
+	ctx, stop := signal.NotifyContext(context.Background(), syscall.SIGTERM, os.Interrupt)
 
 	pluginContext := &plugin.Context{
 		Name:    name,
@@ -51,6 +54,7 @@ func main() {
 	handler := plugin.NewHandler()
 
 	result, err := handler.Handle(ctx, pluginContext)
+	stop()
 	if err != nil {
 		group.OpenCurrent()
 		log.Fatal().Err(err).Msg("Failed to handle plugin execution")
@@ -64,15 +68,10 @@ func main() {
 // It configures the logger for coloured output, omits timestamps, and attaches the context.
 func configureLogger(ctx context.Context) {
 	//nolint:reassign // overriding the global logger for convenience
-	log.Logger = log.Output(
-		zerolog.ConsoleWriter{
-			Out:             os.Stdout,
-			NoColor:         false,
-			PartsExclude:    []string{"time"},
-			FormatFieldName: func(i any) string { return fmt.Sprintf("%s:", i) },
-		},
-	).With().Ctx(ctx).Logger()
+	log.Logger = log.Output(common.NewConsoleWriter(os.Stdout)).With().Ctx(ctx).Logger()
 	// We create the logger first and set the log level afterwards so that any logs caused by `ParseLogLevel` are properly formatted
 	//nolint:reassign // overriding the global logger for convenience
 	log.Logger = log.Logger.Level((common.ParseLogLevel("LOG_LEVEL", zerolog.DebugLevel)))
+	// Contexts without a workspace logger attached fall back to the global logger
+	zerolog.DefaultContextLogger = &log.Logger
 }
@@ -0,0 +1,20 @@
+// Package artifacts provides adapters for sharing Terraform plans between
+// pipeline steps as Buildkite artifacts.
+package artifacts
+
+// PlanArtifacts configures uploading plans as artifacts in plan mode and
+// applying exactly those plans in apply mode.
+//
+// When configured, plan mode uploads the binary plan, its JSON representation
+// and a manifest with checksums for every working directory. Apply mode then
+// downloads the plan for each working directory, verifies it and applies it
+// instead of planning again.
+type PlanArtifacts struct {
+	// Directory is the relative directory plans are staged in before upload.
+	// Each working directory is stored in a subdirectory at its path relative to the checkout.
+	Directory string `json:"directory,omitempty" jsonschema:"title=directory,description=Relative directory plans are staged in before upload (defaults to terraform-plans)"`
+
+	// Step is the key or ID of the step that uploaded the plans.
+	// This is only used in apply mode to scope the artifact download.
+	Step string `json:"step,omitempty" jsonschema:"title=step,description=Key or ID of the step that uploaded the plans, used when downloading in apply mode"`
+}
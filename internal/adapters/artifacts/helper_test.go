@@ -0,0 +1,57 @@
+package artifacts_test
+
+import (
+	"context"
+	"fmt"
+	"testing"
+
+	"github.com/cultureamp/terraform-buildkite-plugin/pkg/buildkite/agent"
+	"github.com/rs/zerolog"
+	"github.com/rs/zerolog/log"
+)
+
+func TestMain(m *testing.M) {
+	//nolint:reassign // sinencing the global logger to avoid output during tests
+	log.Logger = zerolog.New(nil)
+	m.Run()
+}
+
+// localAgent is an agent whose artifact storage is the local filesystem,
+// so uploaded files are already in place when they are downloaded.
+type localAgent struct {
+	agent.Agent
+	uploads   []string
+	downloads []string
+	metaData  map[string]string
+}
+
+func (l *localAgent) SetMetaData(_ context.Context, key string, value string) error {
+	if l.metaData == nil {
+		l.metaData = map[string]string{}
+	}
+	l.metaData[key] = value
+	return nil
+}
+
+func (l *localAgent) GetMetaData(_ context.Context, key string) (string, error) {
+	value, ok := l.metaData[key]
+	if !ok {
+		return "", fmt.Errorf("meta-data key %q not found", key)
+	}
+	return value, nil
+}
+
+func (l *localAgent) UploadArtifacts(_ context.Context, paths string) (*string, error) {
+	l.uploads = append(l.uploads, paths)
+	return nil, nil
+}
+
+func (l *localAgent) DownloadArtifacts(
+	_ context.Context,
+	query string,
+	_ string,
+	_ ...agent.ArtifactOptions,
+) (*string, error) {
+	l.downloads = append(l.downloads, query)
+	return nil, nil
+}
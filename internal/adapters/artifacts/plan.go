@@ -0,0 +1,275 @@
+package artifacts
+
+import (
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"time"
+
+	"github.com/cultureamp/terraform-buildkite-plugin/internal/common"
+	"github.com/cultureamp/terraform-buildkite-plugin/pkg/buildkite/agent"
+	tfjson "github.com/hashicorp/terraform-json"
+	"github.com/rs/zerolog/log"
+)
+
+const (
+	defaultDirectory = "terraform-plans"
+	planBinaryName   = "plan.binary"
+	planJSONName     = "plan.json"
+	manifestName     = "manifest.json"
+	// metaDataPrefix prefixes the build meta-data key holding the checksum of each plan's manifest.
+	metaDataPrefix = "terraform-plan:"
+)
+
+// ErrPlanNotFound is returned when no saved plan exists for a working directory.
+var ErrPlanNotFound = errors.New("saved plan not found")
+
+// Manifest describes a saved plan and is uploaded alongside it.
+type Manifest struct {
+	// Workspace is the base name of the working directory the plan was created in.
+	Workspace string `json:"workspace"`
+	// WorkingDir is the path of the working directory relative to the checkout.
+	WorkingDir string `json:"working_dir"`
+	// TerraformVersion is the version of Terraform that created the plan.
+	TerraformVersion string `json:"terraform_version"`
+	// Commit is the commit the plan was created from, from BUILDKITE_COMMIT.
+	Commit string `json:"commit,omitempty"`
+	// HasChanges reports whether the plan contains changes.
+	HasChanges bool `json:"has_changes"`
+	// PlanChecksum is the SHA-256 checksum of the binary plan.
+	PlanChecksum string `json:"plan_sha256"`
+	// PlanJSONChecksum is the SHA-256 checksum of the JSON plan, when the plan has changes.
+	PlanJSONChecksum string `json:"plan_json_sha256,omitempty"`
+	// CreatedAt is when the plan was saved.
+	CreatedAt time.Time `json:"created_at"`
+}
+
+// SavedPlan is a verified plan retrieved from artifacts.
+type SavedPlan struct {
+	// Manifest describes the saved plan.
+	Manifest Manifest
+	// PlanFile is the absolute path of the downloaded binary plan.
+	PlanFile string
+}
+
+// PlanStore saves and retrieves plans keyed by working directory.
+type PlanStore interface {
+	// Upload saves the plan for a working directory. Plan is nil when there are no changes.
+	Upload(ctx context.Context, workingDir, planFile string, plan *tfjson.Plan, terraformVersion string) error
+	// Download retrieves and verifies the plan for a working directory.
+	Download(ctx context.Context, workingDir string) (*SavedPlan, error)
+}
+
+type planStoreConfig struct {
+	agent  agent.Agent
+	config *PlanArtifacts
+	now    func() time.Time
+}
+
+// PlanStoreOptions allows functional options for customizing the plan store.
+type PlanStoreOptions func(*planStoreConfig)
+
+// WithAgent allows injecting a custom Buildkite agent.
+func WithAgent(a agent.Agent) PlanStoreOptions {
+	return func(p *planStoreConfig) {
+		if a != nil {
+			p.agent = a
+		}
+	}
+}
+
+// NewPlanStore creates a plan store backed by Buildkite artifacts.
+func NewPlanStore(config *PlanArtifacts, opts ...PlanStoreOptions) PlanStore {
+	if config == nil {
+		config = &PlanArtifacts{}
+	}
+	store := &planStoreConfig{
+		agent:  agent.NewAgent(),
+		config: config,
+		now:    time.Now,
+	}
+	for _, opt := range opts {
+		opt(store)
+	}
+	return store
+}
+
+// dir returns the staging directory for a working directory key.
+func (p *planStoreConfig) dir(key string) string {
+	base := p.config.Directory
+	if base == "" {
+		base = defaultDirectory
+	}
+	return filepath.Join(base, filepath.FromSlash(key))
+}
+
+// Upload stages the plan, its JSON and a manifest, then uploads them as artifacts.
+func (p *planStoreConfig) Upload(
+	ctx context.Context,
+	workingDir string,
+	planFile string,
+	plan *tfjson.Plan,
+	terraformVersion string,
+) error {
+	key, err := common.CheckoutRelativePath(workingDir)
+	if err != nil {
+		return err
+	}
+	dir := p.dir(key)
+	log.Ctx(ctx).Debug().Str("working_dir", workingDir).Str("artifact_dir", dir).Msg("staging plan artifacts")
+	if err = os.MkdirAll(dir, 0o755); err != nil {
+		return fmt.Errorf("failed to create plan artifact directory: %w", err)
+	}
+	manifest := Manifest{
+		Workspace:        filepath.Base(workingDir),
+		WorkingDir:       key,
+		TerraformVersion: terraformVersion,
+		Commit:           common.FetchEnv("BUILDKITE_COMMIT", ""),
+		HasChanges:       plan != nil,
+		CreatedAt:        p.now().UTC(),
+	}
+	checksum, err := copyWithChecksum(planFile, filepath.Join(dir, planBinaryName))
+	if err != nil {
+		return fmt.Errorf("failed to stage binary plan: %w", err)
+	}
+	manifest.PlanChecksum = checksum
+	if plan != nil {
+		var planJSON []byte
+		planJSON, err = json.Marshal(plan)
+		if err != nil {
+			return fmt.Errorf("failed to marshal plan JSON: %w", err)
+		}
+		if err = os.WriteFile(filepath.Join(dir, planJSONName), planJSON, 0o600); err != nil {
+			return fmt.Errorf("failed to stage plan JSON: %w", err)
+		}
+		manifest.PlanJSONChecksum = checksumBytes(planJSON)
+	}
+	manifestJSON, err := json.MarshalIndent(manifest, "", "  ")
+	if err != nil {
+		return fmt.Errorf("failed to marshal plan manifest: %w", err)
+	}
+	if err = os.WriteFile(filepath.Join(dir, manifestName), manifestJSON, 0o600); err != nil {
+		return fmt.Errorf("failed to stage plan manifest: %w", err)
+	}
+	if _, err = p.agent.UploadArtifacts(ctx, filepath.Join(dir, "*")); err != nil {
+		return fmt.Errorf("failed to upload plan artifacts: %w", err)
+	}
+	// The manifest checksum is kept outside the artifact store, so replacing
+	// the artifacts cannot also replace what they are verified against.
+	if err = p.agent.SetMetaData(ctx, metaDataPrefix+key, checksumBytes(manifestJSON)); err != nil {
+		return fmt.Errorf("failed to record plan checksum: %w", err)
+	}
+	log.Ctx(ctx).Info().
+		Str("working_dir", workingDir).
+		Str("artifact_dir", dir).
+		Str("plan_sha256", manifest.PlanChecksum).
+		Msg("uploaded plan artifacts")
+	return nil
+}
+
+// Download retrieves the plan artifacts for a working directory, verifies the
+// manifest against the checksum recorded in build meta-data, then verifies the
+// plan's checksums, working directory and commit against the manifest.
+func (p *planStoreConfig) Download(ctx context.Context, workingDir string) (*SavedPlan, error) {
+	key, err := common.CheckoutRelativePath(workingDir)
+	if err != nil {
+		return nil, err
+	}
+	dir := p.dir(key)
+	var opts []agent.ArtifactOptions
+	if p.config.Step != "" {
+		opts = append(opts, agent.WithStep(p.config.Step))
+	}
+	log.Ctx(ctx).Debug().Str("working_dir", workingDir).Str("artifact_dir", dir).Msg("downloading plan artifacts")
+	if _, err = p.agent.DownloadArtifacts(ctx, filepath.Join(dir, "*"), ".", opts...); err != nil {
+		return nil, fmt.Errorf("%w for %s: %w", ErrPlanNotFound, workingDir, err)
+	}
+	manifestJSON, err := os.ReadFile(filepath.Join(dir, manifestName))
+	if err != nil {
+		return nil, fmt.Errorf("%w for %s: %w", ErrPlanNotFound, workingDir, err)
+	}
+	expected, err := p.agent.GetMetaData(ctx, metaDataPrefix+key)
+	if err != nil {
+		return nil, fmt.Errorf("no checksum recorded for the saved plan for %s: %w", workingDir, err)
+	}
+	if actual := checksumBytes(manifestJSON); actual != expected {
+		return nil, fmt.Errorf(
+			"plan manifest for %s failed verification: checksum mismatch: expected %s, got %s",
+			workingDir, expected, actual,
+		)
+	}
+	var manifest Manifest
+	if err = json.Unmarshal(manifestJSON, &manifest); err != nil {
+		return nil, fmt.Errorf("failed to parse plan manifest: %w", err)
+	}
+	if manifest.WorkingDir != key {
+		return nil, fmt.Errorf("saved plan for %s was created in working directory %s", key, manifest.WorkingDir)
+	}
+	planFile, err := filepath.Abs(filepath.Join(dir, planBinaryName))
+	if err != nil {
+		return nil, fmt.Errorf("failed to resolve plan path: %w", err)
+	}
+	if err = verifyChecksum(planFile, manifest.PlanChecksum); err != nil {
+		return nil, fmt.Errorf("binary plan for %s failed verification: %w", workingDir, err)
+	}
+	if manifest.HasChanges {
+		if err = verifyChecksum(filepath.Join(dir, planJSONName), manifest.PlanJSONChecksum); err != nil {
+			return nil, fmt.Errorf("JSON plan for %s failed verification: %w", workingDir, err)
+		}
+	}
+	if commit := common.FetchEnv("BUILDKITE_COMMIT", ""); commit != "" && manifest.Commit != "" &&
+		commit != manifest.Commit {
+		return nil, fmt.Errorf(
+			"saved plan for %s is stale: created from commit %s but running on %s",
+			workingDir, manifest.Commit, commit,
+		)
+	}
+	log.Ctx(ctx).Info().
+		Str("working_dir", workingDir).
+		Str("plan_sha256", manifest.PlanChecksum).
+		Str("terraform_version", manifest.TerraformVersion).
+		Msg("downloaded and verified plan artifacts")
+	return &SavedPlan{Manifest: manifest, PlanFile: planFile}, nil
+}
+
+// copyWithChecksum copies src to dst and returns the SHA-256 checksum of the content.
+func copyWithChecksum(src, dst string) (string, error) {
+	in, err := os.Open(src)
+	if err != nil {
+		return "", err
+	}
+	defer in.Close()
+	outFile, err := os.Create(dst)
+	if err != nil {
+		return "", err
+	}
+	defer outFile.Close()
+	hash := sha256.New()
+	if _, err = io.Copy(io.MultiWriter(outFile, hash), in); err != nil {
+		return "", err
+	}
+	return hex.EncodeToString(hash.Sum(nil)), nil
+}
+
+// verifyChecksum checks that the file content matches the expected SHA-256 checksum.
+func verifyChecksum(path, expected string) error {
+	content, err := os.ReadFile(path)
+	if err != nil {
+		return err
+	}
+	if actual := checksumBytes(content); actual != expected {
+		return fmt.Errorf("checksum mismatch: expected %s, got %s", expected, actual)
+	}
+	return nil
+}
+
+func checksumBytes(b []byte) string {
+	sum := sha256.Sum256(b)
+	return hex.EncodeToString(sum[:])
+}
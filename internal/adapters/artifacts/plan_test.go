@@ -0,0 +1,137 @@
+package artifacts_test
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/cultureamp/terraform-buildkite-plugin/internal/adapters/artifacts"
+	tfjson "github.com/hashicorp/terraform-json"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// writePlan creates a fake binary plan file in a working directory at path
+// within a checkout, and points BUILDKITE_BUILD_CHECKOUT_PATH at the checkout.
+func writePlan(t *testing.T, checkout, path string) (string, string) {
+	t.Helper()
+	t.Setenv("BUILDKITE_BUILD_CHECKOUT_PATH", checkout)
+	workingDir := filepath.Join(checkout, path)
+	require.NoError(t, os.MkdirAll(workingDir, 0o755))
+	planFile := filepath.Join(workingDir, "plan.binary")
+	require.NoError(t, os.WriteFile(planFile, []byte("binary plan"), 0o600))
+	return workingDir, planFile
+}
+
+func TestPlanStore_RoundTrip(t *testing.T) {
+	t.Setenv("BUILDKITE_COMMIT", "abc123")
+	dir := t.TempDir()
+	ag := &localAgent{}
+	store := artifacts.NewPlanStore(&artifacts.PlanArtifacts{Directory: dir}, artifacts.WithAgent(ag))
+	workingDir, planFile := writePlan(t, t.TempDir(), "stacks/prod/network")
+
+	err := store.Upload(t.Context(), workingDir, planFile, &tfjson.Plan{FormatVersion: "1.2"}, "1.9.0")
+	require.NoError(t, err)
+	assert.Equal(t, []string{filepath.Join(dir, "stacks/prod/network", "*")}, ag.uploads)
+	assert.Contains(t, ag.metaData, "terraform-plan:stacks/prod/network")
+
+	saved, err := store.Download(t.Context(), workingDir)
+	require.NoError(t, err)
+	assert.Equal(t, []string{filepath.Join(dir, "stacks/prod/network", "*")}, ag.downloads)
+	assert.Equal(t, "network", saved.Manifest.Workspace)
+	assert.Equal(t, "stacks/prod/network", saved.Manifest.WorkingDir)
+	assert.Equal(t, "1.9.0", saved.Manifest.TerraformVersion)
+	assert.Equal(t, "abc123", saved.Manifest.Commit)
+	assert.True(t, saved.Manifest.HasChanges)
+	assert.Equal(t, filepath.Join(dir, "stacks/prod/network", "plan.binary"), saved.PlanFile)
+}
+
+func TestPlanStore_Upload(t *testing.T) {
+	t.Run("working directories sharing a name do not share artifacts", func(t *testing.T) {
+		dir := t.TempDir()
+		ag := &localAgent{}
+		store := artifacts.NewPlanStore(&artifacts.PlanArtifacts{Directory: dir}, artifacts.WithAgent(ag))
+		checkout := t.TempDir()
+		for _, path := range []string{"stacks/prod/network", "stacks/dev/network"} {
+			workingDir, planFile := writePlan(t, checkout, path)
+			require.NoError(t, store.Upload(t.Context(), workingDir, planFile, nil, "1.9.0"))
+		}
+		assert.Equal(t, []string{
+			filepath.Join(dir, "stacks/prod/network", "*"),
+			filepath.Join(dir, "stacks/dev/network", "*"),
+		}, ag.uploads)
+	})
+
+	t.Run("working directory outside the checkout", func(t *testing.T) {
+		store := artifacts.NewPlanStore(
+			&artifacts.PlanArtifacts{Directory: t.TempDir()},
+			artifacts.WithAgent(&localAgent{}),
+		)
+		_, planFile := writePlan(t, t.TempDir(), "network")
+		err := store.Upload(t.Context(), t.TempDir(), planFile, nil, "1.9.0")
+		require.ErrorContains(t, err, "outside the checkout")
+	})
+}
+
+func TestPlanStore_Download(t *testing.T) {
+	t.Run("missing plan", func(t *testing.T) {
+		t.Setenv("BUILDKITE_BUILD_CHECKOUT_PATH", "/")
+		store := artifacts.NewPlanStore(
+			&artifacts.PlanArtifacts{Directory: t.TempDir()},
+			artifacts.WithAgent(&localAgent{}),
+		)
+		_, err := store.Download(t.Context(), "/stacks/network")
+		require.ErrorIs(t, err, artifacts.ErrPlanNotFound)
+	})
+
+	t.Run("tampered plan", func(t *testing.T) {
+		dir := t.TempDir()
+		store := artifacts.NewPlanStore(&artifacts.PlanArtifacts{Directory: dir}, artifacts.WithAgent(&localAgent{}))
+		workingDir, planFile := writePlan(t, t.TempDir(), "cluster")
+		require.NoError(t, store.Upload(t.Context(), workingDir, planFile, nil, "1.9.0"))
+		require.NoError(t, os.WriteFile(filepath.Join(dir, "cluster", "plan.binary"), []byte("evil"), 0o600))
+
+		_, err := store.Download(t.Context(), workingDir)
+		require.Error(t, err)
+		assert.Contains(t, err.Error(), "checksum mismatch")
+	})
+
+	t.Run("tampered plan and manifest", func(t *testing.T) {
+		dir := t.TempDir()
+		store := artifacts.NewPlanStore(&artifacts.PlanArtifacts{Directory: dir}, artifacts.WithAgent(&localAgent{}))
+		workingDir, planFile := writePlan(t, t.TempDir(), "cluster")
+		require.NoError(t, store.Upload(t.Context(), workingDir, planFile, nil, "1.9.0"))
+		manifestFile := filepath.Join(dir, "cluster", "manifest.json")
+		manifest, err := os.ReadFile(manifestFile)
+		require.NoError(t, err)
+		require.NoError(t, os.WriteFile(manifestFile, append(manifest, '\n'), 0o600))
+
+		_, err = store.Download(t.Context(), workingDir)
+		require.ErrorContains(t, err, "plan manifest for")
+	})
+
+	t.Run("plan without a recorded checksum", func(t *testing.T) {
+		dir := t.TempDir()
+		ag := &localAgent{}
+		store := artifacts.NewPlanStore(&artifacts.PlanArtifacts{Directory: dir}, artifacts.WithAgent(ag))
+		workingDir, planFile := writePlan(t, t.TempDir(), "cluster")
+		require.NoError(t, store.Upload(t.Context(), workingDir, planFile, nil, "1.9.0"))
+		ag.metaData = nil
+
+		_, err := store.Download(t.Context(), workingDir)
+		require.ErrorContains(t, err, "no checksum recorded")
+	})
+
+	t.Run("stale plan from another commit", func(t *testing.T) {
+		dir := t.TempDir()
+		store := artifacts.NewPlanStore(&artifacts.PlanArtifacts{Directory: dir}, artifacts.WithAgent(&localAgent{}))
+		workingDir, planFile := writePlan(t, t.TempDir(), "app")
+		t.Setenv("BUILDKITE_COMMIT", "abc123")
+		require.NoError(t, store.Upload(t.Context(), workingDir, planFile, nil, "1.9.0"))
+		t.Setenv("BUILDKITE_COMMIT", "def456")
+
+		_, err := store.Download(t.Context(), workingDir)
+		require.Error(t, err)
+		assert.Contains(t, err.Error(), "stale")
+	})
+}
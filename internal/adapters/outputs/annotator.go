@@ -5,7 +5,10 @@ package outputs
 import (
 	"context"
 	"fmt"
+	"path/filepath"
+	"strings"
 
+	"github.com/cultureamp/terraform-buildkite-plugin/internal/common"
 	"github.com/cultureamp/terraform-buildkite-plugin/pkg/buildkite/agent"
 	tfjson "github.com/hashicorp/terraform-json"
 )
@@ -39,7 +42,8 @@ func WithConfig(c *BuildkiteAnnotation) BuildkiteAnnotatorOptions {
 // NewBuildkiteAnnotator creates a new annotator adapter for Buildkite annotations.
 func NewBuildkiteAnnotator(opts ...BuildkiteAnnotatorOptions) Outputer {
 	outputer := &buildkiteAnnotatorConfig{
-		agent: agent.NewAgent(),
+		agent:  agent.NewAgent(),
+		config: &BuildkiteAnnotation{},
 	}
 	for _, opt := range opts {
 		opt(outputer)
@@ -47,12 +51,28 @@ func NewBuildkiteAnnotator(opts ...BuildkiteAnnotatorOptions) Outputer {
 	return outputer
 }
 
-// Ouput creates a success annotation for completed operations.
+// Ouput annotates the build with the template for the stage, which is the
+// configured template or the stage's default template.
 func (a *buildkiteAnnotatorConfig) Ouput(ctx context.Context, _ *tfjson.Plan, stage Stage, data any) error {
-	_, err := a.agent.AnnotateWithTemplate(ctx, a.config.Template, data,
+	if d, ok := data.(*Data); ok {
+		vars, err := a.config.resolveVars(ctx, d)
+		if err != nil {
+			return fmt.Errorf("failed to resolve Buildkite annotation variables: %w", err)
+		}
+		// outputers share the data, so each renders a copy with its own variables
+		view := *d
+		view.Vars = vars
+		data = &view
+	}
+	message, err := a.render(stage, data)
+	if err != nil {
+		return fmt.Errorf("failed to render Buildkite annotation: %w", err)
+	}
+	_, err = a.agent.Annotate(ctx,
+		agent.WithMessage(message),
 		agent.WithAppend(false),
-		agent.WithStyle(stage.toBuildkiteAnnotationStyle()),
-		agent.WithContext(a.config.Context),
+		agent.WithStyle(a.config.style(stage)),
+		agent.WithContext(a.annotationContext(data)),
 	)
 	if err != nil {
 		return fmt.Errorf("failed to create Buildkite annotation: %w", err)
@@ -60,14 +80,51 @@ func (a *buildkiteAnnotatorConfig) Ouput(ctx context.Context, _ *tfjson.Plan, st
 	return nil
 }
 
+// annotationContext returns the annotation context for a workspace, so that each
+// workspace keeps its own annotation instead of replacing every other workspace's.
+// Workspaces are named by their path relative to the checkout, as working
+// directories in different parents can share a base name.
+func (a *buildkiteAnnotatorConfig) annotationContext(data any) string {
+	d, ok := data.(*Data)
+	if !ok {
+		return a.config.Context
+	}
+	workspace := d.Workspace
+	if d.WorkingDir != "" {
+		path, err := common.CheckoutRelativePath(d.WorkingDir)
+		if err != nil {
+			path = strings.TrimPrefix(filepath.ToSlash(filepath.Clean(d.WorkingDir)), "/")
+		}
+		workspace = path
+	}
+	switch {
+	case workspace == "":
+		return a.config.Context
+	case a.config.Context == "":
+		return workspace
+	default:
+		return a.config.Context + "-" + workspace
+	}
+}
+
+// style returns the annotation style configured for a stage, or the stage's default style.
+func (b *BuildkiteAnnotation) style(stage Stage) agent.AnnotationStyle {
+	if style, ok := b.Styles[stage]; ok {
+		return style
+	}
+	return stage.toBuildkiteAnnotationStyle()
+}
+
 // toBuildkiteAnnotationStyle converts the Stage to a Buildkite annotation style.
 func (s Stage) toBuildkiteAnnotationStyle() agent.AnnotationStyle {
 	switch s {
-	case PlanFailure, ApplyFailure, ValidationFailure, UnexpectedFailure:
+	case PlanFailure, ApplyFailure, ValidationFailure, UnexpectedFailure, DestroyPlanFailure, DestroyFailure:
 		return agent.StyleError
-	case PlanSuccessWithChanges, ValidationSuccess, ApplySuccess:
+	case PlanSuccessWithChanges, ValidationSuccess, ApplySuccess, DestroySuccess:
 		return agent.StyleSuccess
-	case PlanSuccessNoChanges:
+	case DestroyPlanSuccessWithChanges, DriftDetected:
+		return agent.StyleWarning
+	case PlanSuccessNoChanges, DestroyPlanSuccessNoChanges, NoDriftDetected:
 		return agent.StyleInfo
 	default:
 		return agent.StyleInfo
@@ -0,0 +1,257 @@
+package outputs
+
+import (
+	"os"
+	"os/exec"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/cultureamp/terraform-buildkite-plugin/internal/adapters/plansummary"
+	"github.com/cultureamp/terraform-buildkite-plugin/internal/adapters/validators"
+	"github.com/cultureamp/terraform-buildkite-plugin/pkg/buildkite/agent"
+	tfjson "github.com/hashicorp/terraform-json"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestAnnotationContext(t *testing.T) {
+	annotator := &buildkiteAnnotatorConfig{config: &BuildkiteAnnotation{Context: "terraform"}}
+	assert.Equal(t, "terraform-network", annotator.annotationContext(&Data{Workspace: "network"}))
+	assert.Equal(t, "terraform", annotator.annotationContext(nil))
+
+	unnamed := &buildkiteAnnotatorConfig{config: &BuildkiteAnnotation{}}
+	assert.Equal(t, "network", unnamed.annotationContext(&Data{Workspace: "network"}))
+
+	t.Run("working directories sharing a base name", func(t *testing.T) {
+		t.Setenv("BUILDKITE_BUILD_CHECKOUT_PATH", "/builds/org/pipeline")
+		assert.Equal(t, "terraform-a/app", annotator.annotationContext(&Data{
+			Workspace: "app", WorkingDir: "/builds/org/pipeline/a/app",
+		}))
+		assert.Equal(t, "terraform-b/app", annotator.annotationContext(&Data{
+			Workspace: "app", WorkingDir: "/builds/org/pipeline/b/app",
+		}))
+		assert.Equal(t, "terraform-other/app", annotator.annotationContext(&Data{
+			Workspace: "app", WorkingDir: "/other/app",
+		}))
+	})
+}
+
+// annotatingAgent returns an agent that records the message of each
+// annotation instead of running buildkite-agent.
+func annotatingAgent(messages *[]string) agent.Agent {
+	return agent.NewAgent(agent.WithCommandFn(func(_ string, args ...string) *exec.Cmd {
+		*messages = append(*messages, args[1])
+		return exec.Command("true")
+	}))
+}
+
+func writeTemplate(t *testing.T, content string) string {
+	t.Helper()
+	path := filepath.Join(t.TempDir(), "annotation.tmpl")
+	require.NoError(t, os.WriteFile(path, []byte(content), 0o600))
+	return path
+}
+
+func TestRender_DefaultTemplates(t *testing.T) {
+	annotator := &buildkiteAnnotatorConfig{config: &BuildkiteAnnotation{}}
+	summary := plansummary.New(&tfjson.Plan{ResourceChanges: []*tfjson.ResourceChange{
+		{Address: "aws_s3_bucket.logs", Type: "aws_s3_bucket", Change: &tfjson.Change{
+			Actions: tfjson.Actions{tfjson.ActionCreate},
+		}},
+	}})
+
+	for _, stage := range Stages() {
+		t.Run(string(stage), func(t *testing.T) {
+			_, err := annotator.render(stage, &Data{Workspace: "app", Stage: stage, Summary: summary})
+			require.NoError(t, err)
+		})
+	}
+
+	t.Run("plans summarise their changes", func(t *testing.T) {
+		rendered, err := annotator.render(PlanSuccessWithChanges, &Data{
+			Workspace: "app", Mode: "plan", Stage: PlanSuccessWithChanges, Summary: summary,
+		})
+		require.NoError(t, err)
+		assert.Contains(t, rendered, "#### :terraform: `app` plan\n")
+		assert.Contains(t, rendered, "| 1 | 0 | 0 | 0 | 0 | 0 | 0 | 0 |")
+		assert.Contains(t, rendered, "- :heavy_plus_sign: create `aws_s3_bucket.logs`")
+
+		rendered, err = annotator.render(PlanSuccessNoChanges, &Data{
+			Workspace: "app", WorkingDir: "stacks/app", Stage: PlanSuccessNoChanges,
+		})
+		require.NoError(t, err)
+		assert.Contains(t, rendered, "No changes. The infrastructure in `stacks/app` matches the configuration.")
+	})
+
+	t.Run("validation failures list each violation", func(t *testing.T) {
+		rendered, err := annotator.render(ValidationFailure, &Data{
+			Workspace: "app",
+			Stage:     ValidationFailure,
+			Validations: []validators.ValidationResult{{Failures: []validators.ValidationFailure{
+				{Type: "policy", Message: "buckets must be private", Path: "aws_s3_bucket.logs"},
+			}}},
+		})
+		require.NoError(t, err)
+		assert.Contains(t, rendered, "policy checks failed")
+		assert.Contains(t, rendered, "- **policy**: buckets must be private (`aws_s3_bucket.logs`)")
+	})
+
+	t.Run("failures include an excerpt of the error", func(t *testing.T) {
+		lines := make([]string, 40)
+		for i := range lines {
+			lines[i] = "Error: line"
+		}
+		rendered, err := annotator.render(ApplyFailure, &Data{
+			Workspace: "app", WorkingDir: "stacks/app", Mode: "apply", Stage: ApplyFailure,
+			Error: strings.Join(lines, "\n"),
+		})
+		require.NoError(t, err)
+		assert.Contains(t, rendered, "Applying `stacks/app` failed:")
+		assert.Equal(t, errorExcerptLines, strings.Count(rendered, "Error: line"))
+		assert.Contains(t, rendered, "... 10 more lines")
+	})
+}
+
+func TestRender_ConfiguredTemplate(t *testing.T) {
+	t.Run("the template body is used for every stage", func(t *testing.T) {
+		annotator := &buildkiteAnnotatorConfig{config: &BuildkiteAnnotation{
+			Template: writeTemplate(t, "{{ .Workspace }} {{ .Stage }}"),
+		}}
+		rendered, err := annotator.render(PlanFailure, &Data{Workspace: "app", Stage: PlanFailure})
+		require.NoError(t, err)
+		assert.Equal(t, "app plan_failure", rendered)
+	})
+
+	t.Run("stages can be overridden one at a time", func(t *testing.T) {
+		annotator := &buildkiteAnnotatorConfig{config: &BuildkiteAnnotation{
+			Template: writeTemplate(t, `{{ define "plan_failure" }}custom {{ .Workspace }}{{ end }}`),
+		}}
+		rendered, err := annotator.render(PlanFailure, &Data{Workspace: "app", Stage: PlanFailure})
+		require.NoError(t, err)
+		assert.Equal(t, "custom app", rendered)
+
+		rendered, err = annotator.render(ApplyFailure, &Data{Workspace: "app", Stage: ApplyFailure})
+		require.NoError(t, err)
+		assert.Contains(t, rendered, "#### :terraform: `app`")
+	})
+
+	t.Run("invalid templates fail", func(t *testing.T) {
+		annotator := &buildkiteAnnotatorConfig{config: &BuildkiteAnnotation{
+			Template: writeTemplate(t, "{{ .Workspace"),
+		}}
+		_, err := annotator.render(PlanFailure, &Data{})
+		require.ErrorContains(t, err, "failed to parse template")
+	})
+}
+
+func TestRender_StageTemplates(t *testing.T) {
+	annotator := &buildkiteAnnotatorConfig{config: &BuildkiteAnnotation{
+		Template: writeTemplate(t, "every stage"),
+		Templates: map[Stage]string{
+			PlanFailure:  writeTemplate(t, "{{ .Workspace }} failed to plan"),
+			ApplySuccess: writeTemplate(t, `{{ define "apply_success" }}applied {{ .Workspace }}{{ end }}`),
+		},
+	}}
+
+	for stage, expected := range map[Stage]string{
+		PlanFailure:  "app failed to plan",
+		ApplySuccess: "applied app",
+		ApplyFailure: "every stage",
+	} {
+		rendered, err := annotator.render(stage, &Data{Workspace: "app", Stage: stage})
+		require.NoError(t, err)
+		assert.Equal(t, expected, rendered, stage)
+	}
+}
+
+func TestRender_InlineTemplatesAndPartials(t *testing.T) {
+	partials := t.TempDir()
+	require.NoError(t, os.WriteFile(filepath.Join(partials, "header.tmpl"),
+		[]byte(":terraform: {{ .Workspace | htmlEscape }}"), 0o600))
+	require.NoError(t, os.WriteFile(filepath.Join(partials, "changes.tmpl"),
+		[]byte(`{{ define "changes" }}{{ pluralize .Changes "change" }}{{ end }}`), 0o600))
+	summary := plansummary.New(&tfjson.Plan{ResourceChanges: []*tfjson.ResourceChange{
+		{Address: "aws_s3_bucket.logs", Change: &tfjson.Change{Actions: tfjson.Actions{tfjson.ActionCreate}}},
+	}})
+	data := &Data{Workspace: "<app>", Stage: PlanSuccessWithChanges, Summary: summary}
+
+	for name, partials := range map[string]string{
+		"directory": partials,
+		"glob":      filepath.Join(partials, "*.tmpl"),
+	} {
+		t.Run(name, func(t *testing.T) {
+			annotator := &buildkiteAnnotatorConfig{config: &BuildkiteAnnotation{
+				Template: `{{ template "header.tmpl" . }} {{ template "changes" .Summary }}`,
+				Partials: partials,
+			}}
+			rendered, err := annotator.render(PlanSuccessWithChanges, data)
+			require.NoError(t, err)
+			assert.Equal(t, ":terraform: &lt;app&gt; 1 change", rendered)
+		})
+	}
+
+	t.Run("inline stage templates use functions", func(t *testing.T) {
+		annotator := &buildkiteAnnotatorConfig{config: &BuildkiteAnnotation{Templates: map[Stage]string{
+			PlanFailure: "{{ .Error | truncate 8 | markdownEscape }}\n{{ toJson .Vars }}",
+		}}}
+		rendered, err := annotator.render(PlanFailure, &Data{
+			Error: "failed_to_plan",
+			Vars:  map[string]string{"a": "b"},
+		})
+		require.NoError(t, err)
+		assert.Equal(t, "failed\\_…\n{\"a\":\"b\"}", rendered)
+	})
+
+	t.Run("missing partials fail", func(t *testing.T) {
+		annotator := &buildkiteAnnotatorConfig{config: &BuildkiteAnnotation{
+			Partials: filepath.Join(partials, "*.md"),
+		}}
+		_, err := annotator.render(PlanFailure, data)
+		require.ErrorContains(t, err, "failed to parse partials")
+	})
+}
+
+func TestStyle(t *testing.T) {
+	annotation := &BuildkiteAnnotation{Styles: map[Stage]agent.AnnotationStyle{
+		DestroyPlanSuccessWithChanges: agent.StyleError,
+		PlanSuccessWithChanges:        agent.StyleWarning,
+	}}
+	assert.Equal(t, agent.StyleError, annotation.style(DestroyPlanSuccessWithChanges))
+	assert.Equal(t, agent.StyleWarning, annotation.style(PlanSuccessWithChanges))
+	assert.Equal(t, agent.StyleSuccess, annotation.style(ApplySuccess))
+}
+
+func TestStages(t *testing.T) {
+	for _, stage := range Stages() {
+		assert.NotEmpty(t, defaultStageTemplate(stage))
+		assert.True(t, ValidStage(string(stage)))
+	}
+	assert.Empty(t, defaultStageTemplate("plan_failed"))
+	assert.False(t, ValidStage("plan_failed"))
+}
+
+func TestOuput_Vars(t *testing.T) {
+	t.Setenv("CLUSTER_NAME", "blue")
+	var messages []string
+	annotation := &BuildkiteAnnotation{
+		Template:     writeTemplate(t, "{{ .Vars.cluster }} {{ .Vars.namespace }}"),
+		Vars:         []map[string]string{{"cluster": "${CLUSTER_NAME}"}},
+		ComputedVars: []ComputedVar{{Name: "namespace", From: SourceWorkingDir, Regex: `^stacks/([^/]+)/`}},
+	}
+	data := &Data{Workspace: "app", WorkingDir: "stacks/payments/app"}
+
+	require.NoError(t, NewBuildkiteAnnotator(WithAgent(annotatingAgent(&messages)), WithConfig(annotation)).
+		Ouput(t.Context(), nil, PlanFailure, data))
+	assert.Equal(t, []string{"blue payments"}, messages)
+	assert.Nil(t, data.Vars, "shared data is not modified")
+}
+
+func TestOuput_WithoutTemplate(t *testing.T) {
+	var messages []string
+	annotator := NewBuildkiteAnnotator(WithAgent(annotatingAgent(&messages)), WithConfig(&BuildkiteAnnotation{}))
+
+	require.NoError(t, annotator.Ouput(t.Context(), nil, PlanSuccessNoChanges, &Data{Workspace: "app"}))
+	require.Len(t, messages, 1)
+	assert.Contains(t, messages[0], "#### :terraform: `app` plan")
+}
@@ -2,30 +2,49 @@
 // with the orchestrator interfaces.
 package outputs
 
-// ComputedVar defines a variable computed from Terraform output.
+import "github.com/cultureamp/terraform-buildkite-plugin/pkg/buildkite/agent"
+
+// ComputedVar defines a variable computed from a workspace and its plan.
 //
-// Computed variables allow extraction of specific values from Terraform
-// output using regular expressions, making them available for use in
-// output templates and other plugin operations.
+// Computed variables extract a value from a source, such as the working
+// directory or a path into the plan, using a regular expression and make it
+// available to output templates alongside the static variables.
 type ComputedVar struct {
 	// Name is the identifier for the computed variable.
 	// This name will be used to reference the variable in templates.
-	Name string `json:"name" jsonschema:"title=name,description=Name of the computed variable"`
+	Name string `json:"name" validate:"required" jsonschema:"title=name,description=Name of the computed variable"`
 
-	// From specifies the source field to extract the variable from.
-	// This should reference a field in the Terraform output or state.
-	From string `json:"from" jsonschema:"title=from,description=Source field to extract the variable from"`
+	// From specifies the source to extract the variable from: working_dir,
+	// workspace, env.<name> for an environment variable or plan.<path> for a
+	// gjson path into the plan JSON.
+	From string `json:"from" validate:"required,computed_var_source" jsonschema:"title=from,description=Source to extract the variable from: working_dir or workspace or env.<name> for an environment variable or plan.<path> for a gjson path into the plan JSON"`
 
 	// Regex is the regular expression used to extract the variable value.
-	// The first capture group from this regex will be used as the variable value.
-	Regex string `json:"regex" jsonschema:"title=regex,description=Regular expression to extract the variable value"`
+	// The first capture group, or the whole match when it has none, is used
+	// as the variable value.
+	Regex string `json:"regex" validate:"required,regexp" jsonschema:"title=regex,description=Regular expression to extract the variable value with its first capture group"`
 }
 
 type BuildkiteAnnotation struct {
-	// Template is the template string used for formatting output.
-	// This can include variable references that will be replaced with
-	// actual values during output generation.
-	Template string `json:"template,omitempty" jsonschema:"title=template,description=Template for formatting the output"`
+	// Template is the path of the template used for formatting output, or
+	// an inline template when it contains "{{" or a newline. It is parsed
+	// alongside the built-in default templates, so it can replace every
+	// stage's template with its body or a single stage's template by
+	// defining a template named after the stage.
+	Template string `json:"template,omitempty" jsonschema:"title=template,description=Path to a template for formatting the output or an inline template containing {{ or a newline. Defaults to a built-in template for each stage"`
+
+	// Templates maps stages to the path of the template, or the inline
+	// template, used for them instead of Template, such as
+	// plan_failure: ./templates/failure.tmpl.
+	Templates map[Stage]string `json:"templates,omitempty" validate:"dive,keys,stage,endkeys,required" jsonschema:"title=templates,description=Paths of the templates or inline templates used for each stage such as plan_failure instead of template"`
+
+	// Partials is a directory, or a glob, of templates parsed alongside
+	// every template so they can be included with {{ template "name" . }}.
+	Partials string `json:"partials,omitempty" jsonschema:"title=partials,description=Directory or glob of partial templates that templates can include by file name or defined name"`
+
+	// Styles maps stages to the annotation style used for them instead of
+	// the stage's default style.
+	Styles map[Stage]agent.AnnotationStyle `json:"styles,omitempty" validate:"dive,keys,stage,endkeys,oneof=success info warning error" jsonschema:"title=styles,description=Annotation styles (success or info or warning or error) used for each stage such as plan_success_with_changes instead of the stage's default style"`
 
 	// Context provides additional context for output formatting.
 	// This can be used to specify the context or environment where
@@ -33,13 +52,14 @@ type BuildkiteAnnotation struct {
 	Context string `json:"context,omitempty" jsonschema:"title=context,description=Context for the output formatting"`
 
 	// Vars contains static variables for use in output templates.
-	// These key-value pairs will be available for substitution in the template.
-	Vars []map[string]string `json:"vars,omitempty" jsonschema:"title=vars,description=Variables to be used in output formatting"`
+	// These key-value pairs are available to templates as .Vars, with
+	// environment variables in their values expanded.
+	Vars []map[string]string `json:"vars,omitempty" jsonschema:"title=vars,description=Variables available to templates as .Vars with environment variables expanded"`
 
-	// ComputedVars contains variables computed from Terraform output.
-	// These variables are dynamically extracted from Terraform execution
-	// results and made available for template substitution.
-	ComputedVars []ComputedVar `json:"computed_vars,omitempty" jsonschema:"title=computed_vars,description=Variables computed from Terraform output"`
+	// ComputedVars contains variables computed from each workspace and its plan.
+	// These variables are extracted when the output is rendered and are
+	// available to templates as .Vars alongside the static variables.
+	ComputedVars []ComputedVar `json:"computed_vars,omitempty" validate:"dive" jsonschema:"title=computed_vars,description=Variables computed from each workspace and its plan available to templates as .Vars"`
 }
 
 // Output configures how plugin results are formatted and presented.
@@ -56,5 +76,5 @@ type Outputs struct {
 	// Output configures how plugin results are formatted and presented.
 	// This controls the output format, templates, and variables used
 	// for presenting Terraform operation results.
-	Outputs []Output `json:"outputs,omitempty" jsonschema:"title=outputs,description=A list of output adaptors"`
+	Outputs []Output `json:"outputs,omitempty" validate:"dive" jsonschema:"title=outputs,description=A list of output adaptors"`
 }
@@ -0,0 +1,137 @@
+package outputs
+
+import (
+	"encoding/json"
+	"fmt"
+	"html"
+	"math"
+	"strings"
+	"text/template"
+	"time"
+
+	"github.com/cultureamp/terraform-buildkite-plugin/internal/adapters/plansummary"
+	tfjson "github.com/hashicorp/terraform-json"
+)
+
+// funcs returns the functions available to annotation templates.
+func funcs() template.FuncMap {
+	return template.FuncMap{
+		"planSummary":    plansummary.New,
+		"toJson":         toJSON,
+		"toPrettyJson":   toPrettyJSON,
+		"indent":         indent,
+		"truncate":       truncate,
+		"markdownEscape": markdownEscape,
+		"htmlEscape":     html.EscapeString,
+		"resourceLink":   resourceLink,
+		"duration":       duration,
+		"pluralize":      pluralize,
+	}
+}
+
+// toJSON marshals v as compact JSON.
+func toJSON(v any) (string, error) {
+	data, err := json.Marshal(v)
+	return string(data), err
+}
+
+// toPrettyJSON marshals v as JSON indented with two spaces.
+func toPrettyJSON(v any) (string, error) {
+	data, err := json.MarshalIndent(v, "", "  ")
+	return string(data), err
+}
+
+// indent prefixes every line of s with the given number of spaces.
+func indent(spaces int, s string) string {
+	prefix := strings.Repeat(" ", spaces)
+	return prefix + strings.ReplaceAll(s, "\n", "\n"+prefix)
+}
+
+// truncate shortens s to at most length characters, ending it with an
+// ellipsis when it is shortened.
+func truncate(length int, s string) string {
+	runes := []rune(s)
+	if len(runes) <= length {
+		return s
+	}
+	if length < 1 {
+		return ""
+	}
+	return string(runes[:length-1]) + "…"
+}
+
+// markdownEscaper escapes the characters that format inline markdown.
+//
+//nolint:gochecknoglobals // built once as replacers are safe for concurrent use
+var markdownEscaper = strings.NewReplacer(
+	`\`, `\\`, "`", "\\`", `*`, `\*`, `_`, `\_`, `[`, `\[`, `]`, `\]`, `(`, `\(`, `)`, `\)`,
+	`<`, `\<`, `>`, `\>`, `#`, `\#`, `|`, `\|`, `~`, `\~`, `!`, `\!`,
+)
+
+// markdownEscape escapes s so that it is shown as written in markdown.
+func markdownEscape(s string) string {
+	return markdownEscaper.Replace(s)
+}
+
+// registryHost is the host of providers with documentation on the Terraform registry.
+const registryHost = "registry.terraform.io/"
+
+// resourceLink returns a markdown link from a resource change's address to the
+// registry documentation of its type, or just the address when its provider
+// is not published on the registry.
+func resourceLink(rc *tfjson.ResourceChange) string {
+	if rc == nil {
+		return ""
+	}
+	address := "`" + rc.Address + "`"
+	provider, ok := strings.CutPrefix(rc.ProviderName, registryHost)
+	if !ok || strings.Count(provider, "/") != 1 {
+		return address
+	}
+	kind := "resources"
+	if rc.Mode == tfjson.DataResourceMode {
+		kind = "data-sources"
+	}
+	_, name, _ := strings.Cut(provider, "/")
+	return fmt.Sprintf("[%s](https://%sproviders/%s/latest/docs/%s/%s)",
+		address, registryHost, provider, kind, strings.TrimPrefix(rc.Type, name+"_"))
+}
+
+// duration formats a time.Duration, a number of seconds or a duration string
+// such as "90s" for reading, rounded to the second from a second upwards.
+func duration(v any) (string, error) {
+	var d time.Duration
+	switch v := v.(type) {
+	case time.Duration:
+		d = v
+	case int:
+		d = time.Duration(v) * time.Second
+	case int64:
+		d = time.Duration(v) * time.Second
+	case float64:
+		d = time.Duration(math.Round(v * float64(time.Second)))
+	case string:
+		var err error
+		if d, err = time.ParseDuration(v); err != nil {
+			return "", err
+		}
+	default:
+		return "", fmt.Errorf("cannot format %T as a duration", v)
+	}
+	if d >= time.Second {
+		d = d.Round(time.Second)
+	}
+	return d.String(), nil
+}
+
+// pluralize returns the count followed by the singular or plural form of a
+// word, which is the singular with an "s" unless one is given.
+func pluralize(count int, singular string, plural ...string) string {
+	if count == 1 {
+		return fmt.Sprintf("%d %s", count, singular)
+	}
+	if len(plural) > 0 {
+		return fmt.Sprintf("%d %s", count, plural[0])
+	}
+	return fmt.Sprintf("%d %ss", count, singular)
+}
@@ -0,0 +1,91 @@
+package outputs
+
+import (
+	"testing"
+	"time"
+
+	tfjson "github.com/hashicorp/terraform-json"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestFuncs(t *testing.T) {
+	t.Run("toJson", func(t *testing.T) {
+		compact, err := toJSON(map[string]int{"add": 1})
+		require.NoError(t, err)
+		assert.Equal(t, `{"add":1}`, compact)
+		pretty, err := toPrettyJSON(map[string]int{"add": 1})
+		require.NoError(t, err)
+		assert.Equal(t, "{\n  \"add\": 1\n}", pretty)
+		_, err = toJSON(func() {})
+		require.Error(t, err)
+	})
+
+	t.Run("indent", func(t *testing.T) {
+		assert.Equal(t, "  first\n  second", indent(2, "first\nsecond"))
+	})
+
+	t.Run("truncate", func(t *testing.T) {
+		assert.Equal(t, "short", truncate(10, "short"))
+		assert.Equal(t, "Error: a…", truncate(9, "Error: a long message"))
+		assert.Equal(t, "é…", truncate(2, "éèê"))
+		assert.Empty(t, truncate(0, "message"))
+	})
+
+	t.Run("escaping", func(t *testing.T) {
+		assert.Equal(t, `aws\_s3\_bucket.logs\[0\] \| \*\*`, markdownEscape("aws_s3_bucket.logs[0] | **"))
+	})
+
+	t.Run("resourceLink", func(t *testing.T) {
+		assert.Equal(t,
+			"[`aws_s3_bucket.logs`](https://registry.terraform.io/providers/hashicorp/aws/latest/docs/"+
+				"resources/s3_bucket)",
+			resourceLink(&tfjson.ResourceChange{
+				Address:      "aws_s3_bucket.logs",
+				Mode:         tfjson.ManagedResourceMode,
+				Type:         "aws_s3_bucket",
+				ProviderName: "registry.terraform.io/hashicorp/aws",
+			}))
+		assert.Equal(t,
+			"[`data.aws_caller_identity.current`](https://registry.terraform.io/providers/hashicorp/aws/latest/docs/"+
+				"data-sources/caller_identity)",
+			resourceLink(&tfjson.ResourceChange{
+				Address:      "data.aws_caller_identity.current",
+				Mode:         tfjson.DataResourceMode,
+				Type:         "aws_caller_identity",
+				ProviderName: "registry.terraform.io/hashicorp/aws",
+			}))
+		assert.Equal(t, "`internal_thing.app`", resourceLink(&tfjson.ResourceChange{
+			Address:      "internal_thing.app",
+			Type:         "internal_thing",
+			ProviderName: "terraform.example.com/platform/internal",
+		}))
+		assert.Empty(t, resourceLink(nil))
+	})
+
+	t.Run("duration", func(t *testing.T) {
+		for input, expected := range map[any]string{
+			90 * time.Second:        "1m30s",
+			1500 * time.Millisecond: "2s",
+			250 * time.Millisecond:  "250ms",
+			42:                      "42s",
+			int64(3600):             "1h0m0s",
+			12.4:                    "12s",
+			"2m30.5s":               "2m31s",
+		} {
+			formatted, err := duration(input)
+			require.NoError(t, err)
+			assert.Equal(t, expected, formatted, input)
+		}
+		_, err := duration("soon")
+		require.Error(t, err)
+		_, err = duration(true)
+		require.ErrorContains(t, err, "cannot format bool as a duration")
+	})
+
+	t.Run("pluralize", func(t *testing.T) {
+		assert.Equal(t, "1 resource", pluralize(1, "resource"))
+		assert.Equal(t, "0 resources", pluralize(0, "resource"))
+		assert.Equal(t, "3 policies", pluralize(3, "policy", "policies"))
+	})
+}
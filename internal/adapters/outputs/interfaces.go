@@ -2,7 +2,12 @@ package outputs
 
 import (
 	"context"
+	"fmt"
+	"slices"
+	"strings"
 
+	"github.com/cultureamp/terraform-buildkite-plugin/internal/adapters/plansummary"
+	"github.com/cultureamp/terraform-buildkite-plugin/internal/adapters/validators"
 	tfjson "github.com/hashicorp/terraform-json"
 )
 
@@ -17,8 +22,84 @@ const (
 	PlanSuccessWithChanges Stage = "plan_success_with_changes"
 	ValidationSuccess      Stage = "validation_success"
 	ApplySuccess           Stage = "apply_success"
+	// Destroy stages are reported instead of their plan/apply counterparts in destroy mode.
+	DestroyPlanFailure            Stage = "destroy_plan_failure"
+	DestroyPlanSuccessNoChanges   Stage = "destroy_plan_success_no_changes"
+	DestroyPlanSuccessWithChanges Stage = "destroy_plan_success_with_changes"
+	DestroySuccess                Stage = "destroy_success"
+	DestroyFailure                Stage = "destroy_failure"
+	// Drift stages are reported by refresh-only plans in drift mode.
+	DriftDetected   Stage = "drift_detected"
+	NoDriftDetected Stage = "no_drift_detected"
 )
 
+// Stages returns every stage reported to outputers.
+func Stages() []Stage {
+	return []Stage{
+		PlanFailure,
+		ApplyFailure,
+		ValidationFailure,
+		UnexpectedFailure,
+		PlanSuccessNoChanges,
+		PlanSuccessWithChanges,
+		ValidationSuccess,
+		ApplySuccess,
+		DestroyPlanFailure,
+		DestroyPlanSuccessNoChanges,
+		DestroyPlanSuccessWithChanges,
+		DestroySuccess,
+		DestroyFailure,
+		DriftDetected,
+		NoDriftDetected,
+	}
+}
+
+// ValidStage reports whether s names a stage.
+func ValidStage(s string) bool {
+	return slices.Contains(Stages(), Stage(s))
+}
+
+// Data is the payload handed to every Outputer for a workspace at a given stage.
+//
+// Annotation templates are executed against this struct, so field names are
+// part of the public template contract.
+type Data struct {
+	// Workspace is the base name of the working directory.
+	Workspace string `json:"workspace"`
+	// WorkingDir is the path of the Terraform working directory.
+	WorkingDir string `json:"working_dir"`
+	// Mode is the plugin mode the workspace is running in.
+	Mode string `json:"mode"`
+	// Stage is the lifecycle stage being reported.
+	Stage Stage `json:"stage"`
+	// Plan is the Terraform plan, when one has been produced.
+	Plan *tfjson.Plan `json:"plan,omitempty"`
+	// Summary counts and lists the resource changes of the plan, when one has been produced.
+	Summary *plansummary.Summary `json:"summary,omitempty"`
+	// DriftedResources lists the addresses of resources that drifted, in drift mode.
+	DriftedResources []string `json:"drifted_resources,omitempty"`
+	// Validations contains the results of every validator that has run.
+	Validations []validators.ValidationResult `json:"validations,omitempty"`
+	// Error describes the failure for failure stages.
+	Error string `json:"error,omitempty"`
+	// Vars are the static and computed variables of the outputer rendering the data.
+	Vars map[string]string `json:"vars,omitempty"`
+}
+
+// errorExcerptLines is the number of lines of an error kept by ErrorExcerpt.
+const errorExcerptLines = 30
+
+// ErrorExcerpt returns the first lines of Error, so that long Terraform errors
+// fit in an annotation.
+func (d *Data) ErrorExcerpt() string {
+	lines := strings.Split(strings.TrimSpace(d.Error), "\n")
+	if len(lines) <= errorExcerptLines {
+		return strings.Join(lines, "\n")
+	}
+	return strings.Join(lines[:errorExcerptLines], "\n") +
+		fmt.Sprintf("\n... %d more lines", len(lines)-errorExcerptLines)
+}
+
 type Outputer interface {
 	Ouput(ctx context.Context, plan *tfjson.Plan, stage Stage, data any) error
 }
@@ -0,0 +1,113 @@
+package outputs
+
+import (
+	"embed"
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+	"text/template"
+	"text/template/parse"
+)
+
+// defaultTemplates are the annotation templates used for stages without a configured template.
+//
+//go:embed templates/*.tmpl
+var defaultTemplates embed.FS
+
+// defaultStageTemplate returns the default template of a stage, or an empty
+// string for an unknown stage.
+func defaultStageTemplate(stage Stage) string {
+	switch stage {
+	case PlanSuccessNoChanges, PlanSuccessWithChanges, DestroyPlanSuccessNoChanges, DestroyPlanSuccessWithChanges:
+		return "plan.tmpl"
+	case ValidationSuccess, ValidationFailure:
+		return "validation.tmpl"
+	case ApplySuccess, DestroySuccess:
+		return "apply.tmpl"
+	case DriftDetected, NoDriftDetected:
+		return "drift.tmpl"
+	case PlanFailure, ApplyFailure, UnexpectedFailure, DestroyPlanFailure, DestroyFailure:
+		return "failure.tmpl"
+	default:
+		return ""
+	}
+}
+
+// render renders the annotation for a stage. The partials and the stage's
+// template, from Templates or else Template, are parsed alongside the default
+// templates, so a stage is rendered with the first of:
+//
+//   - a template named after the stage defined in the template or a partial,
+//     such as {{ define "plan_failure" }}
+//   - the body of the template, when it has one
+//   - the stage's default template
+func (a *buildkiteAnnotatorConfig) render(stage Stage, data any) (string, error) {
+	tmpl, err := template.New("annotation").Funcs(funcs()).ParseFS(defaultTemplates, "templates/*.tmpl")
+	if err != nil {
+		return "", fmt.Errorf("failed to parse default templates: %w", err)
+	}
+	if a.config.Partials != "" {
+		tmpl, err = tmpl.ParseGlob(partialsPattern(a.config.Partials))
+		if err != nil {
+			return "", fmt.Errorf("failed to parse partials: %w", err)
+		}
+	}
+	name := defaultStageTemplate(stage)
+	if source := a.config.templateSource(stage); source != "" {
+		t, err := parseTemplate(tmpl, source)
+		if err != nil {
+			return "", fmt.Errorf("failed to parse template: %w", err)
+		}
+		if t != nil && t.Tree != nil && !parse.IsEmptyTree(t.Root) {
+			name = t.Name()
+		}
+	}
+	if tmpl.Lookup(string(stage)) != nil {
+		name = string(stage)
+	}
+	if name == "" {
+		return "", fmt.Errorf("no template for stage %s", stage)
+	}
+	var rendered strings.Builder
+	if err = tmpl.ExecuteTemplate(&rendered, name, data); err != nil {
+		return "", fmt.Errorf("failed to execute template: %w", err)
+	}
+	return rendered.String(), nil
+}
+
+// templateSource returns the template configured for a stage, if any, which
+// is either a path or an inline template.
+func (b *BuildkiteAnnotation) templateSource(stage Stage) string {
+	if source, ok := b.Templates[stage]; ok {
+		return source
+	}
+	return b.Template
+}
+
+// inlineTemplate reports whether a configured template is the template itself
+// rather than the path of a template file.
+func inlineTemplate(source string) bool {
+	return strings.Contains(source, "{{") || strings.Contains(source, "\n")
+}
+
+// parseTemplate parses a configured template into tmpl and returns it. Inline
+// templates are named "inline" and template files are named after their base name.
+func parseTemplate(tmpl *template.Template, source string) (*template.Template, error) {
+	if inlineTemplate(source) {
+		return tmpl.New("inline").Parse(source)
+	}
+	if _, err := tmpl.ParseFiles(source); err != nil {
+		return nil, err
+	}
+	return tmpl.Lookup(filepath.Base(source)), nil
+}
+
+// partialsPattern returns the glob matching the partials, which are every file
+// in a directory or the files matching a glob.
+func partialsPattern(partials string) string {
+	if info, err := os.Stat(partials); err == nil && info.IsDir() {
+		return filepath.Join(partials, "*")
+	}
+	return partials
+}
@@ -0,0 +1,115 @@
+package outputs
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"os"
+	"regexp"
+	"strings"
+
+	"github.com/rs/zerolog/log"
+	"github.com/tidwall/gjson"
+)
+
+// Sources of computed variables.
+const (
+	// SourceWorkingDir is the path of the working directory.
+	SourceWorkingDir = "working_dir"
+	// SourceWorkspace is the base name of the working directory.
+	SourceWorkspace = "workspace"
+	// SourceEnvPrefix prefixes the name of an environment variable, such as env.STEP_ENVIRONMENT.
+	SourceEnvPrefix = "env."
+	// SourcePlanPrefix prefixes a gjson path into the plan JSON, such as plan.terraform_version.
+	SourcePlanPrefix = "plan."
+)
+
+// ValidSource reports whether from names a computed variable source that can
+// be resolved, which for environment variables means they are set.
+func ValidSource(from string) bool {
+	switch {
+	case from == SourceWorkingDir, from == SourceWorkspace:
+		return true
+	case strings.HasPrefix(from, SourceEnvPrefix):
+		_, ok := os.LookupEnv(strings.TrimPrefix(from, SourceEnvPrefix))
+		return ok
+	case strings.HasPrefix(from, SourcePlanPrefix):
+		return strings.TrimPrefix(from, SourcePlanPrefix) != ""
+	default:
+		return false
+	}
+}
+
+// resolveVars resolves the static variables, with environment variables
+// expanded, and the computed variables for a workspace. Later variables
+// replace earlier ones of the same name, and computed variables replace
+// static ones.
+func (b *BuildkiteAnnotation) resolveVars(ctx context.Context, data *Data) (map[string]string, error) {
+	vars := map[string]string{}
+	for _, static := range b.Vars {
+		for name, value := range static {
+			vars[name] = os.ExpandEnv(value)
+		}
+	}
+	var plan []byte
+	for _, computed := range b.ComputedVars {
+		re, err := regexp.Compile(computed.Regex)
+		if err != nil {
+			return nil, fmt.Errorf("invalid regex for computed variable %s: %w", computed.Name, err)
+		}
+		source, err := computed.source(data, &plan)
+		if err != nil {
+			return nil, fmt.Errorf("failed to resolve computed variable %s: %w", computed.Name, err)
+		}
+		vars[computed.Name] = capture(re, source)
+		if vars[computed.Name] == "" {
+			log.Ctx(ctx).Warn().
+				Str("name", computed.Name).
+				Str("from", computed.From).
+				Str("regex", computed.Regex).
+				Msg("computed variable did not match its source")
+		}
+	}
+	return vars, nil
+}
+
+// source returns the value a computed variable is matched against. The plan
+// is marshalled into plan the first time a plan source is read.
+func (c ComputedVar) source(data *Data, plan *[]byte) (string, error) {
+	switch {
+	case c.From == SourceWorkingDir:
+		return data.WorkingDir, nil
+	case c.From == SourceWorkspace:
+		return data.Workspace, nil
+	case strings.HasPrefix(c.From, SourceEnvPrefix):
+		return os.Getenv(strings.TrimPrefix(c.From, SourceEnvPrefix)), nil
+	case strings.HasPrefix(c.From, SourcePlanPrefix):
+		if data.Plan == nil {
+			return "", nil
+		}
+		if *plan == nil {
+			raw, err := json.Marshal(data.Plan)
+			if err != nil {
+				return "", fmt.Errorf("failed to marshal plan: %w", err)
+			}
+			*plan = raw
+		}
+		return gjson.GetBytes(*plan, strings.TrimPrefix(c.From, SourcePlanPrefix)).String(), nil
+	default:
+		return "", fmt.Errorf("unknown source %q", c.From)
+	}
+}
+
+// capture returns the first capture group of the first match of re in s, or
+// the whole match when re has no capture groups.
+func capture(re *regexp.Regexp, s string) string {
+	match := re.FindStringSubmatch(s)
+	switch len(match) {
+	case 0:
+		return ""
+	case 1:
+		return match[0]
+	default:
+		return match[1]
+	}
+}
@@ -0,0 +1,74 @@
+package outputs
+
+import (
+	"testing"
+
+	tfjson "github.com/hashicorp/terraform-json"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestResolveVars(t *testing.T) {
+	t.Setenv("CLUSTER_NAME", "blue")
+	t.Setenv("STEP_ENVIRONMENT", "production-us")
+	annotation := &BuildkiteAnnotation{
+		Vars: []map[string]string{
+			{"cluster": "${CLUSTER_NAME}-cluster", "team": "platform"},
+			{"team": "sre"},
+		},
+		ComputedVars: []ComputedVar{
+			{Name: "namespace", From: SourceWorkingDir, Regex: `^stacks/([^/]+)/`},
+			{Name: "stack", From: SourceWorkspace, Regex: `^[a-z]+`},
+			{Name: "environment", From: "env.STEP_ENVIRONMENT", Regex: `^(\w+)-`},
+			{Name: "version", From: "plan.terraform_version", Regex: `^(\d+\.\d+)`},
+			{Name: "bucket", From: "plan.resource_changes.0.address", Regex: `\.(\w+)$`},
+			{Name: "missing", From: SourceWorkspace, Regex: `^prod`},
+		},
+	}
+	data := &Data{
+		Workspace:  "app",
+		WorkingDir: "stacks/payments/app",
+		Plan: &tfjson.Plan{
+			TerraformVersion: "1.9.8",
+			ResourceChanges:  []*tfjson.ResourceChange{{Address: "aws_s3_bucket.logs"}},
+		},
+	}
+
+	vars, err := annotation.resolveVars(t.Context(), data)
+	require.NoError(t, err)
+	assert.Equal(t, map[string]string{
+		"cluster":     "blue-cluster",
+		"team":        "sre",
+		"namespace":   "payments",
+		"stack":       "app",
+		"environment": "production",
+		"version":     "1.9",
+		"bucket":      "logs",
+		"missing":     "",
+	}, vars)
+
+	t.Run("plan sources are empty without a plan", func(t *testing.T) {
+		vars, err := annotation.resolveVars(t.Context(), &Data{WorkingDir: "stacks/payments/app"})
+		require.NoError(t, err)
+		assert.Empty(t, vars["version"])
+		assert.Equal(t, "payments", vars["namespace"])
+	})
+
+	t.Run("unknown sources fail", func(t *testing.T) {
+		annotation := &BuildkiteAnnotation{ComputedVars: []ComputedVar{{Name: "branch", From: "branch", Regex: ".*"}}}
+		_, err := annotation.resolveVars(t.Context(), data)
+		require.ErrorContains(t, err, `failed to resolve computed variable branch: unknown source "branch"`)
+	})
+}
+
+func TestValidSource(t *testing.T) {
+	t.Setenv("STEP_ENVIRONMENT", "production")
+	assert.True(t, ValidSource("working_dir"))
+	assert.True(t, ValidSource("workspace"))
+	assert.True(t, ValidSource("env.STEP_ENVIRONMENT"))
+	assert.True(t, ValidSource("plan.resource_changes.#.address"))
+	assert.False(t, ValidSource("env.UNSET_ENVIRONMENT"))
+	assert.False(t, ValidSource("plan."))
+	assert.False(t, ValidSource("working_directory"))
+	assert.False(t, ValidSource(""))
+}
@@ -0,0 +1,53 @@
+// Package pipeline provides adapters for generating Buildkite pipelines
+// that run the plugin once per working directory.
+package pipeline
+
+// StepOptions configures each generated plugin step.
+type StepOptions struct {
+	// Label is a Go template for each step's label.
+	// The template has access to .Workspace, .WorkingDir and .Mode.
+	Label string `json:"label,omitempty" jsonschema:"title=label,description=Go template for each step label (defaults to ':terraform: {{.Mode}} {{.Workspace}}')"`
+
+	// Agents are the agent query rules for each step, such as the queue.
+	Agents map[string]string `json:"agents,omitempty" jsonschema:"title=agents,description=Agent query rules for each generated step"`
+
+	// ConcurrencyGroup is a Go template for each step's concurrency group.
+	// The template has access to .Workspace, .WorkingDir and .Mode.
+	ConcurrencyGroup string `json:"concurrency_group,omitempty" jsonschema:"title=concurrency_group,description=Go template for each step concurrency group"`
+
+	// Concurrency is the number of jobs allowed to run in each concurrency group.
+	// Defaults to 1 when a concurrency group is configured.
+	Concurrency int `json:"concurrency,omitempty" validate:"omitempty,min=1" jsonschema:"title=concurrency,description=Number of jobs allowed to run in each concurrency group (defaults to 1)"`
+}
+
+// Options configures the dynamic pipeline generated in pipeline mode.
+//
+// One command step is generated per working directory, each running this
+// plugin pinned to that directory with the rest of the plugin configuration
+// carried over.
+type Options struct {
+	// Mode is the plugin mode each generated step runs in.
+	Mode string `json:"mode" validate:"required,oneof=plan apply destroy drift" jsonschema:"title=mode,description=Operation mode for each generated step (plan or apply or destroy or drift)"`
+
+	// Key is the prefix of each step key.
+	// Set it when more than one pipeline mode step in a build generates steps.
+	Key string `json:"key,omitempty" jsonschema:"title=key,description=Prefix of each generated step key (defaults to terraform)"`
+
+	StepOptions
+}
+
+// Approval configures the approval gate uploaded after a plan.
+//
+// When any working directory has changes, a block step is uploaded followed
+// by an apply step for each working directory with changes. Working
+// directories without changes get no steps.
+type Approval struct {
+	// Block is the label of the block step.
+	Block string `json:"block,omitempty" jsonschema:"title=block,description=Label of the block step (defaults to ':terraform: Apply changes?')"`
+
+	// Key is the key of the block step and the prefix of each apply step key.
+	// Set it when more than one plan step in a build uploads an approval gate.
+	Key string `json:"key,omitempty" jsonschema:"title=key,description=Key of the block step and prefix of each apply step key (defaults to terraform-apply)"`
+
+	StepOptions
+}
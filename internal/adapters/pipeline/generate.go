@@ -0,0 +1,355 @@
+package pipeline
+
+import (
+	"encoding/json"
+	"fmt"
+	"path/filepath"
+	"regexp"
+	"strings"
+	"text/template"
+
+	"github.com/cultureamp/terraform-buildkite-plugin/internal/common"
+	"github.com/rs/zerolog/log"
+)
+
+const (
+	defaultLabel       = ":terraform: {{.Mode}} {{.Workspace}}"
+	defaultBlock       = ":terraform: Apply changes?"
+	defaultApprovalKey = "terraform-apply"
+	defaultStepKey     = "terraform"
+	applyMode          = "apply"
+)
+
+// invalidKeyChars matches characters that are not allowed in a Buildkite step key.
+var invalidKeyChars = regexp.MustCompile(`[^a-zA-Z0-9_:-]+`)
+
+// Pipeline is a Buildkite pipeline that can be uploaded with `buildkite-agent pipeline upload`.
+type Pipeline struct {
+	Steps []Step `json:"steps"`
+}
+
+// Step is a Buildkite pipeline step.
+type Step struct {
+	Label            string                       `json:"label,omitempty"`
+	Block            string                       `json:"block,omitempty"`
+	Prompt           string                       `json:"prompt,omitempty"`
+	Key              string                       `json:"key,omitempty"`
+	DependsOn        []string                     `json:"depends_on,omitempty"`
+	Agents           map[string]string            `json:"agents,omitempty"`
+	ConcurrencyGroup string                       `json:"concurrency_group,omitempty"`
+	Concurrency      int                          `json:"concurrency,omitempty"`
+	Plugins          []map[string]json.RawMessage `json:"plugins,omitempty"`
+}
+
+// Change describes a working directory whose plan has changes.
+type Change struct {
+	WorkingDir string
+	// Summary is a short description of the planned changes, included in the block step prompt.
+	Summary string
+}
+
+// stepData is the data available to label and concurrency group templates.
+type stepData struct {
+	Workspace  string
+	WorkingDir string
+	Mode       string
+}
+
+// StepKey returns the step key used for a working directory, derived from its
+// path relative to the checkout so that working directories sharing a base
+// name get different keys.
+func StepKey(prefix, workingDir string) string {
+	path, err := common.CheckoutRelativePath(workingDir)
+	if err != nil {
+		path = strings.TrimPrefix(filepath.ToSlash(filepath.Clean(workingDir)), "/")
+	}
+	return prefix + "-" + strings.Trim(invalidKeyChars.ReplaceAllString(path, "-"), "-")
+}
+
+// stepKeys detects working directories that map to the same step key.
+type stepKeys map[string]string
+
+// add records the step key of a working directory, returning an error when
+// another working directory already has the same key.
+func (k stepKeys) add(key, workingDir string) error {
+	if other, ok := k[key]; ok {
+		return fmt.Errorf("working directories %s and %s both have step key %s", other, workingDir, key)
+	}
+	k[key] = workingDir
+	return nil
+}
+
+// Generate builds a pipeline with one plugin step per working directory.
+//
+// Parameters:
+//   - reference: The plugin reference, as used in BUILDKITE_PLUGINS (e.g. "github.com/org/plugin#v1.0.0")
+//   - config: The raw plugin configuration to carry over to each step
+//   - workingDirs: The working directories to generate steps for
+//   - dependencies: The working directories each working directory depends on
+//
+// Each step's plugin configuration is the raw configuration with mode set to
+// Options.Mode, working pinned to the step's working directory and the
+// pipeline options removed.
+func (p *Options) Generate(
+	reference string,
+	config json.RawMessage,
+	workingDirs []string,
+	dependencies map[string][]string,
+) (*Pipeline, error) {
+	gen, err := newGenerator(&p.StepOptions, reference, config)
+	if err != nil {
+		return nil, err
+	}
+	prefix := defaultIfEmpty(p.Key, defaultStepKey)
+	approval, err := gen.approval()
+	if err != nil {
+		return nil, err
+	}
+	approvalPrefix := approvalKey(approval)
+	keys := stepKeys{}
+	pipeline := &Pipeline{Steps: make([]Step, 0, len(workingDirs))}
+	for _, workingDir := range workingDirs {
+		key := StepKey(prefix, workingDir)
+		if err = keys.add(key, workingDir); err != nil {
+			return nil, err
+		}
+		var dependsOn []string
+		for _, dependency := range dependencies[workingDir] {
+			dependsOn = append(dependsOn, StepKey(prefix, dependency))
+		}
+		if approval != nil {
+			// each generated plan step uploads its own approval gate, so the
+			// block step keys must not collide
+			approval["key"], err = json.Marshal(StepKey(approvalPrefix, workingDir))
+			if err != nil {
+				return nil, fmt.Errorf("failed to marshal approval key: %w", err)
+			}
+			if gen.base["approval"], err = json.Marshal(approval); err != nil {
+				return nil, fmt.Errorf("failed to marshal approval configuration: %w", err)
+			}
+		}
+		step, stepErr := gen.step(p.Mode, workingDir, key, dependsOn)
+		if stepErr != nil {
+			return nil, stepErr
+		}
+		pipeline.Steps = append(pipeline.Steps, *step)
+	}
+	log.Debug().Int("steps", len(pipeline.Steps)).Msg("generated pipeline")
+	return pipeline, nil
+}
+
+// Generate builds a pipeline with a block step followed by an apply step for
+// each working directory with changes.
+//
+// Parameters:
+//   - reference: The plugin reference, as used in BUILDKITE_PLUGINS (e.g. "github.com/org/plugin#v1.0.0")
+//   - config: The raw plugin configuration of the plan step
+//   - changes: The working directories with changes, in the order to apply them
+//   - dependencies: The working directories each working directory depends on
+//   - planStep: The ID of the plan step, used to download plans when plan artifacts are configured
+//
+// Each apply step depends on the block step, and on the apply steps of any
+// changed working directories it depends on. When the plan step shares plans
+// as artifacts, each apply step downloads the plans from planStep unless a
+// step is already configured.
+func (a *Approval) Generate(
+	reference string,
+	config json.RawMessage,
+	changes []Change,
+	dependencies map[string][]string,
+	planStep string,
+) (*Pipeline, error) {
+	gen, err := newGenerator(&a.StepOptions, reference, config)
+	if err != nil {
+		return nil, err
+	}
+	delete(gen.base, "approval")
+	if err = gen.setPlanStep(planStep); err != nil {
+		return nil, err
+	}
+	key := defaultIfEmpty(a.Key, defaultApprovalKey)
+	block := Step{
+		Block:  defaultIfEmpty(a.Block, defaultBlock),
+		Key:    key,
+		Prompt: approvalPrompt(changes),
+	}
+	changed := make(map[string]bool, len(changes))
+	for _, change := range changes {
+		changed[change.WorkingDir] = true
+	}
+	keys := stepKeys{key: "block step"}
+	pipeline := &Pipeline{Steps: []Step{block}}
+	for _, change := range changes {
+		stepKey := StepKey(key, change.WorkingDir)
+		if err = keys.add(stepKey, change.WorkingDir); err != nil {
+			return nil, err
+		}
+		// explicit dependencies replace implicit ordering, so the block step must be listed
+		dependsOn := []string{key}
+		for _, dependency := range dependencies[change.WorkingDir] {
+			if changed[dependency] {
+				dependsOn = append(dependsOn, StepKey(key, dependency))
+			}
+		}
+		step, stepErr := gen.step(applyMode, change.WorkingDir, stepKey, dependsOn)
+		if stepErr != nil {
+			return nil, stepErr
+		}
+		pipeline.Steps = append(pipeline.Steps, *step)
+	}
+	log.Debug().Int("steps", len(pipeline.Steps)).Msg("generated approval pipeline")
+	return pipeline, nil
+}
+
+// approvalPrompt summarises the changes awaiting approval.
+func approvalPrompt(changes []Change) string {
+	var sb strings.Builder
+	noun := "workspaces"
+	if len(changes) == 1 {
+		noun = "workspace"
+	}
+	fmt.Fprintf(&sb, "Apply Terraform changes to %d %s?\n", len(changes), noun)
+	for _, change := range changes {
+		fmt.Fprintf(&sb, "\n%s: %s", filepath.Base(change.WorkingDir), change.Summary)
+	}
+	return sb.String()
+}
+
+// generator builds plugin steps from a base plugin configuration.
+type generator struct {
+	options   *StepOptions
+	reference string
+	base      map[string]json.RawMessage
+	label     *template.Template
+	group     *template.Template
+}
+
+func newGenerator(options *StepOptions, reference string, config json.RawMessage) (*generator, error) {
+	label, err := template.New("label").Parse(defaultIfEmpty(options.Label, defaultLabel))
+	if err != nil {
+		return nil, fmt.Errorf("failed to parse label template: %w", err)
+	}
+	group, err := template.New("concurrency_group").Parse(options.ConcurrencyGroup)
+	if err != nil {
+		return nil, fmt.Errorf("failed to parse concurrency group template: %w", err)
+	}
+	var base map[string]json.RawMessage
+	if err = json.Unmarshal(config, &base); err != nil {
+		return nil, fmt.Errorf("failed to parse plugin configuration: %w", err)
+	}
+	return &generator{options: options, reference: reference, base: base, label: label, group: group}, nil
+}
+
+// approval returns the approval configuration carried over to each step, or nil when there is none.
+func (g *generator) approval() (map[string]json.RawMessage, error) {
+	raw, ok := g.base["approval"]
+	if !ok {
+		return nil, nil
+	}
+	var approval map[string]json.RawMessage
+	if err := json.Unmarshal(raw, &approval); err != nil {
+		return nil, fmt.Errorf("failed to parse approval configuration: %w", err)
+	}
+	if approval == nil {
+		approval = map[string]json.RawMessage{}
+	}
+	return approval, nil
+}
+
+// approvalKey returns the configured approval key, or the default.
+func approvalKey(approval map[string]json.RawMessage) string {
+	var key string
+	if raw, ok := approval["key"]; ok {
+		_ = json.Unmarshal(raw, &key)
+	}
+	return defaultIfEmpty(key, defaultApprovalKey)
+}
+
+// setPlanStep points plan artifacts at the plan step, unless a step is already configured.
+func (g *generator) setPlanStep(planStep string) error {
+	raw, ok := g.base["plan_artifacts"]
+	if !ok || planStep == "" {
+		return nil
+	}
+	var planArtifacts map[string]json.RawMessage
+	if err := json.Unmarshal(raw, &planArtifacts); err != nil {
+		return fmt.Errorf("failed to parse plan artifacts configuration: %w", err)
+	}
+	if planArtifacts == nil {
+		planArtifacts = map[string]json.RawMessage{}
+	}
+	if _, ok = planArtifacts["step"]; ok {
+		return nil
+	}
+	var err error
+	if planArtifacts["step"], err = json.Marshal(planStep); err != nil {
+		return fmt.Errorf("failed to marshal plan step: %w", err)
+	}
+	if g.base["plan_artifacts"], err = json.Marshal(planArtifacts); err != nil {
+		return fmt.Errorf("failed to marshal plan artifacts configuration: %w", err)
+	}
+	return nil
+}
+
+// step builds the plugin step that runs mode in a single working directory.
+func (g *generator) step(mode, workingDir, key string, dependsOn []string) (*Step, error) {
+	data := stepData{Workspace: filepath.Base(workingDir), WorkingDir: workingDir, Mode: mode}
+	step := &Step{
+		Key:       key,
+		DependsOn: dependsOn,
+		Agents:    g.options.Agents,
+	}
+	var err error
+	if step.Label, err = execute(g.label, data); err != nil {
+		return nil, err
+	}
+	if g.options.ConcurrencyGroup != "" {
+		if step.ConcurrencyGroup, err = execute(g.group, data); err != nil {
+			return nil, err
+		}
+		step.Concurrency = max(g.options.Concurrency, 1)
+	}
+	plugin, err := StepConfig(g.base, mode, workingDir)
+	if err != nil {
+		return nil, err
+	}
+	step.Plugins = []map[string]json.RawMessage{{g.reference: plugin}}
+	return step, nil
+}
+
+// StepConfig returns the plugin configuration for a step that runs mode in a
+// single working directory, derived from the base plugin configuration.
+func StepConfig(base map[string]json.RawMessage, mode string, workingDir string) (json.RawMessage, error) {
+	config := make(map[string]json.RawMessage, len(base))
+	for k, v := range base {
+		config[k] = v
+	}
+	delete(config, "pipeline")
+	var err error
+	if config["mode"], err = json.Marshal(mode); err != nil {
+		return nil, fmt.Errorf("failed to marshal mode: %w", err)
+	}
+	if config["working"], err = json.Marshal(map[string]string{"directory": workingDir}); err != nil {
+		return nil, fmt.Errorf("failed to marshal working directory: %w", err)
+	}
+	out, err := json.Marshal(config)
+	if err != nil {
+		return nil, fmt.Errorf("failed to marshal step plugin configuration: %w", err)
+	}
+	return out, nil
+}
+
+func execute(tmpl *template.Template, data any) (string, error) {
+	var sb strings.Builder
+	if err := tmpl.Execute(&sb, data); err != nil {
+		return "", fmt.Errorf("failed to execute %s template: %w", tmpl.Name(), err)
+	}
+	return sb.String(), nil
+}
+
+func defaultIfEmpty(value, fallback string) string {
+	if value == "" {
+		return fallback
+	}
+	return value
+}
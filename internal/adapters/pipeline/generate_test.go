@@ -0,0 +1,186 @@
+package pipeline_test
+
+import (
+	"encoding/json"
+	"testing"
+
+	"github.com/cultureamp/terraform-buildkite-plugin/internal/adapters/pipeline"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+const reference = "github.com/cultureamp/terraform-buildkite-plugin#v1.0.0"
+
+func TestGenerate(t *testing.T) {
+	config := json.RawMessage(`{
+		"mode": "pipeline",
+		"working": {"directories": {"parent_directory": "stacks"}},
+		"pipeline": {"mode": "plan"},
+		"outputs": [{"buildkite_annotation": {"template": "plan.tmpl"}}]
+	}`)
+
+	t.Run("one step per working directory", func(t *testing.T) {
+		options := &pipeline.Options{Mode: "plan"}
+		p, err := options.Generate(reference, config, []string{"stacks/network", "stacks/app.prod"}, nil)
+		require.NoError(t, err)
+		require.Len(t, p.Steps, 2)
+
+		step := p.Steps[1]
+		assert.Equal(t, ":terraform: plan app.prod", step.Label)
+		assert.Equal(t, "terraform-stacks-app-prod", step.Key)
+		assert.Empty(t, step.DependsOn)
+		assert.Empty(t, step.ConcurrencyGroup)
+		require.Len(t, step.Plugins, 1)
+
+		var plugin map[string]any
+		require.NoError(t, json.Unmarshal(step.Plugins[0][reference], &plugin))
+		assert.Equal(t, "plan", plugin["mode"])
+		assert.Equal(t, map[string]any{"directory": "stacks/app.prod"}, plugin["working"])
+		assert.NotContains(t, plugin, "pipeline")
+		assert.Contains(t, plugin, "outputs")
+	})
+
+	t.Run("working directories sharing a base name get different keys", func(t *testing.T) {
+		options := &pipeline.Options{Mode: "plan", Key: "review"}
+		p, err := options.Generate(reference, config, []string{"stacks/prod/network", "stacks/dev/network"}, nil)
+		require.NoError(t, err)
+		require.Len(t, p.Steps, 2)
+		assert.Equal(t, "review-stacks-prod-network", p.Steps[0].Key)
+		assert.Equal(t, "review-stacks-dev-network", p.Steps[1].Key)
+	})
+
+	t.Run("approval gates get a key per working directory", func(t *testing.T) {
+		withApproval := json.RawMessage(`{
+			"mode": "pipeline",
+			"pipeline": {"mode": "plan"},
+			"approval": {"key": "prod-apply"},
+			"plan_artifacts": {}
+		}`)
+		options := &pipeline.Options{Mode: "plan"}
+		p, err := options.Generate(reference, withApproval, []string{"stacks/network", "stacks/app"}, nil)
+		require.NoError(t, err)
+		require.Len(t, p.Steps, 2)
+		for i, key := range []string{"prod-apply-stacks-network", "prod-apply-stacks-app"} {
+			var plugin map[string]any
+			require.NoError(t, json.Unmarshal(p.Steps[i].Plugins[0][reference], &plugin))
+			assert.Equal(t, map[string]any{"key": key}, plugin["approval"])
+		}
+	})
+
+	t.Run("duplicate step keys are an error", func(t *testing.T) {
+		options := &pipeline.Options{Mode: "plan"}
+		_, err := options.Generate(reference, config, []string{"stacks/foo.bar", "stacks/foo-bar"}, nil)
+		require.ErrorContains(t, err, "both have step key terraform-stacks-foo-bar")
+	})
+
+	t.Run("templates, agents and dependencies", func(t *testing.T) {
+		options := &pipeline.Options{
+			Mode: "apply",
+			StepOptions: pipeline.StepOptions{
+				Label:            "{{.Mode}} {{.WorkingDir}}",
+				Agents:           map[string]string{"queue": "deploy"},
+				ConcurrencyGroup: "terraform/{{.Workspace}}",
+			},
+		}
+		p, err := options.Generate(
+			reference,
+			config,
+			[]string{"stacks/network", "stacks/app"},
+			map[string][]string{"stacks/app": {"stacks/network"}},
+		)
+		require.NoError(t, err)
+		require.Len(t, p.Steps, 2)
+
+		step := p.Steps[1]
+		assert.Equal(t, "apply stacks/app", step.Label)
+		assert.Equal(t, map[string]string{"queue": "deploy"}, step.Agents)
+		assert.Equal(t, "terraform/app", step.ConcurrencyGroup)
+		assert.Equal(t, 1, step.Concurrency)
+		assert.Equal(t, []string{"terraform-stacks-network"}, step.DependsOn)
+	})
+
+	t.Run("invalid label template", func(t *testing.T) {
+		options := &pipeline.Options{Mode: "plan", StepOptions: pipeline.StepOptions{Label: "{{.Mode"}}
+		_, err := options.Generate(reference, config, []string{"stacks/app"}, nil)
+		require.ErrorContains(t, err, "failed to parse label template")
+	})
+
+	t.Run("invalid plugin configuration", func(t *testing.T) {
+		options := &pipeline.Options{Mode: "plan"}
+		_, err := options.Generate(reference, json.RawMessage(`[]`), []string{"stacks/app"}, nil)
+		require.ErrorContains(t, err, "failed to parse plugin configuration")
+	})
+}
+
+func TestApprovalGenerate(t *testing.T) {
+	config := json.RawMessage(`{
+		"mode": "plan",
+		"working": {"directories": {"parent_directory": "stacks"}},
+		"approval": {},
+		"plan_artifacts": {}
+	}`)
+	changes := []pipeline.Change{
+		{WorkingDir: "stacks/network", Summary: "1 to add, 0 to change, 0 to destroy"},
+		{WorkingDir: "stacks/app", Summary: "0 to add, 2 to change, 1 to destroy"},
+	}
+	dependencies := map[string][]string{
+		"stacks/app": {"stacks/network", "stacks/dns"},
+	}
+
+	t.Run("block step followed by apply steps", func(t *testing.T) {
+		approval := &pipeline.Approval{}
+		p, err := approval.Generate(reference, config, changes, dependencies, "plan-step-id")
+		require.NoError(t, err)
+		require.Len(t, p.Steps, 3)
+
+		block := p.Steps[0]
+		assert.Equal(t, ":terraform: Apply changes?", block.Block)
+		assert.Equal(t, "terraform-apply", block.Key)
+		assert.Equal(t, "Apply Terraform changes to 2 workspaces?\n\n"+
+			"network: 1 to add, 0 to change, 0 to destroy\n"+
+			"app: 0 to add, 2 to change, 1 to destroy", block.Prompt)
+		assert.Empty(t, block.Plugins)
+
+		network := p.Steps[1]
+		assert.Equal(t, "terraform-apply-stacks-network", network.Key)
+		assert.Equal(t, ":terraform: apply network", network.Label)
+		assert.Equal(t, []string{"terraform-apply"}, network.DependsOn)
+
+		app := p.Steps[2]
+		assert.Equal(t, []string{"terraform-apply", "terraform-apply-stacks-network"}, app.DependsOn)
+
+		var plugin map[string]any
+		require.NoError(t, json.Unmarshal(app.Plugins[0][reference], &plugin))
+		assert.Equal(t, "apply", plugin["mode"])
+		assert.Equal(t, map[string]any{"directory": "stacks/app"}, plugin["working"])
+		assert.Equal(t, map[string]any{"step": "plan-step-id"}, plugin["plan_artifacts"])
+		assert.NotContains(t, plugin, "approval")
+	})
+
+	t.Run("custom block and key", func(t *testing.T) {
+		approval := &pipeline.Approval{Block: "Ship it?", Key: "prod-apply"}
+		p, err := approval.Generate(reference, config, changes[:1], nil, "")
+		require.NoError(t, err)
+		require.Len(t, p.Steps, 2)
+		assert.Equal(t, "Ship it?", p.Steps[0].Block)
+		assert.Equal(t, "prod-apply", p.Steps[0].Key)
+		assert.Equal(t, "Apply Terraform changes to 1 workspace?\n\nnetwork: 1 to add, 0 to change, 0 to destroy",
+			p.Steps[0].Prompt)
+		assert.Equal(t, "prod-apply-stacks-network", p.Steps[1].Key)
+
+		var plugin map[string]any
+		require.NoError(t, json.Unmarshal(p.Steps[1].Plugins[0][reference], &plugin))
+		assert.Equal(t, map[string]any{}, plugin["plan_artifacts"])
+	})
+
+	t.Run("configured plan artifacts step is kept", func(t *testing.T) {
+		withStep := json.RawMessage(`{"mode": "plan", "plan_artifacts": {"step": "plan"}}`)
+		approval := &pipeline.Approval{}
+		p, err := approval.Generate(reference, withStep, changes[:1], nil, "plan-step-id")
+		require.NoError(t, err)
+
+		var plugin map[string]any
+		require.NoError(t, json.Unmarshal(p.Steps[1].Plugins[0][reference], &plugin))
+		assert.Equal(t, map[string]any{"step": "plan"}, plugin["plan_artifacts"])
+	})
+}
@@ -0,0 +1,280 @@
+// Package plansummary summarises the resource changes of a Terraform plan
+// for templates, validators and logs.
+package plansummary
+
+import (
+	"fmt"
+	"slices"
+	"strings"
+
+	tfjson "github.com/hashicorp/terraform-json"
+)
+
+// Action is a category of resource change.
+type Action string
+
+const (
+	Create  Action = "create"
+	Update  Action = "update"
+	Delete  Action = "delete"
+	Replace Action = "replace"
+	Read    Action = "read"
+	NoOp    Action = "no-op"
+	Forget  Action = "forget"
+	// Move and Import are counted alongside the change made to the resource,
+	// as a moved or imported resource can also be updated.
+	Move   Action = "move"
+	Import Action = "import"
+)
+
+// Actions returns every category in the order they are reported.
+func Actions() []Action {
+	return []Action{Create, Update, Delete, Replace, Read, NoOp, Move, Import, Forget}
+}
+
+// RootModule is the module key of resources in the root module.
+const RootModule = "root"
+
+// Counts counts resource changes by category.
+type Counts struct {
+	Create  int `json:"create"`
+	Update  int `json:"update"`
+	Delete  int `json:"delete"`
+	Replace int `json:"replace"`
+	Read    int `json:"read"`
+	NoOp    int `json:"no_op"`
+	Move    int `json:"move"`
+	Import  int `json:"import"`
+	Forget  int `json:"forget"`
+}
+
+// Addresses lists the addresses of the resources in each category.
+type Addresses struct {
+	Create  []string `json:"create,omitempty"`
+	Update  []string `json:"update,omitempty"`
+	Delete  []string `json:"delete,omitempty"`
+	Replace []string `json:"replace,omitempty"`
+	Read    []string `json:"read,omitempty"`
+	NoOp    []string `json:"no_op,omitempty"`
+	Move    []string `json:"move,omitempty"`
+	Import  []string `json:"import,omitempty"`
+	Forget  []string `json:"forget,omitempty"`
+}
+
+// Summary summarises the resource changes of a plan.
+//
+// Annotation templates are executed with it as the Summary field of their
+// data, so field names are part of the public template contract.
+type Summary struct {
+	// Counts are the totals across every resource.
+	Counts
+	// Addresses lists the resources in each category.
+	Addresses Addresses `json:"addresses"`
+	// ByType counts the changes of each resource type, such as aws_s3_bucket.
+	ByType map[string]*Counts `json:"by_type"`
+	// ByModule counts the changes of each module address, with resources in
+	// the root module under RootModule.
+	ByModule map[string]*Counts `json:"by_module"`
+}
+
+// New summarises the resource changes of a plan. A nil plan has no changes.
+func New(plan *tfjson.Plan) *Summary {
+	s := &Summary{
+		ByType:   map[string]*Counts{},
+		ByModule: map[string]*Counts{},
+	}
+	if plan == nil {
+		return s
+	}
+	for _, rc := range plan.ResourceChanges {
+		if rc == nil || rc.Change == nil {
+			continue
+		}
+		module := rc.ModuleAddress
+		if module == "" {
+			module = RootModule
+		}
+		for _, action := range actions(rc) {
+			s.add(action, rc.Address)
+			counts(s.ByType, rc.Type).add(action)
+			counts(s.ByModule, module).add(action)
+		}
+	}
+	return s
+}
+
+// actions returns the categories of a resource change.
+func actions(rc *tfjson.ResourceChange) []Action {
+	var categories []Action
+	switch a := rc.Change.Actions; {
+	case slices.Contains(a, tfjson.ActionForget):
+		categories = append(categories, Forget)
+	case a.Replace():
+		categories = append(categories, Replace)
+	case a.Create():
+		categories = append(categories, Create)
+	case a.Update():
+		categories = append(categories, Update)
+	case a.Delete():
+		categories = append(categories, Delete)
+	case a.Read():
+		categories = append(categories, Read)
+	case a.NoOp():
+		categories = append(categories, NoOp)
+	}
+	if rc.PreviousAddress != "" && rc.PreviousAddress != rc.Address {
+		categories = append(categories, Move)
+	}
+	if rc.Change.Importing != nil {
+		categories = append(categories, Import)
+	}
+	return categories
+}
+
+// counts returns the counts for key, adding them when missing.
+func counts(m map[string]*Counts, key string) *Counts {
+	c, ok := m[key]
+	if !ok {
+		c = &Counts{}
+		m[key] = c
+	}
+	return c
+}
+
+// add records a resource in a category.
+func (s *Summary) add(action Action, address string) {
+	s.Counts.add(action)
+	if addresses := s.Addresses.of(action); addresses != nil {
+		*addresses = append(*addresses, address)
+	}
+}
+
+// add counts a resource in a category.
+func (c *Counts) add(action Action) {
+	if n := c.of(action); n != nil {
+		*n++
+	}
+}
+
+// Count returns the number of resources in a category.
+func (c Counts) Count(action Action) int {
+	if n := c.of(action); n != nil {
+		return *n
+	}
+	return 0
+}
+
+// Changes returns the number of resources that are changed by the plan, which
+// excludes reads, no-ops and moved or imported resources that are otherwise unchanged.
+func (c Counts) Changes() int {
+	return c.Create + c.Update + c.Delete + c.Replace + c.Forget
+}
+
+// of returns the count of a category, or nil for an unknown category.
+func (c *Counts) of(action Action) *int {
+	switch action {
+	case Create:
+		return &c.Create
+	case Update:
+		return &c.Update
+	case Delete:
+		return &c.Delete
+	case Replace:
+		return &c.Replace
+	case Read:
+		return &c.Read
+	case NoOp:
+		return &c.NoOp
+	case Move:
+		return &c.Move
+	case Import:
+		return &c.Import
+	case Forget:
+		return &c.Forget
+	default:
+		return nil
+	}
+}
+
+// Of returns the addresses of the resources in a category.
+func (a Addresses) Of(action Action) []string {
+	if addresses := a.of(action); addresses != nil {
+		return *addresses
+	}
+	return nil
+}
+
+// of returns the addresses of a category, or nil for an unknown category.
+func (a *Addresses) of(action Action) *[]string {
+	switch action {
+	case Create:
+		return &a.Create
+	case Update:
+		return &a.Update
+	case Delete:
+		return &a.Delete
+	case Replace:
+		return &a.Replace
+	case Read:
+		return &a.Read
+	case NoOp:
+		return &a.NoOp
+	case Move:
+		return &a.Move
+	case Import:
+		return &a.Import
+	case Forget:
+		return &a.Forget
+	default:
+		return nil
+	}
+}
+
+// String describes the counts of the categories with resources, such as
+// "2 to create, 1 to replace, 3 unchanged".
+func (c Counts) String() string {
+	var parts []string
+	for _, action := range Actions() {
+		switch n := c.Count(action); {
+		case n == 0:
+		case action == NoOp:
+			parts = append(parts, fmt.Sprintf("%d unchanged", n))
+		default:
+			parts = append(parts, fmt.Sprintf("%d to %s", n, action))
+		}
+	}
+	if len(parts) == 0 {
+		return "no changes"
+	}
+	return strings.Join(parts, ", ")
+}
+
+// Format renders the summary as text for the job log, listing the addresses
+// in each category other than no-op followed by the counts of each module and
+// resource type.
+func (s *Summary) Format() string {
+	var b strings.Builder
+	fmt.Fprintf(&b, "Plan summary: %s\n", s.Counts)
+	for _, action := range Actions() {
+		if action == NoOp {
+			continue
+		}
+		for _, address := range s.Addresses.Of(action) {
+			fmt.Fprintf(&b, "  %-8s %s\n", action, address)
+		}
+	}
+	for _, group := range []struct {
+		name   string
+		counts map[string]*Counts
+	}{{"module", s.ByModule}, {"type", s.ByType}} {
+		keys := make([]string, 0, len(group.counts))
+		for key := range group.counts {
+			keys = append(keys, key)
+		}
+		slices.Sort(keys)
+		for _, key := range keys {
+			fmt.Fprintf(&b, "  %s %s: %s\n", group.name, key, group.counts[key])
+		}
+	}
+	return b.String()
+}
@@ -0,0 +1,112 @@
+package plansummary_test
+
+import (
+	"encoding/json"
+	"testing"
+
+	"github.com/cultureamp/terraform-buildkite-plugin/internal/adapters/plansummary"
+	tfjson "github.com/hashicorp/terraform-json"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func change(address, module, resourceType string, actions ...tfjson.Action) *tfjson.ResourceChange {
+	return &tfjson.ResourceChange{
+		Address:       address,
+		ModuleAddress: module,
+		Type:          resourceType,
+		Change:        &tfjson.Change{Actions: actions},
+	}
+}
+
+func TestNew(t *testing.T) {
+	moved := change("aws_s3_bucket.archive", "", "aws_s3_bucket", tfjson.ActionUpdate)
+	moved.PreviousAddress = "aws_s3_bucket.logs"
+	imported := change("aws_iam_role.app", "", "aws_iam_role", tfjson.ActionNoop)
+	imported.Change.Importing = &tfjson.Importing{ID: "app"}
+	plan := &tfjson.Plan{
+		ResourceChanges: []*tfjson.ResourceChange{
+			change("aws_s3_bucket.assets", "", "aws_s3_bucket", tfjson.ActionCreate),
+			change("module.vpc.aws_vpc.main", "module.vpc", "aws_vpc", tfjson.ActionDelete, tfjson.ActionCreate),
+			change("module.vpc.aws_subnet.a", "module.vpc", "aws_subnet", tfjson.ActionDelete),
+			change("data.aws_caller_identity.current", "", "aws_caller_identity", tfjson.ActionRead),
+			change("aws_sqs_queue.jobs", "", "aws_sqs_queue", tfjson.ActionForget),
+			moved,
+			imported,
+			{Address: "aws_sns_topic.alerts"},
+			nil,
+		},
+	}
+
+	summary := plansummary.New(plan)
+	assert.Equal(t, plansummary.Counts{
+		Create: 1, Update: 1, Delete: 1, Replace: 1, Read: 1, NoOp: 1, Move: 1, Import: 1, Forget: 1,
+	}, summary.Counts)
+	assert.Equal(t, plansummary.Addresses{
+		Create:  []string{"aws_s3_bucket.assets"},
+		Update:  []string{"aws_s3_bucket.archive"},
+		Delete:  []string{"module.vpc.aws_subnet.a"},
+		Replace: []string{"module.vpc.aws_vpc.main"},
+		Read:    []string{"data.aws_caller_identity.current"},
+		NoOp:    []string{"aws_iam_role.app"},
+		Move:    []string{"aws_s3_bucket.archive"},
+		Import:  []string{"aws_iam_role.app"},
+		Forget:  []string{"aws_sqs_queue.jobs"},
+	}, summary.Addresses)
+	assert.Equal(t, &plansummary.Counts{Create: 1, Update: 1, Move: 1}, summary.ByType["aws_s3_bucket"])
+	assert.Equal(t, &plansummary.Counts{Delete: 1, Replace: 1}, summary.ByModule["module.vpc"])
+	assert.Equal(t, 3, summary.ByModule[plansummary.RootModule].Changes())
+	assert.Equal(t, 5, summary.Changes())
+	assert.Equal(t, []string{"module.vpc.aws_vpc.main"}, summary.Addresses.Of(plansummary.Replace))
+	assert.Equal(t, 1, summary.Count(plansummary.Import))
+}
+
+func TestNew_NilPlan(t *testing.T) {
+	summary := plansummary.New(nil)
+	assert.Zero(t, summary.Counts)
+	assert.Equal(t, "no changes", summary.String())
+}
+
+func TestSummary_String(t *testing.T) {
+	plan := &tfjson.Plan{ResourceChanges: []*tfjson.ResourceChange{
+		change("aws_s3_bucket.assets", "", "aws_s3_bucket", tfjson.ActionCreate),
+		change("aws_s3_bucket.logs", "", "aws_s3_bucket", tfjson.ActionCreate),
+		change("module.vpc.aws_vpc.main", "module.vpc", "aws_vpc", tfjson.ActionCreate, tfjson.ActionDelete),
+		change("aws_iam_role.app", "", "aws_iam_role", tfjson.ActionNoop),
+	}}
+
+	summary := plansummary.New(plan)
+	assert.Equal(t, "2 to create, 1 to replace, 1 unchanged", summary.String())
+	assert.Equal(t, `Plan summary: 2 to create, 1 to replace, 1 unchanged
+  create   aws_s3_bucket.assets
+  create   aws_s3_bucket.logs
+  replace  module.vpc.aws_vpc.main
+  module module.vpc: 1 to replace
+  module root: 2 to create, 1 unchanged
+  type aws_iam_role: 1 unchanged
+  type aws_s3_bucket: 2 to create
+  type aws_vpc: 1 to replace
+`, summary.Format())
+}
+
+func TestSummary_JSON(t *testing.T) {
+	plan := &tfjson.Plan{ResourceChanges: []*tfjson.ResourceChange{
+		change("aws_s3_bucket.assets", "", "aws_s3_bucket", tfjson.ActionCreate),
+	}}
+
+	data, err := json.Marshal(plansummary.New(plan))
+	require.NoError(t, err)
+	assert.JSONEq(t, `{
+		"create": 1, "update": 0, "delete": 0, "replace": 0, "read": 0,
+		"no_op": 0, "move": 0, "import": 0, "forget": 0,
+		"addresses": {"create": ["aws_s3_bucket.assets"]},
+		"by_type": {"aws_s3_bucket": {
+			"create": 1, "update": 0, "delete": 0, "replace": 0, "read": 0,
+			"no_op": 0, "move": 0, "import": 0, "forget": 0
+		}},
+		"by_module": {"root": {
+			"create": 1, "update": 0, "delete": 0, "replace": 0, "read": 0,
+			"no_op": 0, "move": 0, "import": 0, "forget": 0
+		}}
+	}`, string(data))
+}
@@ -0,0 +1,193 @@
+// Package runner provides discovery and invocation of the Terraform
+// compatible binary used to process working directories, either Terraform
+// or OpenTofu.
+package runner
+
+import (
+	"context"
+	"fmt"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"sync"
+
+	"github.com/hashicorp/go-version"
+	"github.com/hashicorp/terraform-exec/tfexec"
+	"github.com/rs/zerolog/log"
+)
+
+// Flavour identifies a Terraform compatible binary.
+type Flavour string
+
+const (
+	Terraform Flavour = "terraform"
+	OpenTofu  Flavour = "tofu"
+)
+
+// Runner creates executors for a Terraform compatible binary.
+type Runner interface {
+	// Flavour returns the flavour of the binary.
+	Flavour() Flavour
+	// ExecPath returns the path of the default binary, empty when every binary
+	// comes from a versions directory.
+	ExecPath() string
+	// New returns an executor for a working directory.
+	New(ctx context.Context, workingDir string) (*tfexec.Terraform, error)
+	// Capabilities returns the features supported by the binary, detected once
+	// from its version using tf.
+	Capabilities(ctx context.Context, tf *tfexec.Terraform) (*Capabilities, error)
+}
+
+type runner struct {
+	flavour      Flavour
+	execPath     string
+	versionsDir  string
+	mu           sync.Mutex
+	capabilities map[string]*Capabilities // keyed by exec path
+}
+
+// Option configures a Runner.
+type Option func(*runner)
+
+// WithVersionsDir picks the binary for each working directory from a directory
+// of versioned binaries laid out as <dir>/<version>/<binary>, using the newest
+// version that satisfies the working directory's version requirements.
+func WithVersionsDir(dir string) Option {
+	return func(r *runner) {
+		r.versionsDir = dir
+	}
+}
+
+// New creates a Runner for flavour. When execPath is empty the binary for the
+// flavour is looked up in PATH, unless a versions directory is configured.
+func New(ctx context.Context, flavour Flavour, execPath string, opts ...Option) (Runner, error) {
+	if flavour == "" {
+		flavour = Terraform
+	}
+	r := &runner{flavour: flavour, execPath: execPath, capabilities: map[string]*Capabilities{}}
+	for _, opt := range opts {
+		opt(r)
+	}
+	if r.execPath == "" && r.versionsDir == "" {
+		binary := string(flavour)
+		log.Ctx(ctx).Debug().Str("binary", binary).Msg("exec path not configured, attempting to find binary in PATH")
+		p, err := exec.LookPath(binary)
+		if err != nil {
+			log.Ctx(ctx).Error().Err(err).Str("PATH", os.Getenv("PATH")).Str("binary", binary).Msg("binary not found in PATH")
+			return nil, fmt.Errorf("%s binary not found in PATH: %w", binary, err)
+		}
+		log.Ctx(ctx).Debug().Str("exec_path", p).Msg("found binary in PATH")
+		r.execPath = p
+	}
+	return r, nil
+}
+
+func (r *runner) Flavour() Flavour {
+	return r.flavour
+}
+
+func (r *runner) ExecPath() string {
+	return r.execPath
+}
+
+func (r *runner) New(ctx context.Context, workingDir string) (*tfexec.Terraform, error) {
+	execPath := r.execPath
+	if r.versionsDir != "" {
+		var err error
+		if execPath, err = r.resolve(workingDir); err != nil {
+			return nil, err
+		}
+	}
+	log.Ctx(ctx).Debug().
+		Str("working_dir", workingDir).
+		Str("flavour", string(r.flavour)).
+		Str("exec_path", execPath).
+		Msg("creating executor")
+	tf, err := tfexec.NewTerraform(workingDir, execPath)
+	if err != nil {
+		return nil, fmt.Errorf("failed to create %s runner: %w", r.flavour, err)
+	}
+	return tf, nil
+}
+
+func (r *runner) Capabilities(ctx context.Context, tf *tfexec.Terraform) (*Capabilities, error) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	if caps, ok := r.capabilities[tf.ExecPath()]; ok {
+		return caps, nil
+	}
+	v, _, err := tf.Version(ctx, false)
+	if err != nil {
+		return nil, fmt.Errorf("failed to determine %s version: %w", r.flavour, err)
+	}
+	caps := Detect(r.flavour, v)
+	r.capabilities[tf.ExecPath()] = caps
+	log.Ctx(ctx).Debug().Interface("capabilities", caps).Msg("detected capabilities")
+	return caps, nil
+}
+
+// resolve returns the binary in the versions directory for a working directory.
+// Without version requirements the configured binary is used, falling back to
+// the newest installed version.
+func (r *runner) resolve(workingDir string) (string, error) {
+	requirements, err := RequiredVersions(workingDir, r.flavour)
+	if err != nil {
+		return "", err
+	}
+	if len(requirements) == 0 && r.execPath != "" {
+		return r.execPath, nil
+	}
+	versions, err := installedVersions(r.versionsDir, r.flavour)
+	if err != nil {
+		return "", err
+	}
+	for _, v := range versions {
+		if satisfies(v, requirements) {
+			return filepath.Join(r.versionsDir, v.Original(), string(r.flavour)), nil
+		}
+	}
+	return "", fmt.Errorf("no %s version in %s satisfies the version requirements of %s",
+		r.flavour, r.versionsDir, workingDir)
+}
+
+// Capabilities describes version specific features of a binary.
+type Capabilities struct {
+	Flavour Flavour
+	Version *version.Version
+	// PlanJSON reports whether plans can be shown as JSON.
+	PlanJSON bool
+	// ResourceDrift reports whether JSON plans include resource drift.
+	ResourceDrift bool
+	// Replace reports whether plans support -replace.
+	Replace bool
+	// RefreshOnly reports whether plans support -refresh-only.
+	RefreshOnly bool
+	// JSONOutput reports whether plan and apply support a -json UI stream.
+	JSONOutput bool
+}
+
+//nolint:gochecknoglobals // minimum versions of each capability
+var (
+	planJSONVersion      = version.Must(version.NewVersion("0.12.0"))
+	replaceVersion       = version.Must(version.NewVersion("0.15.2"))
+	jsonOutputVersion    = version.Must(version.NewVersion("0.15.3"))
+	refreshOnlyVersion   = version.Must(version.NewVersion("0.15.4"))
+	resourceDriftVersion = version.Must(version.NewVersion("0.15.4"))
+)
+
+// Detect returns the capabilities of a binary from its flavour and version.
+//
+// OpenTofu forked from Terraform 1.6 and shares its version lineage, so the
+// same minimum versions apply to both flavours.
+func Detect(flavour Flavour, v *version.Version) *Capabilities {
+	core := v.Core()
+	return &Capabilities{
+		Flavour:       flavour,
+		Version:       v,
+		PlanJSON:      core.GreaterThanOrEqual(planJSONVersion),
+		ResourceDrift: core.GreaterThanOrEqual(resourceDriftVersion),
+		Replace:       core.GreaterThanOrEqual(replaceVersion),
+		RefreshOnly:   core.GreaterThanOrEqual(refreshOnlyVersion),
+		JSONOutput:    core.GreaterThanOrEqual(jsonOutputVersion),
+	}
+}
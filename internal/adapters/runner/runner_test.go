@@ -0,0 +1,94 @@
+package runner_test
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/hashicorp/go-version"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/cultureamp/terraform-buildkite-plugin/internal/adapters/runner"
+)
+
+func TestNew(t *testing.T) {
+	bin := t.TempDir()
+	for _, name := range []string{"terraform", "tofu"} {
+		require.NoError(t, os.WriteFile(filepath.Join(bin, name), []byte("#!/bin/sh\n"), 0o700))
+	}
+	t.Setenv("PATH", bin)
+
+	t.Run("discovers each flavour in PATH", func(t *testing.T) {
+		for _, flavour := range []runner.Flavour{runner.Terraform, runner.OpenTofu} {
+			r, err := runner.New(t.Context(), flavour, "")
+			require.NoError(t, err)
+			assert.Equal(t, flavour, r.Flavour())
+			assert.Equal(t, filepath.Join(bin, string(flavour)), r.ExecPath())
+		}
+	})
+
+	t.Run("defaults to terraform", func(t *testing.T) {
+		r, err := runner.New(t.Context(), "", "")
+		require.NoError(t, err)
+		assert.Equal(t, runner.Terraform, r.Flavour())
+	})
+
+	t.Run("configured exec path", func(t *testing.T) {
+		r, err := runner.New(t.Context(), runner.OpenTofu, "/opt/tofu/bin/tofu")
+		require.NoError(t, err)
+		assert.Equal(t, "/opt/tofu/bin/tofu", r.ExecPath())
+	})
+
+	t.Run("binary not found", func(t *testing.T) {
+		t.Setenv("PATH", t.TempDir())
+		_, err := runner.New(t.Context(), runner.OpenTofu, "")
+		require.ErrorContains(t, err, "tofu binary not found in PATH")
+	})
+
+	t.Run("executor for a working directory", func(t *testing.T) {
+		r, err := runner.New(t.Context(), runner.OpenTofu, "")
+		require.NoError(t, err)
+		tf, err := r.New(t.Context(), t.TempDir())
+		require.NoError(t, err)
+		assert.Equal(t, filepath.Join(bin, "tofu"), tf.ExecPath())
+	})
+}
+
+func TestDetect(t *testing.T) {
+	cases := []struct {
+		flavour runner.Flavour
+		version string
+		want    runner.Capabilities
+	}{
+		{flavour: runner.Terraform, version: "0.11.14", want: runner.Capabilities{}},
+		{flavour: runner.Terraform, version: "0.12.31", want: runner.Capabilities{PlanJSON: true}},
+		{
+			flavour: runner.Terraform,
+			version: "0.15.3",
+			want:    runner.Capabilities{PlanJSON: true, Replace: true, JSONOutput: true},
+		},
+		{
+			flavour: runner.Terraform,
+			version: "1.9.0-beta1",
+			want: runner.Capabilities{
+				PlanJSON: true, ResourceDrift: true, Replace: true, RefreshOnly: true, JSONOutput: true,
+			},
+		},
+		{
+			flavour: runner.OpenTofu,
+			version: "1.6.0",
+			want: runner.Capabilities{
+				PlanJSON: true, ResourceDrift: true, Replace: true, RefreshOnly: true, JSONOutput: true,
+			},
+		},
+	}
+	for _, tc := range cases {
+		t.Run(string(tc.flavour)+" "+tc.version, func(t *testing.T) {
+			v := version.Must(version.NewVersion(tc.version))
+			tc.want.Flavour = tc.flavour
+			tc.want.Version = v
+			assert.Equal(t, &tc.want, runner.Detect(tc.flavour, v))
+		})
+	}
+}
@@ -0,0 +1,210 @@
+package runner
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"regexp"
+	"slices"
+	"strings"
+
+	"github.com/hashicorp/go-version"
+
+	"github.com/cultureamp/terraform-buildkite-plugin/internal/common"
+)
+
+// requiredVersionPattern matches a required_version argument in a .tf file.
+var requiredVersionPattern = regexp.MustCompile(`(?m)^\s*required_version\s*=\s*"([^"]*)"`)
+
+// blockCommentPattern matches a /* */ comment in a .tf file.
+var blockCommentPattern = regexp.MustCompile(`(?s)/\*.*?\*/`)
+
+// VersionRequirement is a version constraint and the file it was read from.
+type VersionRequirement struct {
+	Source     string
+	Constraint version.Constraints
+}
+
+// versionFile returns the version file read for a flavour.
+func versionFile(flavour Flavour) string {
+	if flavour == OpenTofu {
+		return ".opentofu-version"
+	}
+	return ".terraform-version"
+}
+
+// RequiredVersions returns the version requirements of a working directory.
+//
+// Requirements are read from required_version in the root module's .tf and
+// .tf.json files, and from the nearest version file for the flavour
+// (.terraform-version or .opentofu-version) in the working directory or its
+// parents, up to the checkout (BUILDKITE_BUILD_CHECKOUT_PATH, or the first
+// directory containing .git). Version files naming "latest" or "min-required" are
+// resolved by version managers rather than constraints, so they are ignored.
+func RequiredVersions(workingDir string, flavour Flavour) ([]VersionRequirement, error) {
+	var requirements []VersionRequirement
+	files, err := filepath.Glob(filepath.Join(workingDir, "*.tf"))
+	if err != nil {
+		return nil, fmt.Errorf("failed to list configuration files: %w", err)
+	}
+	jsonFiles, err := filepath.Glob(filepath.Join(workingDir, "*.tf.json"))
+	if err != nil {
+		return nil, fmt.Errorf("failed to list configuration files: %w", err)
+	}
+	for _, file := range append(files, jsonFiles...) {
+		constraints, readErr := readRequiredVersions(file)
+		if readErr != nil {
+			return nil, readErr
+		}
+		for _, c := range constraints {
+			requirement, parseErr := newRequirement(file, c)
+			if parseErr != nil {
+				return nil, parseErr
+			}
+			requirements = append(requirements, requirement)
+		}
+	}
+	file, content, err := findVersionFile(workingDir, versionFile(flavour))
+	if err != nil || file == "" {
+		return requirements, err
+	}
+	if content == "" || strings.HasPrefix(content, "latest") || content == "min-required" {
+		return requirements, nil
+	}
+	requirement, err := newRequirement(file, strings.TrimPrefix(content, "v"))
+	if err != nil {
+		return nil, err
+	}
+	return append(requirements, requirement), nil
+}
+
+func newRequirement(source, constraint string) (VersionRequirement, error) {
+	c, err := version.NewConstraint(constraint)
+	if err != nil {
+		return VersionRequirement{}, fmt.Errorf("invalid version constraint %q in %s: %w", constraint, source, err)
+	}
+	return VersionRequirement{Source: source, Constraint: c}, nil
+}
+
+// readRequiredVersions returns the required_version constraints declared in a configuration file.
+func readRequiredVersions(file string) ([]string, error) {
+	data, err := os.ReadFile(file)
+	if err != nil {
+		return nil, fmt.Errorf("failed to read %s: %w", file, err)
+	}
+	if !strings.HasSuffix(file, ".json") {
+		var constraints []string
+		data = blockCommentPattern.ReplaceAll(data, []byte(" "))
+		for _, match := range requiredVersionPattern.FindAllSubmatch(data, -1) {
+			constraints = append(constraints, string(match[1]))
+		}
+		return constraints, nil
+	}
+	var config struct {
+		Terraform json.RawMessage `json:"terraform"`
+	}
+	if err = json.Unmarshal(data, &config); err != nil {
+		return nil, fmt.Errorf("failed to parse %s: %w", file, err)
+	}
+	if len(config.Terraform) == 0 {
+		return nil, nil
+	}
+	// the terraform block is either an object or a list of objects
+	var blocks []struct {
+		RequiredVersion string `json:"required_version"`
+	}
+	if config.Terraform[0] != '[' {
+		config.Terraform = append(append(json.RawMessage{'['}, config.Terraform...), ']')
+	}
+	if err = json.Unmarshal(config.Terraform, &blocks); err != nil {
+		return nil, fmt.Errorf("failed to parse terraform block in %s: %w", file, err)
+	}
+	var constraints []string
+	for _, block := range blocks {
+		if block.RequiredVersion != "" {
+			constraints = append(constraints, block.RequiredVersion)
+		}
+	}
+	return constraints, nil
+}
+
+// findVersionFile returns the path and trimmed first line of the nearest
+// version file named name in dir or its parents, stopping at the checkout
+// root so that files outside the repository are never read.
+func findVersionFile(dir, name string) (string, string, error) {
+	dir, err := filepath.Abs(dir)
+	if err != nil {
+		return "", "", fmt.Errorf("failed to resolve %s: %w", dir, err)
+	}
+	root := common.FetchEnv("BUILDKITE_BUILD_CHECKOUT_PATH", "")
+	if root != "" {
+		if root, err = filepath.Abs(root); err != nil {
+			return "", "", fmt.Errorf("failed to resolve checkout path %s: %w", root, err)
+		}
+	}
+	for {
+		file := filepath.Join(dir, name)
+		data, readErr := os.ReadFile(file)
+		switch {
+		case readErr == nil:
+			line, _, _ := strings.Cut(string(data), "\n")
+			return file, strings.TrimSpace(line), nil
+		case !errors.Is(readErr, os.ErrNotExist):
+			return "", "", fmt.Errorf("failed to read %s: %w", file, readErr)
+		}
+		if dir == root || isRepositoryRoot(dir) {
+			return "", "", nil
+		}
+		parent := filepath.Dir(dir)
+		if parent == dir {
+			return "", "", nil
+		}
+		dir = parent
+	}
+}
+
+// isRepositoryRoot reports whether dir contains a .git directory or file.
+func isRepositoryRoot(dir string) bool {
+	_, err := os.Stat(filepath.Join(dir, ".git"))
+	return err == nil
+}
+
+// CheckVersion returns an error describing every requirement v does not satisfy.
+func CheckVersion(v *version.Version, requirements []VersionRequirement) error {
+	var errs []error
+	for _, requirement := range requirements {
+		if !requirement.Constraint.Check(v) {
+			errs = append(errs, fmt.Errorf("version %s does not satisfy %q required by %s",
+				v, requirement.Constraint, requirement.Source))
+		}
+	}
+	return errors.Join(errs...)
+}
+
+// satisfies reports whether v satisfies every requirement.
+func satisfies(v *version.Version, requirements []VersionRequirement) bool {
+	return CheckVersion(v, requirements) == nil
+}
+
+// installedVersions returns the versions installed in a directory of
+// versioned binaries laid out as <dir>/<version>/<binary>, newest first.
+func installedVersions(dir string, flavour Flavour) ([]*version.Version, error) {
+	entries, err := os.ReadDir(dir)
+	if err != nil {
+		return nil, fmt.Errorf("failed to read versions directory: %w", err)
+	}
+	var versions []*version.Version
+	for _, entry := range entries {
+		v, parseErr := version.NewVersion(entry.Name())
+		if parseErr != nil {
+			continue
+		}
+		if _, statErr := os.Stat(filepath.Join(dir, entry.Name(), string(flavour))); statErr == nil {
+			versions = append(versions, v)
+		}
+	}
+	slices.SortFunc(versions, func(a, b *version.Version) int { return b.Compare(a) })
+	return versions, nil
+}
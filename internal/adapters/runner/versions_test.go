@@ -0,0 +1,144 @@
+package runner_test
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/hashicorp/go-version"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/cultureamp/terraform-buildkite-plugin/internal/adapters/runner"
+)
+
+func writeFile(t *testing.T, path, content string) {
+	t.Helper()
+	require.NoError(t, os.MkdirAll(filepath.Dir(path), 0o700))
+	require.NoError(t, os.WriteFile(path, []byte(content), 0o700))
+}
+
+func sources(requirements []runner.VersionRequirement) []string {
+	var s []string
+	for _, r := range requirements {
+		s = append(s, filepath.Base(r.Source)+" "+r.Constraint.String())
+	}
+	return s
+}
+
+func TestRequiredVersions(t *testing.T) {
+	t.Run("configuration and version files", func(t *testing.T) {
+		root := t.TempDir()
+		workingDir := filepath.Join(root, "stacks", "app")
+		writeFile(t, filepath.Join(workingDir, "main.tf"), "terraform {\n  required_version = \">= 1.5.0\"\n}\n")
+		writeFile(t, filepath.Join(workingDir, "cdk.tf.json"), `{"terraform": {"required_version": "< 2.0.0"}}`)
+		writeFile(t, filepath.Join(workingDir, "extra.tf.json"), `{"terraform": [{"required_version": "~> 1.6"}]}`)
+		writeFile(t, filepath.Join(root, ".terraform-version"), "1.6.2\n")
+		writeFile(t, filepath.Join(root, ".opentofu-version"), "v1.7.0\n")
+
+		requirements, err := runner.RequiredVersions(workingDir, runner.Terraform)
+		require.NoError(t, err)
+		assert.Equal(t, []string{
+			"main.tf >= 1.5.0",
+			"cdk.tf.json < 2.0.0",
+			"extra.tf.json ~> 1.6",
+			".terraform-version 1.6.2",
+		}, sources(requirements))
+
+		requirements, err = runner.RequiredVersions(workingDir, runner.OpenTofu)
+		require.NoError(t, err)
+		assert.Contains(t, sources(requirements), ".opentofu-version 1.7.0")
+	})
+
+	t.Run("version manager keywords are ignored", func(t *testing.T) {
+		workingDir := t.TempDir()
+		writeFile(t, filepath.Join(workingDir, ".terraform-version"), "latest:^1.5")
+
+		requirements, err := runner.RequiredVersions(workingDir, runner.Terraform)
+		require.NoError(t, err)
+		assert.Empty(t, requirements)
+	})
+
+	t.Run("commented out requirements are ignored", func(t *testing.T) {
+		workingDir := t.TempDir()
+		writeFile(t, filepath.Join(workingDir, "main.tf"), "terraform {\n"+
+			"  /*\n  required_version = \"< 1.0.0\"\n  */\n"+
+			"  # required_version = \"< 1.1.0\"\n"+
+			"  required_version = \">= 1.5.0\"\n}\n")
+
+		requirements, err := runner.RequiredVersions(workingDir, runner.Terraform)
+		require.NoError(t, err)
+		assert.Equal(t, []string{"main.tf >= 1.5.0"}, sources(requirements))
+	})
+
+	t.Run("version files outside the checkout are ignored", func(t *testing.T) {
+		root := t.TempDir()
+		checkout := filepath.Join(root, "checkout")
+		workingDir := filepath.Join(checkout, "stacks", "app")
+		writeFile(t, filepath.Join(workingDir, "main.tf"), "")
+		writeFile(t, filepath.Join(root, ".terraform-version"), "1.6.2\n")
+
+		t.Setenv("BUILDKITE_BUILD_CHECKOUT_PATH", checkout)
+		requirements, err := runner.RequiredVersions(workingDir, runner.Terraform)
+		require.NoError(t, err)
+		assert.Empty(t, requirements)
+
+		t.Setenv("BUILDKITE_BUILD_CHECKOUT_PATH", "")
+		require.NoError(t, os.Mkdir(filepath.Join(checkout, ".git"), 0o700))
+		requirements, err = runner.RequiredVersions(workingDir, runner.Terraform)
+		require.NoError(t, err)
+		assert.Empty(t, requirements)
+	})
+
+	t.Run("invalid constraint", func(t *testing.T) {
+		workingDir := t.TempDir()
+		writeFile(t, filepath.Join(workingDir, "main.tf"), "terraform {\n  required_version = \"not a version\"\n}\n")
+
+		_, err := runner.RequiredVersions(workingDir, runner.Terraform)
+		require.ErrorContains(t, err, "invalid version constraint")
+	})
+}
+
+func TestCheckVersion(t *testing.T) {
+	workingDir := t.TempDir()
+	writeFile(t, filepath.Join(workingDir, "main.tf"), "terraform {\n  required_version = \">= 1.5.0, < 1.7.0\"\n}\n")
+	requirements, err := runner.RequiredVersions(workingDir, runner.Terraform)
+	require.NoError(t, err)
+
+	require.NoError(t, runner.CheckVersion(version.Must(version.NewVersion("1.6.0")), requirements))
+	err = runner.CheckVersion(version.Must(version.NewVersion("1.8.0")), requirements)
+	require.ErrorContains(t, err, "version 1.8.0 does not satisfy")
+	assert.ErrorContains(t, err, "main.tf")
+}
+
+func TestVersionsDir(t *testing.T) {
+	versionsDir := t.TempDir()
+	for _, v := range []string{"1.5.7", "1.6.6", "1.9.0"} {
+		writeFile(t, filepath.Join(versionsDir, v, "terraform"), "#!/bin/sh\n")
+	}
+	t.Setenv("PATH", t.TempDir())
+
+	r, err := runner.New(t.Context(), runner.Terraform, "", runner.WithVersionsDir(versionsDir))
+	require.NoError(t, err)
+
+	t.Run("newest matching version", func(t *testing.T) {
+		workingDir := t.TempDir()
+		writeFile(t, filepath.Join(workingDir, "main.tf"), "terraform {\n  required_version = \"~> 1.6.0\"\n}\n")
+		tf, err := r.New(t.Context(), workingDir)
+		require.NoError(t, err)
+		assert.Equal(t, filepath.Join(versionsDir, "1.6.6", "terraform"), tf.ExecPath())
+	})
+
+	t.Run("newest version without requirements", func(t *testing.T) {
+		tf, err := r.New(t.Context(), t.TempDir())
+		require.NoError(t, err)
+		assert.Equal(t, filepath.Join(versionsDir, "1.9.0", "terraform"), tf.ExecPath())
+	})
+
+	t.Run("no matching version", func(t *testing.T) {
+		workingDir := t.TempDir()
+		writeFile(t, filepath.Join(workingDir, ".terraform-version"), "1.4.0")
+		_, err := r.New(t.Context(), workingDir)
+		require.ErrorContains(t, err, "no terraform version")
+	})
+}
@@ -7,10 +7,66 @@ type InitOptions struct {
 	PluginDir *string `json:"plugin_dir"  validate:"omitempty,dir"     jsonschema:"title=plugin_dir,description=Directory containing Terraform plugins"`
 	// GetPlugins indicates whether to automatically download Terraform plugins.
 	Get *bool `json:"get_plugins" validate:"omitempty,boolean" jsonschema:"title=get_plugins,description=Whether to automatically download Terraform plugins"`
+	// BackendConfig are backend configuration key/value pairs. Values are Go templates.
+	BackendConfig map[string]string `json:"backend_config,omitempty" jsonschema:"title=backend_config,description=Backend configuration key/value pairs where values are Go templates with access to .Workspace and .WorkingDir and .Env"`
+	// BackendConfigFiles are backend configuration files, relative to each working directory unless absolute.
+	// Paths are Go templates.
+	BackendConfigFiles []string `json:"backend_config_files,omitempty" jsonschema:"title=backend_config_files,description=Backend configuration files relative to each working directory unless absolute where paths are Go templates"`
+	// Backend indicates whether to configure the backend. Defaults to true.
+	Backend *bool `json:"backend,omitempty" jsonschema:"title=backend,description=Whether to configure the backend (defaults to true)"`
+	// Reconfigure ignores any existing backend configuration.
+	Reconfigure *bool `json:"reconfigure,omitempty" jsonschema:"title=reconfigure,description=Reconfigure the backend ignoring any saved configuration"`
+	// Upgrade upgrades modules and providers to the newest allowed versions.
+	Upgrade *bool `json:"upgrade,omitempty" jsonschema:"title=upgrade,description=Upgrade modules and providers to the newest allowed versions"`
+	// Lock indicates whether to hold a state lock during backend migration.
+	Lock *bool `json:"lock,omitempty" jsonschema:"title=lock,description=Whether to hold a state lock during backend migration (defaults to true)"`
+	// LockTimeout is how long to retry acquiring a state lock, such as "30s".
+	LockTimeout string `json:"lock_timeout,omitempty" jsonschema:"title=lock_timeout,description=Duration to retry acquiring a state lock such as 30s"`
 }
 type Options struct {
+	// Flavour is the Terraform compatible binary to run, either "terraform" or "tofu". Defaults to "terraform".
+	Flavour string `json:"flavour,omitempty" validate:"omitempty,oneof=terraform tofu" jsonschema:"title=flavour,description=Terraform compatible binary to run (terraform or tofu and defaults to terraform)"`
 	// ExecPath specifies the path to the Terraform executable.
-	ExecPath *string `json:"exec_path,omitempty"    validate:"omitempty,file" jsonschema:"title=exec_path,description=Path to the Terraform executable, defaults to a lookup in the PATH environment variable"`
+	ExecPath *string `json:"exec_path,omitempty"    validate:"omitempty,file" jsonschema:"title=exec_path,description=Path to the Terraform executable, defaults to a lookup of the flavour binary in the PATH environment variable"`
+	// VersionsDir is a directory of versioned binaries laid out as <versions_dir>/<version>/<flavour>.
+	// When set, each working directory runs the newest binary satisfying its version requirements.
+	VersionsDir *string `json:"versions_dir,omitempty" validate:"omitempty,dir" jsonschema:"title=versions_dir,description=Directory of versioned binaries laid out as <versions_dir>/<version>/<flavour> to pick each working directory's binary from"`
 	// InitOptions contains options for running `terraform init`.
 	InitOptions *InitOptions `json:"init_options,omitempty"                           jsonschema:"title=init,description=Options for the terraform init command"`
+	// PlanOptions contains options for running `terraform plan`, also used when applying.
+	PlanOptions *PlanOptions `json:"plan_options,omitempty" jsonschema:"title=plan_options,description=Options for the terraform plan command also used when applying"`
+	// Workspace selects, or creates, a Terraform CLI workspace after `terraform init`.
+	Workspace *Workspace `json:"workspace,omitempty" jsonschema:"title=workspace,description=Terraform CLI workspace to select or create in each working directory"`
+	// JSONOutput runs plan and apply with -json, rendering the UI stream as
+	// per-resource progress lines and collecting its diagnostics.
+	JSONOutput bool `json:"json_output,omitempty" jsonschema:"title=json_output,description=Run plan and apply with -json and log per-resource progress and diagnostics instead of the plain output"`
+}
+
+// Workspace configures the Terraform CLI workspace used in each working directory.
+type Workspace struct {
+	// Name is a Go template for the workspace name.
+	// The template has access to .Workspace (the working directory name), .WorkingDir and .Env.
+	Name string `json:"name" validate:"required" jsonschema:"title=name,description=Go template for the Terraform workspace name with access to .Workspace and .WorkingDir and .Env"`
+	// Create creates the workspace when it does not exist. Defaults to true.
+	Create *bool `json:"create,omitempty" jsonschema:"title=create,description=Create the workspace when it does not exist (defaults to true)"`
+}
+
+// PlanOptions contains options for running `terraform plan`.
+type PlanOptions struct {
+	// VarFiles are variable files to load, relative to each working directory unless absolute.
+	VarFiles []string `json:"var_files,omitempty" jsonschema:"title=var_files,description=Variable files relative to each working directory unless absolute"`
+	// Vars are input variables set on the command line.
+	Vars map[string]string `json:"vars,omitempty" jsonschema:"title=vars,description=Input variables set with -var"`
+	// Targets limits planning to the given resource addresses.
+	Targets []string `json:"targets,omitempty" jsonschema:"title=targets,description=Resource addresses to limit planning to"`
+	// Replace forces replacement of the given resource addresses.
+	Replace []string `json:"replace,omitempty" jsonschema:"title=replace,description=Resource addresses to force replacement of"`
+	// Refresh indicates whether to refresh state before planning. Ignored in drift mode.
+	Refresh *bool `json:"refresh,omitempty" jsonschema:"title=refresh,description=Whether to refresh state before planning (defaults to true and ignored in drift mode)"`
+	// Lock indicates whether to hold a state lock.
+	Lock *bool `json:"lock,omitempty" jsonschema:"title=lock,description=Whether to hold a state lock (defaults to true)"`
+	// LockTimeout is how long to retry acquiring a state lock, such as "30s".
+	LockTimeout string `json:"lock_timeout,omitempty" jsonschema:"title=lock_timeout,description=Duration to retry acquiring a state lock such as 30s"`
+	// Parallelism limits the number of concurrent operations.
+	Parallelism *int `json:"parallelism,omitempty" validate:"omitempty,min=1" jsonschema:"title=parallelism,description=Number of concurrent operations Terraform walks the graph with"`
 }
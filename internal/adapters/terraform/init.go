@@ -0,0 +1,37 @@
+package terraform
+
+import (
+	"fmt"
+	"maps"
+	"path/filepath"
+	"slices"
+)
+
+// ResolveBackendConfig returns the -backend-config arguments for a working
+// directory. Key/value pairs come first, sorted by key, followed by files
+// resolved against the working directory unless absolute. Files are returned
+// as absolute paths, as terraform runs from within the working directory.
+// Every value and file path is a template.
+func (i *InitOptions) ResolveBackendConfig(workingDir string) ([]string, error) {
+	config := make([]string, 0, len(i.BackendConfig)+len(i.BackendConfigFiles))
+	for _, key := range slices.Sorted(maps.Keys(i.BackendConfig)) {
+		value, err := render("backend config "+key, i.BackendConfig[key], workingDir)
+		if err != nil {
+			return nil, err
+		}
+		config = append(config, key+"="+value)
+	}
+	for _, file := range i.BackendConfigFiles {
+		file, err := render("backend config file", file, workingDir)
+		if err != nil {
+			return nil, err
+		}
+		if !filepath.IsAbs(file) {
+			if file, err = filepath.Abs(filepath.Join(workingDir, file)); err != nil {
+				return nil, fmt.Errorf("failed to resolve backend config file: %w", err)
+			}
+		}
+		config = append(config, file)
+	}
+	return config, nil
+}
@@ -0,0 +1,46 @@
+package terraform_test
+
+import (
+	"path/filepath"
+	"testing"
+
+	"github.com/cultureamp/terraform-buildkite-plugin/internal/adapters/terraform"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestInitOptions_ResolveBackendConfig(t *testing.T) {
+	t.Setenv("STATE_BUCKET", "state-prod")
+	checkout := t.TempDir()
+	t.Chdir(checkout)
+
+	t.Run("templated values and files", func(t *testing.T) {
+		opts := &terraform.InitOptions{
+			BackendConfig: map[string]string{
+				"key":    "stacks/{{.Workspace}}/terraform.tfstate",
+				"bucket": "{{.Env.STATE_BUCKET}}",
+			},
+			BackendConfigFiles: []string{"backend.hcl", "/etc/terraform/{{.Workspace}}.hcl"},
+		}
+		config, err := opts.ResolveBackendConfig("stacks/app")
+		require.NoError(t, err)
+		assert.Equal(t, []string{
+			"bucket=state-prod",
+			"key=stacks/app/terraform.tfstate",
+			filepath.Join(checkout, "stacks/app", "backend.hcl"),
+			"/etc/terraform/app.hcl",
+		}, config)
+	})
+
+	t.Run("no backend config", func(t *testing.T) {
+		config, err := (&terraform.InitOptions{}).ResolveBackendConfig("stacks/app")
+		require.NoError(t, err)
+		assert.Empty(t, config)
+	})
+
+	t.Run("invalid template", func(t *testing.T) {
+		opts := &terraform.InitOptions{BackendConfig: map[string]string{"key": "{{.Workspace"}}
+		_, err := opts.ResolveBackendConfig("stacks/app")
+		require.ErrorContains(t, err, "failed to parse backend config key template")
+	})
+}
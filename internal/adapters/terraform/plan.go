@@ -0,0 +1,38 @@
+package terraform
+
+import (
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+)
+
+// ResolveVarFiles returns the var files for a working directory, resolving
+// relative paths against it and checking that every file exists. Paths are
+// returned absolute, as terraform runs from within the working directory.
+func (p *PlanOptions) ResolveVarFiles(workingDir string) ([]string, error) {
+	files := make([]string, 0, len(p.VarFiles))
+	var errs []error
+	for _, file := range p.VarFiles {
+		if !filepath.IsAbs(file) {
+			abs, err := filepath.Abs(filepath.Join(workingDir, file))
+			if err != nil {
+				errs = append(errs, fmt.Errorf("var file %s: %w", file, err))
+				continue
+			}
+			file = abs
+		}
+		info, err := os.Stat(file)
+		switch {
+		case err != nil:
+			errs = append(errs, fmt.Errorf("var file %s: %w", file, err))
+		case info.IsDir():
+			errs = append(errs, fmt.Errorf("var file %s is a directory", file))
+		}
+		files = append(files, file)
+	}
+	if err := errors.Join(errs...); err != nil {
+		return nil, err
+	}
+	return files, nil
+}
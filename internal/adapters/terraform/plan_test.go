@@ -0,0 +1,48 @@
+package terraform_test
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/cultureamp/terraform-buildkite-plugin/internal/adapters/terraform"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestPlanOptions_ResolveVarFiles(t *testing.T) {
+	workingDir := t.TempDir()
+	shared := filepath.Join(t.TempDir(), "shared.tfvars")
+	require.NoError(t, os.WriteFile(filepath.Join(workingDir, "prod.tfvars"), nil, 0o600))
+	require.NoError(t, os.WriteFile(shared, nil, 0o600))
+	require.NoError(t, os.Mkdir(filepath.Join(workingDir, "vars"), 0o700))
+
+	t.Run("relative and absolute files", func(t *testing.T) {
+		opts := &terraform.PlanOptions{VarFiles: []string{"prod.tfvars", shared}}
+		files, err := opts.ResolveVarFiles(workingDir)
+		require.NoError(t, err)
+		assert.Equal(t, []string{filepath.Join(workingDir, "prod.tfvars"), shared}, files)
+	})
+
+	t.Run("relative working directory", func(t *testing.T) {
+		t.Chdir(filepath.Dir(workingDir))
+		opts := &terraform.PlanOptions{VarFiles: []string{"prod.tfvars"}}
+		files, err := opts.ResolveVarFiles(filepath.Base(workingDir))
+		require.NoError(t, err)
+		assert.Equal(t, []string{filepath.Join(workingDir, "prod.tfvars")}, files)
+	})
+
+	t.Run("no files", func(t *testing.T) {
+		files, err := (&terraform.PlanOptions{}).ResolveVarFiles(workingDir)
+		require.NoError(t, err)
+		assert.Empty(t, files)
+	})
+
+	t.Run("missing files and directories", func(t *testing.T) {
+		opts := &terraform.PlanOptions{VarFiles: []string{"missing.tfvars", "vars", "prod.tfvars"}}
+		_, err := opts.ResolveVarFiles(workingDir)
+		require.ErrorIs(t, err, os.ErrNotExist)
+		assert.ErrorContains(t, err, "missing.tfvars")
+		assert.ErrorContains(t, err, "is a directory")
+	})
+}
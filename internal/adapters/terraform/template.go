@@ -0,0 +1,44 @@
+package terraform
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+	"text/template"
+)
+
+// templateData is the data available to templates evaluated per working directory.
+type templateData struct {
+	Workspace  string
+	WorkingDir string
+	Env        map[string]string
+}
+
+// render executes a template with the data for a working directory.
+func render(name, text, workingDir string) (string, error) {
+	tmpl, err := template.New(name).Option("missingkey=error").Parse(text)
+	if err != nil {
+		return "", fmt.Errorf("failed to parse %s template: %w", name, err)
+	}
+	data := templateData{
+		Workspace:  filepath.Base(workingDir),
+		WorkingDir: workingDir,
+		Env:        environ(),
+	}
+	var sb strings.Builder
+	if err = tmpl.Execute(&sb, data); err != nil {
+		return "", fmt.Errorf("failed to execute %s template: %w", name, err)
+	}
+	return sb.String(), nil
+}
+
+func environ() map[string]string {
+	env := map[string]string{}
+	for _, kv := range os.Environ() {
+		if k, v, ok := strings.Cut(kv, "="); ok {
+			env[k] = v
+		}
+	}
+	return env
+}
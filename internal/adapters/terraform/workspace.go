@@ -0,0 +1,24 @@
+package terraform
+
+import (
+	"fmt"
+	"strings"
+)
+
+// ResolveName executes the workspace name template for a working directory.
+func (w *Workspace) ResolveName(workingDir string) (string, error) {
+	name, err := render("workspace name", w.Name, workingDir)
+	if err != nil {
+		return "", err
+	}
+	name = strings.TrimSpace(name)
+	if name == "" {
+		return "", fmt.Errorf("workspace name template %q resolved to an empty name", w.Name)
+	}
+	return name, nil
+}
+
+// ShouldCreate reports whether a missing workspace is created.
+func (w *Workspace) ShouldCreate() bool {
+	return w.Create == nil || *w.Create
+}
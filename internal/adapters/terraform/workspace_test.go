@@ -0,0 +1,46 @@
+package terraform_test
+
+import (
+	"testing"
+
+	"github.com/cultureamp/terraform-buildkite-plugin/internal/adapters/terraform"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestWorkspace_ResolveName(t *testing.T) {
+	t.Setenv("STEP_ENVIRONMENT", "production")
+	falseValue := false
+
+	cases := []struct {
+		name      string
+		template  string
+		want      string
+		wantError string
+	}{
+		{name: "static name", template: "staging", want: "staging"},
+		{name: "directory name", template: "{{.Workspace}}", want: "app"},
+		{name: "environment", template: "{{.Workspace}}-{{.Env.STEP_ENVIRONMENT}}", want: "app-production"},
+		{name: "missing environment variable", template: "{{.Env.MISSING}}", wantError: "failed to execute"},
+		{name: "invalid template", template: "{{.Workspace", wantError: "failed to parse"},
+		{name: "empty name", template: "  ", wantError: "empty name"},
+	}
+	for _, tc := range cases {
+		t.Run(tc.name, func(t *testing.T) {
+			workspace := &terraform.Workspace{Name: tc.template, Create: &falseValue}
+			got, err := workspace.ResolveName("stacks/app")
+			if tc.wantError != "" {
+				require.ErrorContains(t, err, tc.wantError)
+				return
+			}
+			require.NoError(t, err)
+			assert.Equal(t, tc.want, got)
+		})
+	}
+}
+
+func TestWorkspace_ShouldCreate(t *testing.T) {
+	falseValue := false
+	assert.True(t, (&terraform.Workspace{}).ShouldCreate())
+	assert.False(t, (&terraform.Workspace{Create: &falseValue}).ShouldCreate())
+}
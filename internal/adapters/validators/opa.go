@@ -6,6 +6,7 @@ import (
 	"context"
 	"fmt"
 
+	"github.com/cultureamp/terraform-buildkite-plugin/internal/adapters/plansummary"
 	"github.com/cultureamp/terraform-buildkite-plugin/internal/adapters/validators/opa"
 	tfjson "github.com/hashicorp/terraform-json"
 	"github.com/rs/zerolog/log"
@@ -24,6 +25,13 @@ type OpaValidatorAdapter struct {
 	config *OpaValidation
 }
 
+// policyInput is the input OPA policies are evaluated against: the plan as
+// terraform renders it with its summary added under summary.
+type policyInput struct {
+	*tfjson.Plan
+	Summary *plansummary.Summary `json:"summary"`
+}
+
 // NewOpaValidatorAdapter creates a new validator adapter for OPA validation.
 //
 // Parameters:
@@ -76,17 +84,21 @@ func NewOpaValidatorAdapter(validationConfig *OpaValidation, name string) Valida
 //   - ValidationResult containing pass/fail status and detailed failures
 //   - An error if the validation process itself fails
 //
+// Policies are evaluated against the plan with its summary under
+// input.summary, so they can check change counts without walking
+// input.resource_changes.
+//
 // The adapter converts OPA policy violations into structured ValidationFailure
 // objects with appropriate context and details.
 func (v *OpaValidatorAdapter) Validate(ctx context.Context, plan *tfjson.Plan) (ValidationResult, error) {
-	log.Info().
+	log.Ctx(ctx).Info().
 		Str("validator", v.name).
 		Msg("Starting OPA policy validation")
 
 	// Evaluate the OPA policy against the plan
-	violations, err := v.policyValidator.Eval(ctx, plan)
+	violations, err := v.policyValidator.Eval(ctx, &policyInput{Plan: plan, Summary: plansummary.New(plan)})
 	if err != nil {
-		log.Error().
+		log.Ctx(ctx).Error().
 			Err(err).
 			Str("validator", v.name).
 			Msg("OPA policy evaluation failed")
@@ -96,7 +108,7 @@ func (v *OpaValidatorAdapter) Validate(ctx context.Context, plan *tfjson.Plan) (
 	// Convert violations to ValidationResult format
 	result := v.convertViolationsToResult(violations)
 
-	log.Info().
+	log.Ctx(ctx).Info().
 		Str("validator", v.name).
 		Bool("passed", result.Passed).
 		Int("violations", len(result.Failures)).
@@ -110,7 +110,7 @@ func NewRego(bundle, query, condition string, _ ...func(r *PolicyValidator)) Pol
 // The method logs the evaluation process and returns an empty slice if no violations
 // are found, indicating the policy passed successfully.
 func (r *regoEvaluator) Eval(ctx context.Context, input any) ([]any, error) {
-	log.Info().
+	log.Ctx(ctx).Info().
 		Str("bundle", r.bundle).
 		Str("query", r.query).
 		Str("condition", r.condition).
@@ -119,7 +119,7 @@ func (r *regoEvaluator) Eval(ctx context.Context, input any) ([]any, error) {
 	// Prepare the query for evaluation
 	query, err := r.rego.PrepareForEval(ctx)
 	if err != nil {
-		log.Error().
+		log.Ctx(ctx).Error().
 			Err(err).
 			Str("bundle", r.bundle).
 			Str("query", r.query).
@@ -127,19 +127,19 @@ func (r *regoEvaluator) Eval(ctx context.Context, input any) ([]any, error) {
 		return nil, fmt.Errorf("failed to prepare OPA query: %w", err)
 	}
 
-	log.Debug().Msg("OPA query prepared successfully")
+	log.Ctx(ctx).Debug().Msg("OPA query prepared successfully")
 
 	// Execute the query against the input data
 	results, err := query.Eval(ctx, rego.EvalInput(input))
 	if err != nil {
-		log.Error().
+		log.Ctx(ctx).Error().
 			Err(err).
 			Str("query", r.query).
 			Msg("Failed to evaluate OPA query")
 		return nil, fmt.Errorf("failed to evaluate OPA query: %w", err)
 	}
 
-	log.Debug().
+	log.Ctx(ctx).Debug().
 		Int("resultCount", len(results)).
 		Msg("OPA query evaluation completed")
 
@@ -147,13 +147,13 @@ func (r *regoEvaluator) Eval(ctx context.Context, input any) ([]any, error) {
 	for _, result := range results {
 		for _, expr := range result.Expressions {
 			if expr.Value != nil {
-				log.Debug().
+				log.Ctx(ctx).Debug().
 					Interface("expressionValue", expr.Value).
 					Msg("Processing expression result")
 				var filteredValues []any
 				filteredValues, err = r.filterResult(expr.Value, r.condition)
 				if err != nil {
-					log.Error().
+					log.Ctx(ctx).Error().
 						Err(err).
 						Str("condition", r.condition).
 						Msg("Failed to filter policy result")
@@ -161,19 +161,19 @@ func (r *regoEvaluator) Eval(ctx context.Context, input any) ([]any, error) {
 				}
 
 				if len(filteredValues) > 0 {
-					log.Debug().
+					log.Ctx(ctx).Debug().
 						Interface("filteredValues", filteredValues).
 						Int("count", len(filteredValues)).
 						Msg("Found policy violations")
 					violations = append(violations, filteredValues...)
 				} else {
-					log.Debug().Msg("No violations found in expression result")
+					log.Ctx(ctx).Debug().Msg("No violations found in expression result")
 				}
 			}
 		}
 	}
 
-	log.Info().
+	log.Ctx(ctx).Info().
 		Int("violationCount", len(violations)).
 		Msg("OPA policy evaluation completed")
 
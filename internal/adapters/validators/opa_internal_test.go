@@ -0,0 +1,56 @@
+package validators
+
+import (
+	"context"
+	"encoding/json"
+	"testing"
+
+	tfjson "github.com/hashicorp/terraform-json"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// inputRecorder records the input policies are evaluated against.
+type inputRecorder struct {
+	input any
+}
+
+func (r *inputRecorder) Eval(_ context.Context, input any) ([]any, error) {
+	r.input = input
+	return nil, nil
+}
+
+func TestOpaValidatorAdapter_Validate(t *testing.T) {
+	plan := &tfjson.Plan{
+		FormatVersion: "1.2",
+		ResourceChanges: []*tfjson.ResourceChange{{
+			Address: "aws_s3_bucket.logs",
+			Type:    "aws_s3_bucket",
+			Change:  &tfjson.Change{Actions: tfjson.Actions{tfjson.ActionDelete, tfjson.ActionCreate}},
+		}},
+	}
+	recorder := &inputRecorder{}
+	v := &OpaValidatorAdapter{policyValidator: recorder, name: "test"}
+
+	result, err := v.Validate(t.Context(), plan)
+	require.NoError(t, err)
+	assert.True(t, result.Passed)
+
+	data, err := json.Marshal(recorder.input)
+	require.NoError(t, err)
+	var input struct {
+		FormatVersion   string           `json:"format_version"`
+		ResourceChanges []map[string]any `json:"resource_changes"`
+		Summary         struct {
+			Replace int `json:"replace"`
+			ByType  map[string]struct {
+				Replace int `json:"replace"`
+			} `json:"by_type"`
+		} `json:"summary"`
+	}
+	require.NoError(t, json.Unmarshal(data, &input))
+	assert.Equal(t, "1.2", input.FormatVersion)
+	assert.Len(t, input.ResourceChanges, 1)
+	assert.Equal(t, 1, input.Summary.Replace)
+	assert.Equal(t, 1, input.Summary.ByType["aws_s3_bucket"].Replace)
+}
@@ -49,6 +49,16 @@ type Working struct {
 	// This is mutually exclusive with WorkingDirectory for single directory mode.
 	Directories *Directories `json:"directories" validate:"omitempty,excluded_with=Directory" jsonschema:"title=directories,description=Configuration for multiple working directories"`
 
+	// DependsOn declares ordering between working directories.
+	// Keys and values are workspace names, the base name of each working directory,
+	// or its path relative to the checkout when working directories share a base name.
+	// A workspace only runs once every workspace it depends on has succeeded.
+	DependsOn map[string][]string `json:"depends_on,omitempty" jsonschema:"title=depends_on,description=Map of workspace name to the workspace names it depends on"`
+
+	// InferDependencies infers ordering between working directories from
+	// terraform_remote_state data sources and the cdktf manifest.
+	InferDependencies bool `json:"infer_dependencies,omitempty" jsonschema:"title=infer_dependencies,description=Infer dependencies from terraform_remote_state data sources and cdktf stack dependencies"`
+
 	// Parallelism contains Buildkite parallel job context information.
 	// This is automatically populated from Buildkite environment variables
 	// and is not typically set via JSON configuration.
@@ -0,0 +1,403 @@
+package workingdir
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"regexp"
+	"slices"
+	"strings"
+
+	"github.com/cultureamp/terraform-buildkite-plugin/internal/common"
+	"github.com/rs/zerolog/log"
+)
+
+// cdktfManifest is the subset of the cdktf.out/manifest.json file used to infer dependencies.
+type cdktfManifest struct {
+	Stacks map[string]struct {
+		Dependencies []string `json:"dependencies"`
+	} `json:"stacks"`
+}
+
+// remoteStateBlock matches the start of a terraform_remote_state data source in HCL.
+var remoteStateBlock = regexp.MustCompile(`data\s+"terraform_remote_state"\s+"[^"]*"\s*\{`)
+
+// pathAttribute matches a key or prefix attribute set to a double quoted HCL string.
+var pathAttribute = regexp.MustCompile(`\b(?:key|prefix)\s*=\s*"((?:[^"\\]|\\.)*)"`)
+
+// workspacesBlock matches the start of a remote backend workspaces block or object.
+var workspacesBlock = regexp.MustCompile(`\bworkspaces\s*=?\s*\{`)
+
+// nameAttribute matches a name attribute set to a double quoted HCL string.
+var nameAttribute = regexp.MustCompile(`\bname\s*=\s*"((?:[^"\\]|\\.)*)"`)
+
+// Dependencies returns, for each working directory, the working directories it depends on.
+//
+// Dependencies come from the explicit DependsOn configuration and, when
+// InferDependencies is enabled, from the cdktf manifest and from
+// terraform_remote_state data sources whose state location is another
+// workspace's name or path. Workspaces are named by the base name of their
+// working directory, or by its path relative to the checkout when working
+// directories share a base name. Dependencies on workspaces outside dirs are
+// ignored, while an explicit dependency on a shared base name and a
+// dependency cycle are reported as errors.
+func (w *Working) Dependencies(ctx context.Context, dirs []string) (map[string][]string, error) {
+	dependencies := map[string][]string{}
+	if w == nil || (len(w.DependsOn) == 0 && !w.InferDependencies) {
+		return dependencies, nil
+	}
+	names := newWorkspaceIndex(dirs)
+	add := func(dir, dependencyName, source string) error {
+		dependency, err := names.lookup(dependencyName)
+		if err != nil {
+			return err
+		}
+		if dependency == "" {
+			log.Ctx(ctx).Warn().
+				Str("workspace", filepath.Base(dir)).
+				Str("dependency", dependencyName).
+				Str("source", source).
+				Msg("ignoring dependency on workspace that is not being processed")
+			return nil
+		}
+		if dependency == dir || slices.Contains(dependencies[dir], dependency) {
+			return nil
+		}
+		log.Ctx(ctx).Debug().
+			Str("workspace", filepath.Base(dir)).
+			Str("dependency", dependencyName).
+			Str("source", source).
+			Msg("adding workspace dependency")
+		dependencies[dir] = append(dependencies[dir], dependency)
+		return nil
+	}
+
+	for name, dependencyNames := range w.DependsOn {
+		dir, err := names.lookup(name)
+		if err != nil {
+			return nil, err
+		}
+		if dir == "" {
+			log.Ctx(ctx).Warn().Str("workspace", name).Msg("ignoring dependencies for workspace that is not being processed")
+			continue
+		}
+		for _, dependencyName := range dependencyNames {
+			if err = add(dir, dependencyName, "depends_on"); err != nil {
+				return nil, err
+			}
+		}
+	}
+
+	if w.InferDependencies {
+		manifests := map[string]*cdktfManifest{}
+		for _, dir := range dirs {
+			name := filepath.Base(dir)
+			manifest, err := readCdktfManifest(filepath.Dir(filepath.Dir(dir)), manifests)
+			if err != nil {
+				return nil, err
+			}
+			if manifest != nil {
+				for _, dependencyName := range manifest.Stacks[name].Dependencies {
+					if err = add(dir, dependencyName, "cdktf"); err != nil {
+						return nil, err
+					}
+				}
+			}
+			references, err := remoteStateReferences(ctx, dir)
+			if err != nil {
+				return nil, err
+			}
+			for _, reference := range references {
+				dependency, lookupErr := names.lookup(reference)
+				if lookupErr != nil {
+					log.Ctx(ctx).Warn().Err(lookupErr).Str("workspace", filepath.Base(dir)).
+						Msg("ignoring ambiguous terraform_remote_state reference")
+					continue
+				}
+				if dependency != "" {
+					if err = add(dir, reference, "terraform_remote_state"); err != nil {
+						return nil, err
+					}
+				}
+			}
+		}
+	}
+
+	for dir := range dependencies {
+		slices.Sort(dependencies[dir])
+	}
+	if cycle := findCycle(dirs, dependencies); cycle != nil {
+		return nil, fmt.Errorf("dependency cycle between working directories: %s", strings.Join(cycle, " -> "))
+	}
+	log.Ctx(ctx).Info().Interface("dependencies", dependencies).Msg("resolved working directory dependencies")
+	return dependencies, nil
+}
+
+// workspaceIndex resolves workspace names to working directories.
+type workspaceIndex struct {
+	byPath map[string]string   // checkout-relative path to working directory
+	byName map[string][]string // base name to working directories
+}
+
+// newWorkspaceIndex indexes working directories by base name and by path
+// relative to the checkout.
+func newWorkspaceIndex(dirs []string) *workspaceIndex {
+	names := &workspaceIndex{byPath: map[string]string{}, byName: map[string][]string{}}
+	for _, dir := range dirs {
+		names.byPath[workspacePath(dir)] = dir
+		name := filepath.Base(dir)
+		names.byName[name] = append(names.byName[name], dir)
+	}
+	return names
+}
+
+// lookup returns the working directory a workspace name or path refers to, or
+// an empty string when there is none. Base names shared by several working
+// directories are an error, as they must be referred to by path.
+func (n *workspaceIndex) lookup(name string) (string, error) {
+	if dir, ok := n.byPath[strings.Trim(filepath.ToSlash(filepath.Clean(name)), "/")]; ok {
+		return dir, nil
+	}
+	switch dirs := n.byName[name]; len(dirs) {
+	case 0:
+		return "", nil
+	case 1:
+		return dirs[0], nil
+	default:
+		paths := make([]string, 0, len(dirs))
+		for _, dir := range dirs {
+			paths = append(paths, workspacePath(dir))
+		}
+		return "", fmt.Errorf("workspace name %s is shared by working directories %s, refer to them by path",
+			name, strings.Join(paths, ", "))
+	}
+}
+
+// workspacePath returns the path of a working directory relative to the checkout,
+// or its cleaned path when it is outside the checkout.
+func workspacePath(dir string) string {
+	path, err := common.CheckoutRelativePath(dir)
+	if err != nil {
+		return strings.Trim(filepath.ToSlash(filepath.Clean(dir)), "/")
+	}
+	return path
+}
+
+// readCdktfManifest reads the cdktf manifest in dir, caching the result per directory.
+func readCdktfManifest(dir string, cache map[string]*cdktfManifest) (*cdktfManifest, error) {
+	if manifest, ok := cache[dir]; ok {
+		return manifest, nil
+	}
+	data, err := os.ReadFile(filepath.Join(dir, "manifest.json"))
+	if errors.Is(err, os.ErrNotExist) {
+		cache[dir] = nil
+		return nil, nil
+	}
+	if err != nil {
+		return nil, fmt.Errorf("failed to read cdktf manifest: %w", err)
+	}
+	var manifest cdktfManifest
+	if err = json.Unmarshal(data, &manifest); err != nil {
+		return nil, fmt.Errorf("failed to parse cdktf manifest in %s: %w", dir, err)
+	}
+	cache[dir] = &manifest
+	return &manifest, nil
+}
+
+// remoteStateReferences returns the workspace names and paths that
+// terraform_remote_state data sources in dir may refer to.
+//
+// Only the state location attributes are considered: key and prefix, whose
+// whole path may name a workspace or be its path, and the name of a remote
+// backend workspace. Other values such as bucket names are ignored.
+func remoteStateReferences(ctx context.Context, dir string) ([]string, error) {
+	entries, err := os.ReadDir(dir)
+	if err != nil {
+		return nil, fmt.Errorf("failed to read working directory %s: %w", dir, err)
+	}
+	var locations []remoteStateLocation
+	for _, entry := range entries {
+		if entry.IsDir() {
+			continue
+		}
+		name := entry.Name()
+		if !strings.HasSuffix(name, ".tf") && !strings.HasSuffix(name, ".tf.json") {
+			continue
+		}
+		data, err := os.ReadFile(filepath.Join(dir, name))
+		if err != nil {
+			return nil, fmt.Errorf("failed to read %s: %w", name, err)
+		}
+		if strings.HasSuffix(name, ".tf.json") {
+			locations = append(locations, jsonRemoteStateLocations(ctx, data)...)
+		} else {
+			locations = append(locations, hclRemoteStateLocations(string(data))...)
+		}
+	}
+	var references []string
+	for _, location := range locations {
+		references = append(references, location.references()...)
+	}
+	return references, nil
+}
+
+// remoteStateLocation holds the attributes of a terraform_remote_state
+// configuration that identify which state it reads.
+type remoteStateLocation struct {
+	paths      []string // values of key and prefix
+	workspaces []string // names of remote backend workspaces
+}
+
+// references returns the workspace names and paths a remote state location may
+// refer to. A trailing .tfstate is trimmed from each path, and the default state
+// file name terraform.tfstate is dropped, so a key of network/terraform.tfstate
+// refers to network. Individual path segments are never references.
+func (l remoteStateLocation) references() []string {
+	references := slices.Clone(l.workspaces)
+	for _, path := range l.paths {
+		segments := strings.FieldsFunc(path, func(r rune) bool { return r == '/' })
+		if len(segments) == 0 {
+			continue
+		}
+		last := len(segments) - 1
+		segments[last] = strings.TrimSuffix(segments[last], ".tfstate")
+		if segments[last] == "terraform" {
+			segments = segments[:last]
+		}
+		if len(segments) > 0 {
+			references = append(references, strings.Join(segments, "/"))
+		}
+	}
+	return references
+}
+
+// jsonRemoteStateLocations returns the state locations of terraform_remote_state
+// data sources in a Terraform JSON configuration file.
+func jsonRemoteStateLocations(ctx context.Context, data []byte) []remoteStateLocation {
+	var config struct {
+		Data struct {
+			RemoteState map[string]struct {
+				Config struct {
+					Key        string `json:"key"`
+					Prefix     string `json:"prefix"`
+					Workspaces any    `json:"workspaces"`
+				} `json:"config"`
+			} `json:"terraform_remote_state"`
+		} `json:"data"`
+	}
+	if err := json.Unmarshal(data, &config); err != nil {
+		log.Ctx(ctx).Debug().Err(err).Msg("skipping unparsable terraform JSON configuration")
+		return nil
+	}
+	var locations []remoteStateLocation
+	for _, remoteState := range config.Data.RemoteState {
+		location := remoteStateLocation{}
+		for _, path := range []string{remoteState.Config.Key, remoteState.Config.Prefix} {
+			if path != "" {
+				location.paths = append(location.paths, path)
+			}
+		}
+		location.workspaces = workspaceNames(remoteState.Config.Workspaces)
+		locations = append(locations, location)
+	}
+	return locations
+}
+
+// workspaceNames returns the name of each remote backend workspace in a
+// decoded JSON workspaces value, which is either an object or a list of objects.
+func workspaceNames(v any) []string {
+	switch value := v.(type) {
+	case map[string]any:
+		if name, ok := value["name"].(string); ok && name != "" {
+			return []string{name}
+		}
+	case []any:
+		var names []string
+		for _, item := range value {
+			names = append(names, workspaceNames(item)...)
+		}
+		return names
+	}
+	return nil
+}
+
+// hclRemoteStateLocations returns the state locations of terraform_remote_state
+// data sources in an HCL configuration file.
+func hclRemoteStateLocations(content string) []remoteStateLocation {
+	var locations []remoteStateLocation
+	for _, loc := range remoteStateBlock.FindAllStringIndex(content, -1) {
+		body := blockBody(content, loc[1])
+		location := remoteStateLocation{}
+		for _, match := range pathAttribute.FindAllStringSubmatch(body, -1) {
+			location.paths = append(location.paths, match[1])
+		}
+		for _, workspaces := range workspacesBlock.FindAllStringIndex(body, -1) {
+			for _, match := range nameAttribute.FindAllStringSubmatch(blockBody(body, workspaces[1]), -1) {
+				location.workspaces = append(location.workspaces, match[1])
+			}
+		}
+		locations = append(locations, location)
+	}
+	return locations
+}
+
+// blockBody returns the content of the block whose opening brace ends at start,
+// up to its matching closing brace.
+func blockBody(content string, start int) string {
+	depth := 1
+	end := start
+	for ; end < len(content) && depth > 0; end++ {
+		switch content[end] {
+		case '{':
+			depth++
+		case '}':
+			depth--
+		}
+	}
+	return content[start:end]
+}
+
+// findCycle returns the workspace names forming a dependency cycle, or nil if there is none.
+func findCycle(dirs []string, dependencies map[string][]string) []string {
+	const (
+		unvisited = iota
+		visiting
+		visited
+	)
+	state := map[string]int{}
+	var stack []string
+	var visit func(dir string) []string
+	visit = func(dir string) []string {
+		state[dir] = visiting
+		stack = append(stack, dir)
+		for _, dependency := range dependencies[dir] {
+			switch state[dependency] {
+			case visiting:
+				start := slices.Index(stack, dependency)
+				cycle := make([]string, 0, len(stack)-start+1)
+				for _, d := range stack[start:] {
+					cycle = append(cycle, filepath.Base(d))
+				}
+				return append(cycle, filepath.Base(dependency))
+			case unvisited:
+				if cycle := visit(dependency); cycle != nil {
+					return cycle
+				}
+			}
+		}
+		stack = stack[:len(stack)-1]
+		state[dir] = visited
+		return nil
+	}
+	for _, dir := range dirs {
+		if state[dir] == unvisited {
+			if cycle := visit(dir); cycle != nil {
+				return cycle
+			}
+		}
+	}
+	return nil
+}
@@ -0,0 +1,219 @@
+package workingdir_test
+
+import (
+	"os"
+	"path/filepath"
+	"slices"
+	"testing"
+
+	"github.com/cultureamp/terraform-buildkite-plugin/internal/adapters/workingdir"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// makeStacks creates a directory per stack containing the given files.
+func makeStacks(t *testing.T, parent string, stacks map[string]map[string]string) []string {
+	t.Helper()
+	var dirs []string
+	for _, name := range []string{"app", "cluster", "network"} {
+		files, ok := stacks[name]
+		if !ok {
+			continue
+		}
+		dir := filepath.Join(parent, name)
+		require.NoError(t, os.MkdirAll(dir, 0o755))
+		for file, content := range files {
+			require.NoError(t, os.WriteFile(filepath.Join(dir, file), []byte(content), 0o600))
+		}
+		dirs = append(dirs, dir)
+	}
+	return dirs
+}
+
+func TestWorking_Dependencies(t *testing.T) {
+	t.Run("no dependency configuration", func(t *testing.T) {
+		w := &workingdir.Working{}
+		deps, err := w.Dependencies(t.Context(), []string{"a", "b"})
+		require.NoError(t, err)
+		assert.Empty(t, deps)
+	})
+
+	t.Run("explicit depends_on", func(t *testing.T) {
+		w := &workingdir.Working{DependsOn: map[string][]string{
+			"app":     {"cluster", "unknown"},
+			"cluster": {"network"},
+		}}
+		deps, err := w.Dependencies(t.Context(), []string{"stacks/app", "stacks/cluster", "stacks/network"})
+		require.NoError(t, err)
+		assert.Equal(t, map[string][]string{
+			"stacks/app":     {"stacks/cluster"},
+			"stacks/cluster": {"stacks/network"},
+		}, deps)
+	})
+
+	t.Run("cycle is an error", func(t *testing.T) {
+		w := &workingdir.Working{DependsOn: map[string][]string{
+			"app":     {"cluster"},
+			"cluster": {"app"},
+		}}
+		_, err := w.Dependencies(t.Context(), []string{"stacks/app", "stacks/cluster"})
+		require.Error(t, err)
+		assert.Contains(t, err.Error(), "dependency cycle")
+	})
+
+	t.Run("inferred from cdktf manifest", func(t *testing.T) {
+		out := t.TempDir()
+		manifest := `{"stacks": {"app": {"dependencies": ["cluster"]}, "cluster": {"dependencies": ["network"]}}}`
+		require.NoError(t, os.WriteFile(filepath.Join(out, "manifest.json"), []byte(manifest), 0o600))
+		dirs := makeStacks(t, filepath.Join(out, "stacks"), map[string]map[string]string{
+			"app": {}, "cluster": {}, "network": {},
+		})
+		w := &workingdir.Working{InferDependencies: true}
+		deps, err := w.Dependencies(t.Context(), dirs)
+		require.NoError(t, err)
+		assert.Equal(t, map[string][]string{
+			dirs[0]: {dirs[1]},
+			dirs[1]: {dirs[2]},
+		}, deps)
+	})
+
+	t.Run("inferred from terraform_remote_state", func(t *testing.T) {
+		hcl := `
+data "terraform_remote_state" "cluster" {
+  backend = "s3"
+  config = {
+    bucket = "state"
+    key    = "cluster/terraform.tfstate"
+  }
+}
+
+resource "null_resource" "network" {}
+`
+		json := `{"data": {"terraform_remote_state": {"network": {"backend": "gcs", ` +
+			`"config": {"bucket": "app-state", "prefix": "network"}}}}}`
+		dirs := makeStacks(t, t.TempDir(), map[string]map[string]string{
+			"app":     {"main.tf": hcl},
+			"cluster": {"cdk.tf.json": json},
+			"network": {"main.tf": `resource "null_resource" "app" {}`},
+		})
+		w := &workingdir.Working{InferDependencies: true}
+		deps, err := w.Dependencies(t.Context(), dirs)
+		require.NoError(t, err)
+		assert.Equal(t, map[string][]string{
+			dirs[0]: {dirs[1]},
+			dirs[1]: {dirs[2]},
+		}, deps)
+	})
+}
+
+func TestWorking_Dependencies_SharedBaseNames(t *testing.T) {
+	checkout := t.TempDir()
+	t.Setenv("BUILDKITE_BUILD_CHECKOUT_PATH", checkout)
+	prod := makeStacks(t, filepath.Join(checkout, "prod"), map[string]map[string]string{
+		"app": {}, "network": {"main.tf": `
+data "terraform_remote_state" "app" {
+  backend = "s3"
+  config = {
+    key = "staging/app/terraform.tfstate"
+  }
+}
+`},
+	})
+	staging := makeStacks(t, filepath.Join(checkout, "staging"), map[string]map[string]string{
+		"app": {}, "network": {"main.tf": `
+data "terraform_remote_state" "app" {
+  backend = "s3"
+  config = {
+    key = "env/prod/app/terraform.tfstate"
+  }
+}
+`},
+	})
+	dirs := slices.Concat(prod, staging)
+
+	t.Run("shared base names are an error", func(t *testing.T) {
+		w := &workingdir.Working{DependsOn: map[string][]string{"app": {"network"}}}
+		_, err := w.Dependencies(t.Context(), dirs)
+		require.ErrorContains(t, err, "workspace name app is shared by working directories prod/app, staging/app")
+	})
+
+	t.Run("paths relative to the checkout", func(t *testing.T) {
+		w := &workingdir.Working{DependsOn: map[string][]string{"prod/app": {"prod/network"}}}
+		deps, err := w.Dependencies(t.Context(), dirs)
+		require.NoError(t, err)
+		assert.Equal(t, map[string][]string{prod[0]: {prod[1]}}, deps)
+	})
+
+	t.Run("state locations match whole paths", func(t *testing.T) {
+		w := &workingdir.Working{InferDependencies: true}
+		deps, err := w.Dependencies(t.Context(), dirs)
+		require.NoError(t, err)
+		assert.Equal(t, map[string][]string{prod[1]: {staging[0]}}, deps)
+	})
+}
+
+func TestWorking_Dependencies_RemoteStateLocations(t *testing.T) {
+	hcl := `
+data "terraform_remote_state" "shared" {
+  backend = "s3"
+  config = {
+    bucket = "prod-terraform-state"
+    key    = "app/terraform.tfstate"
+    region = "us-west-2"
+  }
+}
+
+data "terraform_remote_state" "tfc" {
+  backend = "remote"
+  config = {
+    organization = "state"
+    workspaces = {
+      name = "network"
+    }
+  }
+}
+`
+	parent := t.TempDir()
+	var dirs []string
+	for _, name := range []string{"app", "network", "prod", "state", "terraform", "consumer"} {
+		dir := filepath.Join(parent, name)
+		require.NoError(t, os.MkdirAll(dir, 0o755))
+		dirs = append(dirs, dir)
+	}
+	require.NoError(t, os.WriteFile(filepath.Join(parent, "consumer", "main.tf"), []byte(hcl), 0o600))
+
+	w := &workingdir.Working{InferDependencies: true}
+	deps, err := w.Dependencies(t.Context(), dirs)
+	require.NoError(t, err)
+	assert.Equal(t, map[string][]string{
+		filepath.Join(parent, "consumer"): {filepath.Join(parent, "app"), filepath.Join(parent, "network")},
+	}, deps)
+}
+
+func TestWorking_Shard(t *testing.T) {
+	dirs := []string{"stacks/app", "stacks/cluster", "stacks/dns", "stacks/logs", "stacks/network"}
+	dependencies := map[string][]string{
+		"stacks/app":     {"stacks/cluster"},
+		"stacks/cluster": {"stacks/network"},
+	}
+	shard := func(job, count int) ([]string, map[string][]string) {
+		w := &workingdir.Working{Parallelism: &workingdir.Parallelism{ParallelJob: &job, ParallelJobCount: &count}}
+		return w.Shard(t.Context(), dirs, dependencies)
+	}
+
+	t.Run("dependent working directories share a job", func(t *testing.T) {
+		first, firstDeps := shard(0, 2)
+		assert.Equal(t, []string{"stacks/app", "stacks/cluster", "stacks/dns", "stacks/network"}, first)
+		assert.Equal(t, dependencies, firstDeps)
+		second, secondDeps := shard(1, 2)
+		assert.Equal(t, []string{"stacks/logs"}, second)
+		assert.Empty(t, secondDeps)
+	})
+
+	t.Run("without parallelism every working directory is returned", func(t *testing.T) {
+		w := &workingdir.Working{}
+		all, deps := w.Shard(t.Context(), dirs, dependencies)
+		assert.Equal(t, dirs, all)
+		assert.Equal(t, dependencies, deps)
+	})
+}
@@ -6,6 +6,7 @@ import (
 	"github.com/rs/zerolog/log"
 )
 
+// Parse returns every configured working directory, across all parallel jobs.
 func (w *Working) Parse() ([]string, error) {
 	log.Debug().Msg("parsing working directory configuration")
 
@@ -30,23 +31,10 @@ func (w *Working) Parse() ([]string, error) {
 			return nil, err
 		}
 
-		// if the plugin has parallelism configured, partition the directories
-		if w.Parallelism != nil {
-			result := partition(directories, *w.Parallelism.ParallelJob, *w.Parallelism.ParallelJobCount)
-			log.Info().
-				Int("parallelJob", *w.Parallelism.ParallelJob).
-				Int("parallelJobCount", *w.Parallelism.ParallelJobCount).
-				Int("selectedDirectoryCount", len(result)).
-				Int("totalDirectoryCount", len(directories)).
-				Interface("directories", result).
-				Msg("successfully parsed working directories with parallelism")
-			return result, nil
-		}
-
-		// if no parallelism is configured, return the directories as is
+		// parallel jobs are assigned their share of the directories by Shard,
+		// once dependencies between them are known
 		log.Info().
 			Int("count", len(directories)).
-			Interface("directories", directories).
 			Msg("successfully parsed working directories")
 		return directories, nil
 	}
@@ -1,6 +1,86 @@
 package workingdir
 
-import "github.com/rs/zerolog/log"
+import (
+	"context"
+	"slices"
+
+	"github.com/rs/zerolog/log"
+)
+
+// Shard returns the working directories assigned to this parallel job, and
+// the dependencies between them.
+//
+// Working directories connected by dependencies are always assigned to the
+// same job, so that they still run in dependency order. Groups of connected
+// working directories are spread across jobs the same way partition spreads
+// items, and each job keeps the original directory order. Without parallelism
+// every working directory is returned.
+func (w *Working) Shard(
+	ctx context.Context,
+	dirs []string,
+	dependencies map[string][]string,
+) ([]string, map[string][]string) {
+	if w == nil || w.Parallelism == nil || w.Parallelism.ParallelJob == nil || w.Parallelism.ParallelJobCount == nil {
+		return dirs, dependencies
+	}
+	index := make(map[string]int, len(dirs))
+	for i, dir := range dirs {
+		index[dir] = i
+	}
+	// union-find over directory indexes, keeping the lowest index as each group's root
+	parent := make([]int, len(dirs))
+	for i := range parent {
+		parent[i] = i
+	}
+	var find func(i int) int
+	find = func(i int) int {
+		if parent[i] != i {
+			parent[i] = find(parent[i])
+		}
+		return parent[i]
+	}
+	for dir, dependencyDirs := range dependencies {
+		for _, dependency := range dependencyDirs {
+			i, ok := index[dir]
+			j, found := index[dependency]
+			if !ok || !found {
+				continue
+			}
+			a, b := find(i), find(j)
+			parent[max(a, b)] = min(a, b)
+		}
+	}
+	var roots []int
+	members := map[int][]int{}
+	for i := range dirs {
+		root := find(i)
+		if root == i {
+			roots = append(roots, root)
+		}
+		members[root] = append(members[root], i)
+	}
+	var selected []int
+	for _, root := range partition(roots, *w.Parallelism.ParallelJob, *w.Parallelism.ParallelJobCount) {
+		selected = append(selected, members[root]...)
+	}
+	slices.Sort(selected)
+	result := make([]string, 0, len(selected))
+	sharded := map[string][]string{}
+	for _, i := range selected {
+		result = append(result, dirs[i])
+		if deps, ok := dependencies[dirs[i]]; ok {
+			sharded[dirs[i]] = deps
+		}
+	}
+	log.Ctx(ctx).Info().
+		Int("parallelJob", *w.Parallelism.ParallelJob).
+		Int("parallelJobCount", *w.Parallelism.ParallelJobCount).
+		Int("selectedDirectoryCount", len(result)).
+		Int("totalDirectoryCount", len(dirs)).
+		Interface("directories", result).
+		Msg("selected working directories for parallel job")
+	return result, sharded
+}
 
 // Partition returns the contiguous chunk of items assigned to job `i`
 // out of `n` total jobs. Jobs and jobCount are 0-based: 0 ≤ i < n.
@@ -10,6 +10,7 @@ import (
 	"fmt"
 	"io"
 	"os"
+	"path/filepath"
 	"strings"
 
 	"github.com/alecthomas/chroma/quick"
@@ -49,6 +50,46 @@ func FetchEnv(key, fallback string) string {
 	return fallback
 }
 
+// CheckoutRelativePath returns a path relative to the build checkout, using forward slashes.
+//
+// Paths relative to the checkout identify a working directory the same way in
+// every step of a build, even when steps run on agents with different build
+// directories.
+//
+// # Parameters
+//
+//   - path: The path to resolve, relative to the current directory unless absolute
+//
+// # Returns
+//
+// The path relative to BUILDKITE_BUILD_CHECKOUT_PATH, or to the current
+// directory when it is not set. An error is returned when the path is outside
+// the checkout.
+//
+// # Example
+//
+//	// With BUILDKITE_BUILD_CHECKOUT_PATH=/builds/org/pipeline
+//	key, err := CheckoutRelativePath("/builds/org/pipeline/stacks/network")
+//	// key == "stacks/network"
+func CheckoutRelativePath(path string) (string, error) {
+	root := FetchEnv("BUILDKITE_BUILD_CHECKOUT_PATH", "")
+	if root == "" {
+		var err error
+		if root, err = os.Getwd(); err != nil {
+			return "", fmt.Errorf("failed to determine checkout directory: %w", err)
+		}
+	}
+	abs, err := filepath.Abs(path)
+	if err != nil {
+		return "", fmt.Errorf("failed to resolve path: %w", err)
+	}
+	rel, err := filepath.Rel(root, abs)
+	if err != nil || rel == ".." || strings.HasPrefix(rel, ".."+string(filepath.Separator)) {
+		return "", fmt.Errorf("%s is outside the checkout %s", path, root)
+	}
+	return filepath.ToSlash(rel), nil
+}
+
 // WritePrettyJSON prints structured data as pretty JSON with syntax highlighting.
 //
 // This function is useful for displaying plugin configuration or other data in a human-readable format.
@@ -84,6 +125,27 @@ func WritePrettyJSON(data any, w io.Writer) error {
 	return nil
 }
 
+// NewConsoleWriter creates the CI-friendly console writer used for plugin logs.
+//
+// The writer produces coloured output without timestamps. It is shared so that
+// loggers writing to buffers produce the same format as the global logger.
+//
+// # Parameters
+//
+//   - w: The io.Writer to write formatted log lines to (e.g., os.Stdout)
+//
+// # Example
+//
+//	logger := zerolog.New(common.NewConsoleWriter(os.Stdout))
+func NewConsoleWriter(w io.Writer) zerolog.ConsoleWriter {
+	return zerolog.ConsoleWriter{
+		Out:             w,
+		NoColor:         false,
+		PartsExclude:    []string{"time"},
+		FormatFieldName: func(i any) string { return fmt.Sprintf("%s:", i) },
+	}
+}
+
 // SetLogLevel sets the global zerolog log level from a string value.
 //
 // This function parses a log level string (e.g., "info", "debug", "warn") and applies it to the global zerolog logger.
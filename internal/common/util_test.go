@@ -60,6 +60,21 @@ func TestFetchEnv(t *testing.T) {
 	})
 }
 
+func TestCheckoutRelativePath(t *testing.T) {
+	t.Setenv("BUILDKITE_BUILD_CHECKOUT_PATH", "/builds/org/pipeline")
+
+	t.Run("absolute path inside the checkout", func(t *testing.T) {
+		rel, err := common.CheckoutRelativePath("/builds/org/pipeline/stacks/prod/network")
+		require.NoError(t, err)
+		assert.Equal(t, "stacks/prod/network", rel)
+	})
+
+	t.Run("path outside the checkout", func(t *testing.T) {
+		_, err := common.CheckoutRelativePath("/builds/org/other/stacks/network")
+		require.ErrorContains(t, err, "outside the checkout")
+	})
+}
+
 func TestWritePrettyJSON(t *testing.T) {
 	t.Run("prints valid JSON", func(t *testing.T) {
 		data := map[string]any{"foo": "bar", "num": 42}
@@ -18,6 +18,16 @@ import (
 type Config interface {
 	// LoadPlugin loads, parses, and validates a named Buildkite plugin configuration.
 	LoadPlugin(ctx context.Context, pluginName string) (*Plugin, error)
+	// LoadRawPlugin returns the unparsed configuration of a named Buildkite plugin and the reference it was used with.
+	LoadRawPlugin(ctx context.Context, pluginName string) (*RawPlugin, error)
+}
+
+// RawPlugin is a plugin entry as it appears in the Buildkite plugins JSON.
+type RawPlugin struct {
+	// Reference is the plugin reference used in the pipeline, e.g. "github.com/org/plugin#v1.0.0".
+	Reference string
+	// Config is the unparsed plugin configuration.
+	Config json.RawMessage
 }
 
 // config implements Config.
@@ -81,3 +91,13 @@ func (c *config) LoadPlugin(_ context.Context, pluginName string) (*Plugin, erro
 	log.Debug().Interface("plugin", plugin).Msg("plugin configuration details")
 	return plugin, nil
 }
+
+// LoadRawPlugin returns the unparsed configuration of a Buildkite plugin.
+func (c *config) LoadRawPlugin(_ context.Context, pluginName string) (*RawPlugin, error) {
+	entries, err := c.unmarshalPlugins(common.FetchEnv(c.pluginsEnv, ""))
+	if err != nil {
+		log.Error().Str("plugin", pluginName).Msg("failed to unmarshal plugins JSON")
+		return nil, err
+	}
+	return c.findRawPluginEntry(entries, pluginName)
+}
@@ -7,6 +7,7 @@ import (
 	"github.com/cultureamp/terraform-buildkite-plugin/internal/adapters/outputs"
 	"github.com/cultureamp/terraform-buildkite-plugin/internal/adapters/validators"
 	"github.com/cultureamp/terraform-buildkite-plugin/internal/adapters/workingdir"
+	"github.com/cultureamp/terraform-buildkite-plugin/pkg/buildkite/agent"
 	"github.com/stretchr/testify/assert"
 	"github.com/stretchr/testify/require"
 )
@@ -248,6 +249,36 @@ func TestValidatePlugin(t *testing.T) {
 			require.NoError(t, err)
 		})
 
+		t.Run("destroy mode", func(t *testing.T) {
+			workingDir := t.TempDir()
+			plugin := &Plugin{
+				Mode: Destroy,
+				Working: &workingdir.Working{
+					Directory: &workingDir,
+				},
+			}
+			err := cfg.validatePlugin(plugin)
+			require.NoError(t, err)
+		})
+
+		t.Run("stage timeouts", func(t *testing.T) {
+			plugin := &Plugin{
+				Mode:     Apply,
+				Timeouts: &Timeouts{Init: "5m", Plan: "30m", Validation: "90s", Apply: "1h"},
+			}
+			err := cfg.validatePlugin(plugin)
+			require.NoError(t, err)
+		})
+
+		t.Run("retry policy", func(t *testing.T) {
+			plugin := &Plugin{
+				Mode:  Plan,
+				Retry: &Retry{Attempts: 5, Backoff: "30s", Patterns: []string{`(?i)throttl`}},
+			}
+			err := cfg.validatePlugin(plugin)
+			require.NoError(t, err)
+		})
+
 		t.Run("working directories config", func(t *testing.T) {
 			parentDir := t.TempDir()
 			plugin := &Plugin{
@@ -280,6 +311,106 @@ func TestValidatePlugin(t *testing.T) {
 			require.Error(t, err)
 		})
 
+		t.Run("pipeline mode without pipeline options", func(t *testing.T) {
+			plugin := &Plugin{
+				Mode: Pipeline,
+			}
+			err := cfg.validatePlugin(plugin)
+			require.Error(t, err)
+		})
+
+		t.Run("report mode without report options", func(t *testing.T) {
+			plugin := &Plugin{
+				Mode: Report,
+			}
+			err := cfg.validatePlugin(plugin)
+			require.ErrorContains(t, err, "Plugin.Report")
+		})
+
+		t.Run("invalid report source", func(t *testing.T) {
+			plugin := &Plugin{
+				Mode:   Report,
+				Report: &RunReport{Source: "s3"},
+			}
+			err := cfg.validatePlugin(plugin)
+			require.ErrorContains(t, err, "Report.Source")
+		})
+
+		t.Run("invalid timeout", func(t *testing.T) {
+			for _, timeout := range []string{"soon", "-5m", "0s"} {
+				plugin := &Plugin{
+					Mode:     Plan,
+					Timeouts: &Timeouts{Plan: timeout},
+				}
+				err := cfg.validatePlugin(plugin)
+				require.ErrorContains(t, err, "Timeouts.Plan", timeout)
+			}
+		})
+
+		t.Run("invalid retry pattern", func(t *testing.T) {
+			plugin := &Plugin{
+				Mode:  Plan,
+				Retry: &Retry{Patterns: []string{"Throttling", "("}},
+			}
+			err := cfg.validatePlugin(plugin)
+			require.ErrorContains(t, err, "Retry.Patterns[1]")
+		})
+
+		t.Run("invalid computed variables", func(t *testing.T) {
+			t.Setenv("STEP_ENVIRONMENT", "production")
+			annotation := func(vars ...outputs.ComputedVar) *Plugin {
+				return &Plugin{
+					Mode: Plan,
+					Outputs: outputs.Outputs{Outputs: []outputs.Output{{
+						BuildkiteAnnotation: &outputs.BuildkiteAnnotation{ComputedVars: vars},
+					}}},
+				}
+			}
+
+			require.NoError(t, cfg.validatePlugin(annotation(
+				outputs.ComputedVar{Name: "env", From: "env.STEP_ENVIRONMENT", Regex: "^(prod)"},
+				outputs.ComputedVar{Name: "version", From: "plan.terraform_version", Regex: `^(\d+)\.`},
+			)))
+			for field, computed := range map[string]outputs.ComputedVar{
+				"From":  {Name: "env", From: "env.UNSET_ENVIRONMENT", Regex: ".*"},
+				"Regex": {Name: "env", From: "workspace", Regex: "("},
+				"Name":  {From: "working_dir", Regex: ".*"},
+			} {
+				err := cfg.validatePlugin(annotation(computed))
+				require.ErrorContains(t, err, "BuildkiteAnnotation.ComputedVars[0]."+field)
+			}
+			err := cfg.validatePlugin(annotation(outputs.ComputedVar{Name: "env", From: "branch", Regex: ".*"}))
+			require.ErrorContains(t, err, "computed_var_source")
+		})
+
+		t.Run("invalid annotation stages", func(t *testing.T) {
+			annotation := func(b *outputs.BuildkiteAnnotation) *Plugin {
+				return &Plugin{
+					Mode:    Plan,
+					Outputs: outputs.Outputs{Outputs: []outputs.Output{{BuildkiteAnnotation: b}}},
+				}
+			}
+
+			require.NoError(t, cfg.validatePlugin(annotation(&outputs.BuildkiteAnnotation{
+				Templates: map[outputs.Stage]string{outputs.PlanFailure: "failure.tmpl"},
+				Styles: map[outputs.Stage]agent.AnnotationStyle{
+					outputs.DestroyPlanSuccessWithChanges: agent.StyleError,
+				},
+			})))
+			err := cfg.validatePlugin(annotation(&outputs.BuildkiteAnnotation{
+				Templates: map[outputs.Stage]string{"plan_failed": "failure.tmpl"},
+			}))
+			require.ErrorContains(t, err, "BuildkiteAnnotation.Templates[plan_failed]")
+			err = cfg.validatePlugin(annotation(&outputs.BuildkiteAnnotation{
+				Templates: map[outputs.Stage]string{outputs.PlanFailure: ""},
+			}))
+			require.ErrorContains(t, err, "BuildkiteAnnotation.Templates[plan_failure]")
+			err = cfg.validatePlugin(annotation(&outputs.BuildkiteAnnotation{
+				Styles: map[outputs.Stage]agent.AnnotationStyle{outputs.ApplySuccess: "danger"},
+			}))
+			require.ErrorContains(t, err, "BuildkiteAnnotation.Styles[apply_success]")
+		})
+
 		t.Run("both working_directory and working_directories set", func(t *testing.T) {
 			workingDir := t.TempDir()
 			parentDir := t.TempDir()
@@ -297,3 +428,18 @@ func TestValidatePlugin(t *testing.T) {
 		})
 	})
 }
+
+// Tests for findRawPluginEntry function
+
+func TestFindRawPluginEntry(t *testing.T) {
+	cfg := NewConfig().(*config)
+
+	data := []map[string]json.RawMessage{
+		{"github.com/org/other-plugin#v0.0.1": json.RawMessage(`{}`)},
+		{"github.com/org/terraform-buildkite-plugin#v0.0.1": json.RawMessage(`{"mode": "pipeline"}`)},
+	}
+	raw, err := cfg.findRawPluginEntry(data, "terraform-buildkite-plugin")
+	require.NoError(t, err)
+	assert.Equal(t, "github.com/org/terraform-buildkite-plugin#v0.0.1", raw.Reference)
+	assert.JSONEq(t, `{"mode": "pipeline"}`, string(raw.Config))
+}
@@ -122,8 +122,8 @@ func TestInitializePlugin(t *testing.T) {
 								{"region": "us-west-2"}
 							],
 							"computed_vars": [
-								{"name": "workspace", "from": "working_directory", "regex": "/([^/]+)/?$"},
-								{"name": "environment", "from": "workspace_name", "regex": "^env-(.+)$"}
+								{"name": "workspace", "from": "working_dir", "regex": "/([^/]+)/?$"},
+								{"name": "environment", "from": "workspace", "regex": "^env-(.+)$"}
 							]
 						}
 					}
@@ -148,12 +148,12 @@ func TestInitializePlugin(t *testing.T) {
 							ComputedVars: []outputs.ComputedVar{
 								{
 									Name:  "workspace",
-									From:  "working_directory",
+									From:  "working_dir",
 									Regex: "/([^/]+)/?$",
 								},
 								{
 									Name:  "environment",
-									From:  "workspace_name",
+									From:  "workspace",
 									Regex: "^env-(.+)$",
 								},
 							},
@@ -10,6 +10,7 @@ import (
 	"strings"
 
 	"github.com/caarlos0/env/v11"
+	"github.com/cultureamp/terraform-buildkite-plugin/internal/adapters/outputs"
 	"github.com/cultureamp/terraform-buildkite-plugin/internal/adapters/workingdir"
 	"github.com/go-playground/validator/v10"
 	"github.com/rs/zerolog/log"
@@ -43,11 +44,20 @@ func (c *config) unmarshalPlugins(data string) ([]map[string]json.RawMessage, er
 
 // findRawPlugin returns the first plugin config whose key matches pluginName prefix.
 func (c *config) findRawPlugin(data []map[string]json.RawMessage, pluginName string) (*json.RawMessage, error) {
+	raw, err := c.findRawPluginEntry(data, pluginName)
+	if err != nil {
+		return nil, err
+	}
+	return &raw.Config, nil
+}
+
+// findRawPluginEntry returns the first plugin entry whose key matches pluginName prefix.
+func (c *config) findRawPluginEntry(data []map[string]json.RawMessage, pluginName string) (*RawPlugin, error) {
 	for _, p := range data {
 		for key, pluginConfig := range p {
 			if strings.HasPrefix(c.getPluginName(key), pluginName) {
 				log.Debug().Str("matched_key", key).Msg("found matching plugin configuration")
-				return &pluginConfig, nil
+				return &RawPlugin{Reference: key, Config: pluginConfig}, nil
 			}
 		}
 	}
@@ -87,9 +97,31 @@ func (c *config) parseRawPlugin(data *json.RawMessage) (*Plugin, error) {
 // validatePlugin checks struct tags and field constraints.
 func (c *config) validatePlugin(plugin *Plugin) error {
 	validate := validator.New(validator.WithRequiredStructEnabled())
+	if err := validate.RegisterValidation("duration", validateDuration); err != nil {
+		return fmt.Errorf("failed to register duration validation: %w", err)
+	}
+	if err := validate.RegisterValidation("regexp", validateRegexp); err != nil {
+		return fmt.Errorf("failed to register regexp validation: %w", err)
+	}
+	if err := validate.RegisterValidation("computed_var_source", validateComputedVarSource); err != nil {
+		return fmt.Errorf("failed to register computed_var_source validation: %w", err)
+	}
+	if err := validate.RegisterValidation("stage", validateStage); err != nil {
+		return fmt.Errorf("failed to register stage validation: %w", err)
+	}
 	if err := validate.Struct(plugin); err != nil {
 		log.Error().Msg("plugin validation failed")
 		return fmt.Errorf("failed to validate config: %w", err)
 	}
 	return nil
 }
+
+// validateComputedVarSource reports whether a field names a computed variable source that can be resolved.
+func validateComputedVarSource(fl validator.FieldLevel) bool {
+	return outputs.ValidSource(fl.Field().String())
+}
+
+// validateStage reports whether a field names an output stage.
+func validateStage(fl validator.FieldLevel) bool {
+	return outputs.ValidStage(fl.Field().String())
+}
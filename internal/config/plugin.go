@@ -6,7 +6,9 @@
 package config
 
 import (
+	"github.com/cultureamp/terraform-buildkite-plugin/internal/adapters/artifacts"
 	"github.com/cultureamp/terraform-buildkite-plugin/internal/adapters/outputs"
+	"github.com/cultureamp/terraform-buildkite-plugin/internal/adapters/pipeline"
 	"github.com/cultureamp/terraform-buildkite-plugin/internal/adapters/terraform"
 	"github.com/cultureamp/terraform-buildkite-plugin/internal/adapters/validators"
 	"github.com/cultureamp/terraform-buildkite-plugin/internal/adapters/workingdir"
@@ -15,8 +17,12 @@ import (
 type Mode string
 
 const (
-	Plan  Mode = "plan"
-	Apply Mode = "apply"
+	Plan     Mode = "plan"
+	Apply    Mode = "apply"
+	Destroy  Mode = "destroy"
+	Drift    Mode = "drift"
+	Pipeline Mode = "pipeline"
+	Report   Mode = "report"
 )
 
 // Plugin represents the complete configuration for a Terraform Buildkite plugin instance.
@@ -26,15 +32,42 @@ const (
 // The validation tags ensure configuration consistency and completeness.
 type Plugin struct {
 	// Mode specifies the Terraform operation to perform.
-	// Valid values: "plan" for planning operations, "apply" for apply operations
-	Mode Mode `json:"mode" validate:"required,oneof=plan apply" jsonschema:"title=mode,description=Operation mode for the plugin (plan or apply)"`
+	// Valid values: "plan" for planning operations, "apply" for apply operations,
+	// "destroy" for planning and applying a destroy, "drift" for refresh-only drift detection,
+	// "pipeline" for uploading a pipeline with one step per working directory,
+	// "report" for summarising the reports of other jobs
+	Mode Mode `json:"mode" validate:"required,oneof=plan apply destroy drift pipeline report" jsonschema:"title=mode,description=Operation mode for the plugin (plan or apply or destroy or drift or pipeline or report)"`
 
 	// Working contains configuration for the working directories
 	Working *workingdir.Working `json:"working" jsonschema:"title=working,description=Configuration for the working directories containing Terraform configurations"`
 
+	// Concurrency is the maximum number of working directories processed at the same time.
+	// Defaults to 1, processing working directories one after another.
+	Concurrency int `json:"concurrency,omitempty" validate:"omitempty,min=1" jsonschema:"title=concurrency,description=Maximum number of working directories processed concurrently (defaults to 1)"`
+
+	// Pipeline configures the pipeline uploaded in pipeline mode.
+	Pipeline *pipeline.Options `json:"pipeline,omitempty" validate:"required_if=Mode pipeline" jsonschema:"title=pipeline,description=Options for the pipeline generated in pipeline mode with one step per working directory"`
+
+	// Approval configures the approval gate uploaded after a plan with changes, in plan mode.
+	Approval *pipeline.Approval `json:"approval,omitempty" jsonschema:"title=approval,description=Upload a block step and an apply step for each working directory with changes after planning"`
+
 	// Terraform contains options for executing Terraform commands.
 	Terraform *terraform.Options `json:"terraform,omitempty" jsonschema:"title=terraform,description=Terraform execution options including plugin directory, executable path, and plugin management"`
 
+	// Timeouts limits how long each stage of a working directory may run.
+	Timeouts *Timeouts `json:"timeouts,omitempty" jsonschema:"title=timeouts,description=Timeouts for the init and plan and validation and apply stages of each working directory"`
+
+	// Retry configures retrying terraform init and plan after transient failures.
+	// The default policy applies when it is omitted.
+	Retry *Retry `json:"retry,omitempty" jsonschema:"title=retry,description=Retry terraform init and plan when they fail with a transient error"`
+
+	// Report configures the JSON run report written once every working directory has run,
+	// and where reports are collected from in report mode.
+	Report *RunReport `json:"report,omitempty" validate:"required_if=Mode report" jsonschema:"title=report,description=Write a JSON report of every working directory once they have all run or collect reports in report mode"`
+
+	// PlanArtifacts configures sharing plans between plan and apply steps as Buildkite artifacts.
+	PlanArtifacts *artifacts.PlanArtifacts `json:"plan_artifacts,omitempty" jsonschema:"title=plan_artifacts,description=Upload plans as artifacts in plan mode and apply exactly those plans in apply mode"`
+
 	// Outputs defines how plugin results are formatted and presented.
 	outputs.Outputs
 
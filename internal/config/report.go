@@ -0,0 +1,39 @@
+package config
+
+// DefaultReportPath is the path the run report is written to when none is configured.
+const DefaultReportPath = "terraform-report.json"
+
+// ReportSource is where report mode collects the reports of other jobs from.
+type ReportSource string
+
+const (
+	ReportArtifacts ReportSource = "artifacts"
+	ReportMetaData  ReportSource = "meta_data"
+)
+
+// RunReport configures the JSON run report written once every working
+// directory has run, and where report mode collects reports from.
+type RunReport struct {
+	// Path is the file the report is written to, relative to the job's
+	// working directory unless absolute. Defaults to terraform-report.json.
+	Path string `json:"path,omitempty" jsonschema:"title=path,description=File the report is written to relative to the job's working directory (defaults to terraform-report.json)"`
+	// Upload uploads the report as a build artifact.
+	Upload bool `json:"upload,omitempty" jsonschema:"title=upload,description=Upload the report as a build artifact"`
+	// MetaData stores the report in build meta-data.
+	MetaData bool `json:"meta_data,omitempty" jsonschema:"title=meta_data,description=Store the report in build meta-data"`
+	// Source is where report mode collects reports from. Defaults to artifacts.
+	Source ReportSource `json:"source,omitempty" validate:"omitempty,oneof=artifacts meta_data" jsonschema:"title=source,description=Where report mode collects reports from (artifacts or meta_data) (defaults to artifacts)"`
+	// Step is the key or ID of the step whose reports are collected in report mode.
+	Step string `json:"step,omitempty" jsonschema:"title=step,description=Key or ID of the step whose reports are collected in report mode"`
+	// Jobs is the number of parallel jobs report mode expects a report from.
+	// Defaults to the parallel job count recorded in the collected reports.
+	Jobs int `json:"jobs,omitempty" validate:"omitempty,min=1" jsonschema:"title=jobs,description=Number of parallel jobs report mode expects a report from (defaults to the parallel job count recorded in the reports)"`
+}
+
+// CollectFrom returns where report mode collects reports from.
+func (r *RunReport) CollectFrom() ReportSource {
+	if r.Source == "" {
+		return ReportArtifacts
+	}
+	return r.Source
+}
@@ -0,0 +1,82 @@
+package config
+
+import (
+	"fmt"
+	"regexp"
+	"time"
+
+	"github.com/go-playground/validator/v10"
+)
+
+const (
+	defaultRetryAttempts = 3
+	defaultRetryBackoff  = 10 * time.Second
+)
+
+// defaultRetryPatterns match errors known to be transient: state lock
+// contention, provider and module registry timeouts, and 5xx responses.
+//
+//nolint:gochecknoglobals // fixed list of default retryable errors
+var defaultRetryPatterns = []string{
+	`Error acquiring the state lock`,
+	`Failed to query available provider packages`,
+	`(?i)registry\S* .*(timeout|timed out|deadline exceeded)`,
+	`(?i)client\.timeout exceeded|TLS handshake timeout|i/o timeout|connection reset by peer`,
+	`\b(500 Internal Server Error|502 Bad Gateway|503 Service Unavailable|504 Gateway Time-?out)\b`,
+	`(?i)status(?: code)?:? 5\d\d\b`,
+}
+
+// Retry configures retrying terraform init and plan when they fail with a
+// transient error. Without it the default policy applies.
+type Retry struct {
+	// Attempts is the maximum number of attempts, including the first. Defaults to 3.
+	Attempts int `json:"attempts,omitempty" validate:"omitempty,min=1" jsonschema:"title=attempts,description=Maximum number of attempts including the first (defaults to 3)"`
+	// Backoff is the wait before the second attempt, doubling after each
+	// further attempt. Defaults to 10s.
+	Backoff string `json:"backoff,omitempty" validate:"omitempty,duration" jsonschema:"title=backoff,description=Wait before the second attempt which doubles after each further attempt such as 10s (defaults to 10s)"`
+	// Patterns are regular expressions matching retryable errors, in addition
+	// to the default patterns for state lock contention, registry timeouts and
+	// 5xx responses.
+	Patterns []string `json:"patterns,omitempty" validate:"omitempty,dive,regexp" jsonschema:"title=patterns,description=Regular expressions matching retryable errors in addition to the defaults for state locks and registry timeouts and 5xx responses"`
+}
+
+// MaxAttempts returns the maximum number of attempts, which defaults to three
+// when retries are not configured.
+func (r *Retry) MaxAttempts() int {
+	if r == nil || r.Attempts == 0 {
+		return defaultRetryAttempts
+	}
+	return r.Attempts
+}
+
+// InitialBackoff returns the wait before the second attempt.
+func (r *Retry) InitialBackoff() time.Duration {
+	if r == nil || r.Backoff == "" {
+		return defaultRetryBackoff
+	}
+	return parseDuration(r.Backoff)
+}
+
+// RetryablePatterns compiles the default and configured retryable error patterns.
+func (r *Retry) RetryablePatterns() ([]*regexp.Regexp, error) {
+	var patterns []string
+	patterns = append(patterns, defaultRetryPatterns...)
+	if r != nil {
+		patterns = append(patterns, r.Patterns...)
+	}
+	compiled := make([]*regexp.Regexp, 0, len(patterns))
+	for _, pattern := range patterns {
+		re, err := regexp.Compile(pattern)
+		if err != nil {
+			return nil, fmt.Errorf("invalid retry pattern %q: %w", pattern, err)
+		}
+		compiled = append(compiled, re)
+	}
+	return compiled, nil
+}
+
+// validateRegexp reports whether a field holds a valid regular expression.
+func validateRegexp(fl validator.FieldLevel) bool {
+	_, err := regexp.Compile(fl.Field().String())
+	return err == nil
+}
@@ -0,0 +1,57 @@
+package config_test
+
+import (
+	"testing"
+	"time"
+
+	"github.com/cultureamp/terraform-buildkite-plugin/internal/config"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestRetry(t *testing.T) {
+	t.Run("defaults", func(t *testing.T) {
+		var unset *config.Retry
+		assert.Equal(t, 3, unset.MaxAttempts())
+		assert.Equal(t, 10*time.Second, unset.InitialBackoff())
+		assert.Equal(t, 1, (&config.Retry{Attempts: 1}).MaxAttempts())
+		assert.Equal(t, 3, (&config.Retry{}).MaxAttempts())
+		assert.Equal(t, 10*time.Second, (&config.Retry{}).InitialBackoff())
+		assert.Equal(t, 30*time.Second, (&config.Retry{Backoff: "30s"}).InitialBackoff())
+	})
+
+	t.Run("retryable patterns", func(t *testing.T) {
+		patterns, err := (&config.Retry{Patterns: []string{"Throttling"}}).RetryablePatterns()
+		require.NoError(t, err)
+		matches := func(message string) bool {
+			for _, pattern := range patterns {
+				if pattern.MatchString(message) {
+					return true
+				}
+			}
+			return false
+		}
+		for _, message := range []string{
+			"Error: Error acquiring the state lock",
+			"Error: Failed to query available provider packages",
+			"could not connect to registry.terraform.io: Get \"https://registry.terraform.io/.well-known/terraform.json\": " +
+				"net/http: request canceled (Client.Timeout exceeded while awaiting headers)",
+			"Error: 502 Bad Gateway",
+			"unexpected status code: 503",
+			"Throttling: Rate exceeded",
+		} {
+			assert.True(t, matches(message), message)
+		}
+		for _, message := range []string{
+			"Error: Unsupported argument",
+			"Error: Invalid reference",
+		} {
+			assert.False(t, matches(message), message)
+		}
+	})
+
+	t.Run("invalid pattern", func(t *testing.T) {
+		_, err := (&config.Retry{Patterns: []string{"("}}).RetryablePatterns()
+		require.ErrorContains(t, err, "invalid retry pattern")
+	})
+}
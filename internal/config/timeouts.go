@@ -0,0 +1,69 @@
+package config
+
+import (
+	"time"
+
+	"github.com/go-playground/validator/v10"
+)
+
+// Timeouts limits how long each stage of a working directory may run.
+//
+// Values are Go durations such as "10m" or "1h30m". A stage without a
+// timeout runs until it completes or the job is cancelled.
+type Timeouts struct {
+	// Init limits terraform init, including version checks and workspace selection.
+	Init string `json:"init,omitempty" validate:"omitempty,duration" jsonschema:"title=init,description=Timeout for terraform init including version checks and workspace selection such as 10m"`
+	// Plan limits terraform plan, or retrieving a saved plan, and showing it as JSON.
+	Plan string `json:"plan,omitempty" validate:"omitempty,duration" jsonschema:"title=plan,description=Timeout for terraform plan or retrieving a saved plan such as 30m"`
+	// Validation limits running every validator against the plan.
+	Validation string `json:"validation,omitempty" validate:"omitempty,duration" jsonschema:"title=validation,description=Timeout for running validations against the plan such as 5m"`
+	// Apply limits terraform apply.
+	Apply string `json:"apply,omitempty" validate:"omitempty,duration" jsonschema:"title=apply,description=Timeout for terraform apply such as 1h"`
+}
+
+// InitTimeout returns the init timeout, or zero when none is configured.
+func (t *Timeouts) InitTimeout() time.Duration {
+	if t == nil {
+		return 0
+	}
+	return parseDuration(t.Init)
+}
+
+// PlanTimeout returns the plan timeout, or zero when none is configured.
+func (t *Timeouts) PlanTimeout() time.Duration {
+	if t == nil {
+		return 0
+	}
+	return parseDuration(t.Plan)
+}
+
+// ValidationTimeout returns the validation timeout, or zero when none is configured.
+func (t *Timeouts) ValidationTimeout() time.Duration {
+	if t == nil {
+		return 0
+	}
+	return parseDuration(t.Validation)
+}
+
+// ApplyTimeout returns the apply timeout, or zero when none is configured.
+func (t *Timeouts) ApplyTimeout() time.Duration {
+	if t == nil {
+		return 0
+	}
+	return parseDuration(t.Apply)
+}
+
+// parseDuration parses a duration that has already been validated.
+func parseDuration(s string) time.Duration {
+	d, err := time.ParseDuration(s)
+	if err != nil {
+		return 0
+	}
+	return d
+}
+
+// validateDuration reports whether a field holds a positive Go duration.
+func validateDuration(fl validator.FieldLevel) bool {
+	d, err := time.ParseDuration(fl.Field().String())
+	return err == nil && d > 0
+}
@@ -5,8 +5,11 @@ import (
 	"fmt"
 	"os"
 	"path/filepath"
+	"time"
 
+	"github.com/cultureamp/terraform-buildkite-plugin/internal/adapters/pipeline"
 	"github.com/cultureamp/terraform-buildkite-plugin/internal/common"
+	c "github.com/cultureamp/terraform-buildkite-plugin/internal/config"
 	i "github.com/cultureamp/terraform-buildkite-plugin/internal/plugin/initiator"
 	o "github.com/cultureamp/terraform-buildkite-plugin/internal/plugin/orchestrator"
 	a "github.com/cultureamp/terraform-buildkite-plugin/pkg/buildkite/agent"
@@ -20,6 +23,9 @@ const (
 	UnexpectedFailure    ExitStatus = 1
 	HandledFailure       ExitStatus = 2
 	NoWorkingDirectories ExitStatus = 3
+	DriftDetected        ExitStatus = 4
+	TimedOut             ExitStatus = 5
+	Cancelled            ExitStatus = 6
 	TestModeEarlyExit    ExitStatus = 10
 )
 
@@ -34,6 +40,12 @@ func (e ExitStatus) GetName() string {
 		return "HandledFailure"
 	case NoWorkingDirectories:
 		return "NoWorkingDirectories"
+	case DriftDetected:
+		return "DriftDetected"
+	case TimedOut:
+		return "TimedOut"
+	case Cancelled:
+		return "Cancelled"
 	case TestModeEarlyExit:
 		return "TestModeEarlyExit"
 	default:
@@ -94,7 +106,7 @@ func NewHandler(
 	opts ...HandlerOption,
 ) Handler {
 	defaults := &handlerConfig{
-		tExecPath:       "", // Default to empty, will auto-discover "terraform" on PATH
+		tExecPath:       "", // Default to empty, will auto-discover the configured flavour on PATH
 		agent:           a.NewAgent(),
 		pluginInitiator: i.NewInitiator(),
 	}
@@ -119,13 +131,25 @@ func (h *handlerConfig) Handle(
 		log.Info().Msg("test mode is enabled, skipping plugin execution")
 		return TestModeEarlyExit, nil
 	}
+	if payload.Plugin.Mode == c.Report {
+		return h.handleReport(ctx, payload.Plugin.Report)
+	}
+	started := time.Now()
 	if len(payload.WorkingDirectories) == 0 {
 		log.Warn().Msg("no working directories specified, skipping plugin execution")
+		if payload.Plugin.Mode != c.Pipeline {
+			h.writeReport(ctx, payload.Plugin.Report,
+				newReport(payload.Plugin.Mode, nil, NoWorkingDirectories, started, time.Now()))
+		}
 		return NoWorkingDirectories, nil
 	}
+	if payload.Plugin.Mode == c.Pipeline {
+		return h.handlePipeline(ctx, payload)
+	}
 	log.Info().Int("workspaces", len(payload.WorkingDirectories)).Msg("starting plugin execution across workspaces")
 	log.Debug().Msg("creating orchestrator for plugin execution")
 	orchestrator, err := o.NewOrchestrator(
+		ctx,
 		payload.Plugin,
 		payload.Validators,
 		payload.Outputers,
@@ -133,17 +157,45 @@ func (h *handlerConfig) Handle(
 		o.WithTerraformExecPath(h.tExecPath),
 	)
 	if err != nil {
+		report := newReport(payload.Plugin.Mode, nil, UnexpectedFailure, started, time.Now())
+		report.Error = err.Error()
+		h.writeReport(ctx, payload.Plugin.Report, report)
 		return UnexpectedFailure, err
 	}
+	results := runWorkspaces(
+		ctx,
+		orchestrator,
+		payload.WorkingDirectories,
+		payload.Dependencies,
+		payload.Plugin.Concurrency,
+	)
+	status, err := h.summarize(ctx, payload, results)
+	h.writeReport(ctx, payload.Plugin.Report, newReport(payload.Plugin.Mode, results, status, started, time.Now()))
+	return status, err
+}
+
+// summarize logs the outcome of every working directory and returns the exit
+// status of the job, uploading the approval pipeline when there is one.
+func (h *handlerConfig) summarize(
+	ctx context.Context,
+	payload *i.ParsedPayload,
+	results []*o.WorkspaceResult,
+) (ExitStatus, error) {
 	failures := []o.WorkspaceResult{}
-	for _, workingDir := range payload.WorkingDirectories {
-		workdirName := filepath.Base(workingDir)
-		log.Info().Str("workspace", workdirName).
-			Msg("running orchestrator for workspace")
-		result := orchestrator.Run(ctx, workingDir)
-		if result != nil && !result.Success {
+	drifted := []o.WorkspaceResult{}
+	for _, result := range results {
+		workdirName := filepath.Base(result.WorkingDir)
+		if result.OutputError != nil {
+			log.Warn().Err(result.OutputError).Str("workspace", workdirName).
+				Msg("one or more outputers failed for workspace")
+		}
+		if !result.Success {
 			log.Warn().Str("workspace", workdirName).Msg("workspace execution failed")
-			failures = append(failures, *orchestrator.Run(ctx, workingDir))
+			failures = append(failures, *result)
+		} else if result.Drifted {
+			log.Warn().Str("workspace", workdirName).Strs("resources", result.DriftedResources).
+				Msg("workspace has drifted")
+			drifted = append(drifted, *result)
 		} else {
 			log.Info().Str("workspace", workdirName).
 				Msg("workspace execution succeeded")
@@ -155,8 +207,89 @@ func (h *handlerConfig) Handle(
 		for _, failure := range failures {
 			log.Error().Interface("workspace", failure).Msg("workspace execution failure")
 		}
-		return HandledFailure, nil
+		return failureStatus(failures), nil
+	}
+	if payload.Plugin.Mode == c.Plan && payload.Plugin.Approval != nil {
+		if err := h.uploadApproval(ctx, payload, results); err != nil {
+			return UnexpectedFailure, err
+		}
+	}
+	if len(drifted) > 0 {
+		log.Warn().Int("drifted", len(drifted)).Msg("drift detected in some workspaces")
+		return DriftDetected, nil
 	}
 	log.Info().Msg("plugin execution completed successfully across all workspaces")
 	return Success, nil
 }
+
+// failureStatus returns the exit status for failed workspaces. Cancellation
+// takes precedence over timeouts, which take precedence over other failures.
+func failureStatus(failures []o.WorkspaceResult) ExitStatus {
+	status := HandledFailure
+	for _, failure := range failures {
+		switch {
+		case failure.Cancelled:
+			return Cancelled
+		case failure.TimedOut:
+			status = TimedOut
+		}
+	}
+	return status
+}
+
+// handlePipeline uploads a pipeline with one step per working directory instead of running Terraform.
+func (h *handlerConfig) handlePipeline(ctx context.Context, payload *i.ParsedPayload) (ExitStatus, error) {
+	log.Info().Int("workspaces", len(payload.WorkingDirectories)).Msg("generating pipeline for workspaces")
+	p, err := payload.Plugin.Pipeline.Generate(
+		payload.Raw.Reference,
+		payload.Raw.Config,
+		payload.WorkingDirectories,
+		payload.Dependencies,
+	)
+	if err != nil {
+		return UnexpectedFailure, err
+	}
+	if err = uploadPipeline(ctx, h.agent, p); err != nil {
+		return UnexpectedFailure, err
+	}
+	log.Info().Int("steps", len(p.Steps)).Msg("pipeline uploaded successfully")
+	return Success, nil
+}
+
+// uploadApproval uploads a block step followed by an apply step for each
+// working directory whose plan has changes. Nothing is uploaded when no
+// working directory has changes.
+func (h *handlerConfig) uploadApproval(
+	ctx context.Context,
+	payload *i.ParsedPayload,
+	results []*o.WorkspaceResult,
+) error {
+	var changes []pipeline.Change
+	for _, result := range results {
+		if result.HasChanges {
+			changes = append(changes, pipeline.Change{
+				WorkingDir: result.WorkingDir,
+				Summary:    result.Changes.String(),
+			})
+		}
+	}
+	if len(changes) == 0 {
+		log.Info().Msg("no workspaces have changes, skipping approval")
+		return nil
+	}
+	p, err := payload.Plugin.Approval.Generate(
+		payload.Raw.Reference,
+		payload.Raw.Config,
+		changes,
+		payload.Dependencies,
+		common.FetchEnv("BUILDKITE_STEP_ID", ""),
+	)
+	if err != nil {
+		return err
+	}
+	if err = uploadPipeline(ctx, h.agent, p); err != nil {
+		return err
+	}
+	log.Info().Int("workspaces", len(changes)).Msg("approval uploaded for workspaces with changes")
+	return nil
+}
@@ -0,0 +1,76 @@
+package plugin
+
+import (
+	"context"
+	"encoding/json"
+	"os"
+	"path/filepath"
+	"testing"
+
+	c "github.com/cultureamp/terraform-buildkite-plugin/internal/config"
+	i "github.com/cultureamp/terraform-buildkite-plugin/internal/plugin/initiator"
+	o "github.com/cultureamp/terraform-buildkite-plugin/internal/plugin/orchestrator"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// payloadInitiator returns a fixed payload.
+type payloadInitiator struct {
+	payload *i.ParsedPayload
+}
+
+func (p *payloadInitiator) ParsePlugin(_ context.Context, _ string) (*i.ParsedPayload, error) {
+	return p.payload, nil
+}
+
+func TestFailureStatus(t *testing.T) {
+	failed := o.WorkspaceResult{WorkingDir: "stacks/network"}
+	timedOut := o.WorkspaceResult{WorkingDir: "stacks/app", TimedOut: true}
+	cancelled := o.WorkspaceResult{WorkingDir: "stacks/dns", Cancelled: true}
+
+	assert.Equal(t, HandledFailure, failureStatus([]o.WorkspaceResult{failed}))
+	assert.Equal(t, TimedOut, failureStatus([]o.WorkspaceResult{failed, timedOut}))
+	assert.Equal(t, Cancelled, failureStatus([]o.WorkspaceResult{timedOut, cancelled, failed}))
+	assert.Equal(t, "TimedOut", TimedOut.GetName())
+	assert.Equal(t, "Cancelled", Cancelled.GetName())
+}
+
+func TestHandle_EarlyExitReports(t *testing.T) {
+	readReport := func(t *testing.T, path string) *Report {
+		t.Helper()
+		data, err := os.ReadFile(path)
+		require.NoError(t, err)
+		var report Report
+		require.NoError(t, json.Unmarshal(data, &report))
+		return &report
+	}
+
+	t.Run("no working directories", func(t *testing.T) {
+		path := filepath.Join(t.TempDir(), "report.json")
+		h := NewHandler(WithInitatorInterface(&payloadInitiator{payload: &i.ParsedPayload{
+			Plugin: &c.Plugin{Mode: c.Plan, Report: &c.RunReport{Path: path}},
+		}}))
+
+		status, err := h.Handle(t.Context(), &Context{Name: "terraform"})
+		require.NoError(t, err)
+		assert.Equal(t, NoWorkingDirectories, status)
+		assert.Equal(t, NoWorkingDirectories.ToInt(), readReport(t, path).ExitStatus)
+	})
+
+	t.Run("orchestrator failure", func(t *testing.T) {
+		t.Setenv("PATH", "")
+		path := filepath.Join(t.TempDir(), "report.json")
+		h := NewHandler(WithInitatorInterface(&payloadInitiator{payload: &i.ParsedPayload{
+			Plugin:             &c.Plugin{Mode: c.Plan, Report: &c.RunReport{Path: path}},
+			WorkingDirectories: []string{"stacks/app"},
+		}}))
+
+		status, err := h.Handle(t.Context(), &Context{Name: "terraform"})
+		require.ErrorContains(t, err, "binary not found in PATH")
+		assert.Equal(t, UnexpectedFailure, status)
+		report := readReport(t, path)
+		assert.Equal(t, UnexpectedFailure.ToInt(), report.ExitStatus)
+		assert.Contains(t, report.Error, "binary not found in PATH")
+		assert.Empty(t, report.Workspaces)
+	})
+}
@@ -15,6 +15,10 @@ type ParsedPayload struct {
 	Outputers          []outputs.Outputer
 	Validators         []validators.Validator
 	WorkingDirectories []string
+	// Dependencies maps each working directory to the working directories it depends on.
+	Dependencies map[string][]string
+	// Raw is the unparsed plugin configuration, only loaded when steps are generated from it.
+	Raw *c.RawPlugin
 }
 
 type PluginInitiator interface {
@@ -72,6 +76,33 @@ func (i *initiatorConfig) ParsePlugin(
 		log.Error().Err(err).Msg("failed to parse working directories")
 		return nil, fmt.Errorf("failed to parse working directories: %w", err)
 	}
+	dependencies, err := plugin.Working.Dependencies(ctx, dirs)
+	if err != nil {
+		log.Error().Err(err).Msg("failed to resolve working directory dependencies")
+		return nil, fmt.Errorf("failed to resolve working directory dependencies: %w", err)
+	}
+	// dependencies are resolved across every parallel job before sharding, so
+	// that dependent working directories are assigned to the same job
+	dirs, dependencies = plugin.Working.Shard(ctx, dirs, dependencies)
+	if plugin.Approval != nil && plugin.PlanArtifacts == nil {
+		log.Warn().Msg("approval is configured without plan_artifacts, so apply steps plan again " +
+			"and may apply changes that differ from the approved plan")
+	}
+	var raw *c.RawPlugin
+	if plugin.Mode == c.Pipeline || (plugin.Mode == c.Plan && plugin.Approval != nil) {
+		raw, err = i.configInterface.LoadRawPlugin(ctx, pluginName)
+		if err != nil {
+			log.Error().Str("plugin", pluginName).Msg("failed to load raw plugin configuration")
+			return nil, err
+		}
+	}
 	log.Info().Msg("plugin configuration loaded and parsed successfully")
-	return &ParsedPayload{plugin, outputers, validators, dirs}, nil
+	return &ParsedPayload{
+		Plugin:             plugin,
+		Outputers:          outputers,
+		Validators:         validators,
+		WorkingDirectories: dirs,
+		Dependencies:       dependencies,
+		Raw:                raw,
+	}, nil
 }
@@ -0,0 +1,167 @@
+package orchestrator
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"io"
+	"strings"
+
+	tfjson "github.com/hashicorp/terraform-json"
+)
+
+// uiEvent is a message of terraform's machine-readable UI stream, printed by
+// plan and apply with -json. Only the fields of the event types rendered into
+// the log are decoded.
+type uiEvent struct {
+	Level   string `json:"@level"`
+	Message string `json:"@message"`
+	Type    string `json:"type"`
+	Hook    struct {
+		Resource struct {
+			Addr string `json:"addr"`
+		} `json:"resource"`
+		Action         string  `json:"action"`
+		ElapsedSeconds float64 `json:"elapsed_seconds"`
+	} `json:"hook"`
+	Change struct {
+		Resource struct {
+			Addr string `json:"addr"`
+		} `json:"resource"`
+		Action string `json:"action"`
+	} `json:"change"`
+	Diagnostic *uiDiagnostic `json:"diagnostic"`
+}
+
+// uiDiagnostic is the diagnostic of a diagnostic event.
+type uiDiagnostic struct {
+	tfjson.Diagnostic
+	Address string `json:"address"`
+}
+
+// eventWriter renders terraform's -json UI stream as concise progress lines
+// and collects its diagnostics. Lines that are not events are written as they are.
+type eventWriter struct {
+	w           io.Writer
+	partial     []byte
+	diagnostics []Diagnostic
+}
+
+// newEventWriter returns an eventWriter that writes progress lines to w.
+func newEventWriter(w io.Writer) *eventWriter {
+	return &eventWriter{w: w}
+}
+
+// Write renders every complete line of the stream, keeping any partial line
+// until the rest of it is written.
+func (e *eventWriter) Write(p []byte) (int, error) {
+	e.partial = append(e.partial, p...)
+	for {
+		i := bytes.IndexByte(e.partial, '\n')
+		if i < 0 {
+			return len(p), nil
+		}
+		line := e.partial[:i]
+		e.partial = e.partial[i+1:]
+		if err := e.render(line); err != nil {
+			return len(p), err
+		}
+	}
+}
+
+// Flush renders a final line that did not end with a newline.
+func (e *eventWriter) Flush() error {
+	if len(e.partial) == 0 {
+		return nil
+	}
+	line := e.partial
+	e.partial = nil
+	return e.render(line)
+}
+
+// render writes the progress line for one line of the stream.
+func (e *eventWriter) render(line []byte) error {
+	line = bytes.TrimSpace(line)
+	if len(line) == 0 {
+		return nil
+	}
+	var event uiEvent
+	if err := json.Unmarshal(line, &event); err != nil {
+		_, err = fmt.Fprintf(e.w, "%s\n", line)
+		return err
+	}
+	var progress string
+	switch event.Type {
+	case "planned_change":
+		progress = withDefault(event.Message, "%s: plan to %s", event.Change.Resource.Addr, event.Change.Action)
+	case "apply_start":
+		progress = withDefault(event.Message, "%s: %s started", event.Hook.Resource.Addr, event.Hook.Action)
+	case "apply_complete":
+		progress = withDefault(event.Message, "%s: %s complete after %gs",
+			event.Hook.Resource.Addr, event.Hook.Action, event.Hook.ElapsedSeconds)
+	case "apply_errored":
+		progress = withDefault(event.Message, "%s: %s errored after %gs",
+			event.Hook.Resource.Addr, event.Hook.Action, event.Hook.ElapsedSeconds)
+	case "change_summary":
+		progress = event.Message
+	case "diagnostic":
+		if event.Diagnostic == nil {
+			return nil
+		}
+		diagnostic := newDiagnostic(event.Diagnostic)
+		e.diagnostics = append(e.diagnostics, diagnostic)
+		progress = diagnostic.String()
+	default:
+		return nil
+	}
+	_, err := fmt.Fprintf(e.w, "%s\n", progress)
+	return err
+}
+
+// Diagnostics returns every diagnostic in the stream.
+func (e *eventWriter) Diagnostics() []Diagnostic {
+	return e.diagnostics
+}
+
+// wrap adds the summaries of the error diagnostics in the stream to err, as
+// terraform reports errors on stdout rather than stderr with -json. This
+// keeps them in the workspace's error and lets retry patterns match them.
+func (e *eventWriter) wrap(err error) error {
+	if err == nil {
+		return nil
+	}
+	var summaries []string
+	for _, diagnostic := range e.diagnostics {
+		if diagnostic.Severity == string(tfjson.DiagnosticSeverityError) {
+			summaries = append(summaries, diagnostic.String())
+		}
+	}
+	if len(summaries) == 0 {
+		return err
+	}
+	return fmt.Errorf("%w\n%s", err, strings.Join(summaries, "\n"))
+}
+
+// withDefault returns message, or the formatted default when terraform did not send one.
+func withDefault(message string, format string, a ...any) string {
+	if message != "" {
+		return message
+	}
+	return fmt.Sprintf(format, a...)
+}
+
+// newDiagnostic converts a diagnostic of the UI stream.
+func newDiagnostic(d *uiDiagnostic) Diagnostic {
+	diagnostic := Diagnostic{
+		Severity: string(d.Severity),
+		Summary:  d.Summary,
+		Detail:   d.Detail,
+		Address:  d.Address,
+	}
+	if d.Range != nil {
+		diagnostic.Filename = d.Range.Filename
+		diagnostic.StartLine = d.Range.Start.Line
+		diagnostic.EndLine = d.Range.End.Line
+	}
+	return diagnostic
+}
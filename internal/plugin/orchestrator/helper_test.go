@@ -0,0 +1,150 @@
+package orchestrator_test
+
+import (
+	"context"
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/cultureamp/terraform-buildkite-plugin/internal/adapters/outputs"
+	"github.com/cultureamp/terraform-buildkite-plugin/internal/adapters/validators"
+	tfjson "github.com/hashicorp/terraform-json"
+	"github.com/rs/zerolog"
+	"github.com/rs/zerolog/log"
+	"github.com/stretchr/testify/require"
+)
+
+func TestMain(m *testing.M) {
+	//nolint:reassign // sinencing the global logger to avoid output during tests
+	log.Logger = zerolog.New(nil)
+	m.Run()
+}
+
+// recordingOutputer records every stage it is asked to output.
+type recordingOutputer struct {
+	stages []outputs.Stage
+	data   []*outputs.Data
+	err    error
+}
+
+func (r *recordingOutputer) Ouput(_ context.Context, _ *tfjson.Plan, stage outputs.Stage, data any) error {
+	r.stages = append(r.stages, stage)
+	if d, ok := data.(*outputs.Data); ok {
+		r.data = append(r.data, d)
+	}
+	return r.err
+}
+
+// recordingValidator returns a fixed result and counts the plans it validates.
+type recordingValidator struct {
+	passed bool
+	calls  int
+}
+
+func (r *recordingValidator) Validate(_ context.Context, _ *tfjson.Plan) (validators.ValidationResult, error) {
+	r.calls++
+	return validators.ValidationResult{Passed: r.passed}, nil
+}
+
+// testPlanJSON is a plan with a single resource to create, as printed by terraform show -json.
+const testPlanJSON = `{
+  "format_version": "1.2",
+  "terraform_version": "1.9.0",
+  "resource_changes": [
+    {
+      "address": "aws_s3_bucket.logs",
+      "type": "aws_s3_bucket",
+      "name": "logs",
+      "change": {"actions": ["create"]}
+    }
+  ]
+}`
+
+// fakeTerraform is a fake terraform binary that records its arguments.
+type fakeTerraform struct {
+	ExecPath string
+	argsLog  string
+}
+
+// fakeTerraformOptions controls the exit codes and output of a fake terraform binary.
+type fakeTerraformOptions struct {
+	// PlanExit is the plan exit code: 0 for no changes, 1 for an error and 2 for changes.
+	PlanExit  int
+	ApplyExit int
+	// Plan is printed by show, defaulting to testPlanJSON.
+	Plan string
+	// Version is the reported terraform version, defaulting to 1.9.0.
+	Version string
+	// PlanSleep delays plan by a number of seconds, until it is interrupted.
+	PlanSleep int
+	// PlanFailures is the number of times plan fails with PlanError before
+	// exiting with PlanExit.
+	PlanFailures int
+	PlanError    string
+	// PlanEvents and ApplyEvents are printed by plan and apply when run with -json.
+	PlanEvents  string
+	ApplyEvents string
+}
+
+// newFakeTerraform writes a fake terraform binary that reports the configured
+// version, exits with the configured codes and logs every invocation.
+func newFakeTerraform(t *testing.T, opts fakeTerraformOptions) *fakeTerraform {
+	t.Helper()
+	dir := t.TempDir()
+	if opts.Plan == "" {
+		opts.Plan = testPlanJSON
+	}
+	if opts.Version == "" {
+		opts.Version = "1.9.0"
+	}
+	planFile := filepath.Join(dir, "plan.json")
+	planCount := filepath.Join(dir, "plan.count")
+	planEvents := filepath.Join(dir, "plan.events")
+	applyEvents := filepath.Join(dir, "apply.events")
+	require.NoError(t, os.WriteFile(planFile, []byte(opts.Plan), 0o600))
+	require.NoError(t, os.WriteFile(planEvents, []byte(opts.PlanEvents), 0o600))
+	require.NoError(t, os.WriteFile(applyEvents, []byte(opts.ApplyEvents), 0o600))
+	f := &fakeTerraform{
+		ExecPath: filepath.Join(dir, "terraform"),
+		argsLog:  filepath.Join(dir, "args.log"),
+	}
+	script := fmt.Sprintf(`#!/bin/sh
+echo "$*" >> %q
+case "$1" in
+version) echo '{"terraform_version":"%s","platform":"linux_amd64","provider_selections":{},"terraform_outdated":false}' ;;
+init) echo "Terraform has been successfully initialized!" ;;
+plan)
+  echo x >> %q
+  if [ "$(wc -l < %q)" -le %d ]; then echo %q >&2; exit 1; fi
+  case "$*" in *-json*) cat %q ;; *) echo "Terraform will perform the following actions:" ;; esac
+  [ %d -gt 0 ] && exec sleep %d
+  exit %d ;;
+show) cat %q ;;
+apply)
+  case "$*" in *-json*) cat %q ;; esac
+  exit %d ;;
+esac
+`, f.argsLog, opts.Version, planCount, planCount, opts.PlanFailures, opts.PlanError, planEvents,
+		opts.PlanSleep, opts.PlanSleep, opts.PlanExit, planFile, applyEvents, opts.ApplyExit)
+	require.NoError(t, os.WriteFile(f.ExecPath, []byte(script), 0o700))
+	return f
+}
+
+// Calls returns the arguments of every invocation of the given subcommand.
+func (f *fakeTerraform) Calls(t *testing.T, subcommand string) []string {
+	t.Helper()
+	data, err := os.ReadFile(f.argsLog)
+	if os.IsNotExist(err) {
+		return nil
+	}
+	require.NoError(t, err)
+	var calls []string
+	for _, line := range strings.Split(strings.TrimSpace(string(data)), "\n") {
+		if strings.HasPrefix(line, subcommand+" ") || line == subcommand {
+			calls = append(calls, line)
+		}
+	}
+	return calls
+}
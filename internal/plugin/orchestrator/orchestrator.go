@@ -2,40 +2,84 @@ package orchestrator
 
 import (
 	"context"
+	"errors"
 	"fmt"
-	"os"
-	"os/exec"
+	"io"
+	"maps"
 	"path"
+	"path/filepath"
+	"regexp"
+	"slices"
+	"time"
 
 	"github.com/hashicorp/terraform-exec/tfexec"
 	tfjson "github.com/hashicorp/terraform-json"
 	"github.com/rs/zerolog/log"
 
-	o "github.com/cultureamp/terraform-buildkite-plugin/internal/adapters/outputs"
+	"github.com/cultureamp/terraform-buildkite-plugin/internal/adapters/artifacts"
+	out "github.com/cultureamp/terraform-buildkite-plugin/internal/adapters/outputs"
+	"github.com/cultureamp/terraform-buildkite-plugin/internal/adapters/plansummary"
+	"github.com/cultureamp/terraform-buildkite-plugin/internal/adapters/runner"
+	"github.com/cultureamp/terraform-buildkite-plugin/internal/adapters/terraform"
 	v "github.com/cultureamp/terraform-buildkite-plugin/internal/adapters/validators"
 	c "github.com/cultureamp/terraform-buildkite-plugin/internal/config"
 	a "github.com/cultureamp/terraform-buildkite-plugin/pkg/buildkite/agent"
 )
 
-type WorkspaceResult struct {
-	Success    bool
-	Stage      string
-	WorkingDir string
-	Error      interface{}
-}
-
 type PluginOrchestrator interface {
 	Plan(ctx context.Context, workingDir string) *WorkspaceResult
 	Apply(ctx context.Context, workingDir string) *WorkspaceResult
+	Destroy(ctx context.Context, workingDir string) *WorkspaceResult
+	Drift(ctx context.Context, workingDir string) *WorkspaceResult
 	Run(ctx context.Context, workingDir string) *WorkspaceResult
 }
 
+// applyStages describes the output stages reported when a plan is applied.
+type applyStages struct {
+	name        string    // Name of the terraform operation used in messages
+	step        Step      // Step of the apply in workspace results
+	planFailure out.Stage // Reported when initializing or planning fails
+	noChanges   out.Stage // Reported when the plan has no changes
+	planned     out.Stage // Reported once a plan with changes has been produced
+	success     out.Stage // Reported when the plan is applied
+	failure     out.Stage // Reported when applying the plan fails
+	// savedPlan applies the plan uploaded by a previous plan step, when plan artifacts are configured
+	savedPlan bool
+}
+
+//nolint:gochecknoglobals // fixed stage sets for apply and destroy
+var (
+	applyModeStages = applyStages{
+		name:        "apply",
+		step:        StepApply,
+		planFailure: out.PlanFailure,
+		noChanges:   out.PlanSuccessNoChanges,
+		planned:     out.PlanSuccessWithChanges,
+		success:     out.ApplySuccess,
+		failure:     out.ApplyFailure,
+		// apply the exact plan a reviewer approved rather than planning again
+		savedPlan: true,
+	}
+	destroyModeStages = applyStages{
+		name:        "destroy",
+		step:        StepDestroy,
+		planFailure: out.DestroyPlanFailure,
+		noChanges:   out.DestroyPlanSuccessNoChanges,
+		planned:     out.DestroyPlanSuccessWithChanges,
+		success:     out.DestroySuccess,
+		failure:     out.DestroyFailure,
+	}
+)
+
 type orchestratorConfig struct {
-	tExecPath  string
-	agent      a.Agent
-	plugin     *c.Plugin
-	validators []v.Validator
-	outputers  []o.Outputer
+	tExecPath     string
+	runner        runner.Runner
+	agent         a.Agent
+	plugin        *c.Plugin
+	validators    []v.Validator
+	outputers     []out.Outputer
+	planStore     artifacts.PlanStore
+	retryPatterns []*regexp.Regexp
 }
 
 type Option func(*orchestratorConfig)
@@ -58,9 +102,10 @@ func WithAgentInterface(a a.Agent) Option {
 
 // NewOrchestrator creates a new instance of the plugin with the provided configuration options.
 func NewOrchestrator(
+	ctx context.Context,
 	plugin *c.Plugin,
 	validators []v.Validator,
-	outputers []o.Outputer,
+	outputers []out.Outputer,
 	opts ...Option,
 ) (PluginOrchestrator, error) {
 	tExecPath := ""
@@ -77,15 +122,26 @@ func NewOrchestrator(
 	for _, opt := range opts {
 		opt(defaults)
 	}
-	if defaults.tExecPath == "" {
-		log.Debug().Msg("terraform exec path not configured, attempting to find terraform in PATH")
-		p, err := exec.LookPath("terraform")
-		if err != nil {
-			log.Error().Err(err).Str("PATH", os.Getenv("PATH")).Msg("terraform binary not found in PATH")
-			return nil, fmt.Errorf("terraform binary not found in PATH: %w", err)
+	if plugin.PlanArtifacts != nil {
+		defaults.planStore = artifacts.NewPlanStore(plugin.PlanArtifacts, artifacts.WithAgent(defaults.agent))
+	}
+	flavour := runner.Terraform
+	var runnerOpts []runner.Option
+	if ti := plugin.Terraform; ti != nil {
+		if ti.Flavour != "" {
+			flavour = runner.Flavour(ti.Flavour)
+		}
+		if ti.VersionsDir != nil {
+			runnerOpts = append(runnerOpts, runner.WithVersionsDir(*ti.VersionsDir))
 		}
-		log.Debug().Str("terraform_path", p).Msg("found terraform binary in PATH")
-		defaults.tExecPath = p
+	}
+	r, err := runner.New(ctx, flavour, defaults.tExecPath, runnerOpts...)
+	if err != nil {
+		return nil, err
+	}
+	defaults.runner = r
+	if defaults.retryPatterns, err = plugin.Retry.RetryablePatterns(); err != nil {
+		return nil, err
 	}
 	return defaults, nil
 }
@@ -99,112 +155,547 @@ func (o *orchestratorConfig) Run(
 		return o.Plan(ctx, workingDir)
 	case c.Apply:
 		return o.Apply(ctx, workingDir)
+	case c.Destroy:
+		return o.Destroy(ctx, workingDir)
+	case c.Drift:
+		return o.Drift(ctx, workingDir)
 	default:
-		return &WorkspaceResult{
+		return o.finish(ctx, out.UnexpectedFailure, o.newOutputData(workingDir), &WorkspaceResult{
 			Success:    false,
-			Stage:      "validation",
+			Step:       StepConfiguration,
 			WorkingDir: workingDir,
-			Error:      fmt.Sprintf("unsupported plugin mode: %s", o.plugin.Mode),
-		}
+			Error:      fmt.Errorf("unsupported plugin mode: %s", o.plugin.Mode),
+		})
 	}
 }
 
-func (o *orchestratorConfig) Plan(ctx context.Context, workingDir string) *WorkspaceResult {
+func (o *orchestratorConfig) Plan(ctx context.Context, workingDir string) (result *WorkspaceResult) {
+	ctx, r := withRecorder(ctx)
+	defer r.attach(&result)
 	planFile := path.Join(workingDir, "plan.binary")
-	tf, result := o.initSteps(ctx, workingDir)
+	data := o.newOutputData(workingDir)
+	tf, result := o.initStage(ctx, workingDir)
 	if result != nil {
-		return result
+		return o.finish(ctx, out.PlanFailure, data, result)
 	}
-	planJSON, result := o.planSteps(ctx, tf, planFile, workingDir)
+	planJSON, result := o.planStage(ctx, tf, planFile, workingDir)
 	if result != nil {
-		return result
+		if result.Success {
+			if publishResult := o.publishSteps(ctx, tf, planFile, workingDir, nil); publishResult != nil {
+				return o.finish(ctx, out.PlanFailure, data, publishResult)
+			}
+		}
+		return o.finish(ctx, applyModeStages.planResultStage(result), data, result)
 	}
-	result = o.validateSteps(ctx, planJSON, workingDir)
+	withPlan(ctx, data, planJSON)
+	outputErr := o.emit(ctx, out.PlanSuccessWithChanges, data)
+	result = o.validateStage(ctx, planJSON, workingDir, data)
 	if result != nil {
-		return result
+		return o.finish(ctx, out.ValidationFailure, data, result, outputErr)
 	}
-	return &WorkspaceResult{
+	if result = o.publishSteps(ctx, tf, planFile, workingDir, planJSON); result != nil {
+		return o.finish(ctx, out.PlanFailure, data, result, outputErr)
+	}
+	result = &WorkspaceResult{
 		Success:    true,
-		Stage:      "planning",
+		Step:       StepPlan,
 		WorkingDir: workingDir,
-		Error:      nil,
 	}
+	if len(o.validators) == 0 {
+		// the plan stage has already been reported
+		o.describe(out.PlanSuccessWithChanges, data, result)
+		return withOutputError(result, outputErr)
+	}
+	return o.finish(ctx, out.ValidationSuccess, data, result, outputErr)
 }
 
 func (o *orchestratorConfig) Apply(ctx context.Context, workingDir string) *WorkspaceResult {
+	return o.planAndApply(ctx, workingDir, applyModeStages)
+}
+
+// Destroy plans a destroy of every resource in the working directory,
+// validates that plan and then applies it.
+func (o *orchestratorConfig) Destroy(ctx context.Context, workingDir string) *WorkspaceResult {
+	return o.planAndApply(ctx, workingDir, destroyModeStages, tfexec.Destroy(true))
+}
+
+func (o *orchestratorConfig) planAndApply(
+	ctx context.Context,
+	workingDir string,
+	stages applyStages,
+	planOpts ...tfexec.PlanOption,
+) (result *WorkspaceResult) {
+	ctx, r := withRecorder(ctx)
+	defer r.attach(&result)
 	planFile := path.Join(workingDir, "plan.binary")
-	tf, result := o.initSteps(ctx, workingDir)
+	data := o.newOutputData(workingDir)
+	tf, result := o.initStage(ctx, workingDir)
 	if result != nil {
-		return result
+		return o.finish(ctx, stages.planFailure, data, result)
+	}
+	var planJSON *tfjson.Plan
+	if stages.savedPlan && o.planStore != nil {
+		result = runStage(
+			ctx,
+			StepPlan,
+			workingDir,
+			o.plugin.Timeouts.PlanTimeout(),
+			func(ctx context.Context) *WorkspaceResult {
+				planFile, planJSON, result = o.retrieveSteps(ctx, tf, workingDir)
+				return result
+			},
+		)
+	} else {
+		planJSON, result = o.planStage(ctx, tf, planFile, workingDir, planOpts...)
 	}
-	planJSON, result := o.planSteps(ctx, tf, planFile, workingDir)
 	if result != nil {
-		return result
+		return o.finish(ctx, stages.planResultStage(result), data, result)
 	}
-	result = o.validateSteps(ctx, planJSON, workingDir)
+	withPlan(ctx, data, planJSON)
+	outputErr := o.emit(ctx, stages.planned, data)
+	result = o.validateStage(ctx, planJSON, workingDir, data)
 	if result != nil {
-		return result
+		return o.finish(ctx, out.ValidationFailure, data, result, outputErr)
 	}
-	if err := tf.Apply(ctx, tfexec.DirOrPlan(planFile)); err != nil {
-		log.Error().
-			Err(err).
+	if len(o.validators) > 0 {
+		outputErr = errors.Join(outputErr, o.emit(ctx, out.ValidationSuccess, data))
+	}
+	result = runStage(
+		ctx,
+		stages.step,
+		workingDir,
+		o.plugin.Timeouts.ApplyTimeout(),
+		func(ctx context.Context) *WorkspaceResult {
+			opts := append(o.applyOptions(), tfexec.DirOrPlan(planFile))
+			err := stream(ctx, tf, func(w io.Writer) error {
+				if !o.jsonOutput(ctx, tf) {
+					return tf.Apply(ctx, opts...)
+				}
+				events := newEventWriter(w)
+				err := tf.ApplyJSON(ctx, events, opts...)
+				recordDiagnostics(ctx, events)
+				return events.wrap(err)
+			})
+			if err == nil {
+				return nil
+			}
+			log.Ctx(ctx).Error().
+				Err(err).
+				Str("working_dir", workingDir).
+				Str("plan_file", planFile).
+				Msgf("terraform %s failed", stages.name)
+			return &WorkspaceResult{
+				Success:    false,
+				Step:       stages.step,
+				WorkingDir: workingDir,
+				Error:      fmt.Errorf("failed to %s Terraform plan: %w", stages.name, err),
+			}
+		},
+	)
+	if result != nil {
+		return o.finish(ctx, stages.failure, data, result, outputErr)
+	}
+	return o.finish(ctx, stages.success, data, &WorkspaceResult{
+		Success:    true,
+		Step:       stages.step,
+		WorkingDir: workingDir,
+	}, outputErr)
+}
+
+// Drift runs a refresh-only plan and reports any resources that have drifted
+// from their recorded state. It never applies anything.
+func (o *orchestratorConfig) Drift(ctx context.Context, workingDir string) (result *WorkspaceResult) {
+	ctx, r := withRecorder(ctx)
+	defer r.attach(&result)
+	planFile := path.Join(workingDir, "plan.binary")
+	data := o.newOutputData(workingDir)
+	tf, result := o.initStage(ctx, workingDir)
+	if result != nil {
+		return o.finish(ctx, out.PlanFailure, data, result)
+	}
+	planJSON, result := o.planStage(ctx, tf, planFile, workingDir, tfexec.RefreshOnly(true))
+	if result != nil {
+		if result.Success {
+			return o.finish(ctx, out.NoDriftDetected, data, result)
+		}
+		return o.finish(ctx, out.PlanFailure, data, result)
+	}
+	drifted := driftedResources(planJSON)
+	data.Plan = planJSON
+	data.Summary = plansummary.New(planJSON)
+	if len(drifted) == 0 {
+		// a refresh-only plan also reports changes when only outputs differ
+		log.Ctx(ctx).Info().
 			Str("working_dir", workingDir).
-			Str("plan_file", planFile).
-			Msg("terraform apply failed")
-		return &WorkspaceResult{
-			Success:    false,
-			Stage:      "applying",
+			Msg("refresh-only plan has changes but no resources drifted")
+		return o.finish(ctx, out.NoDriftDetected, data, &WorkspaceResult{
+			Success:    true,
+			Step:       StepDrift,
 			WorkingDir: workingDir,
-			Error:      fmt.Sprintf("failed to apply Terraform plan: %v", err),
+		})
+	}
+	log.Ctx(ctx).Warn().
+		Str("working_dir", workingDir).
+		Strs("resources", drifted).
+		Msg("drift detected")
+	data.DriftedResources = drifted
+	return o.finish(ctx, out.DriftDetected, data, &WorkspaceResult{
+		Success:          true,
+		Step:             StepDrift,
+		WorkingDir:       workingDir,
+		Drifted:          true,
+		DriftedResources: drifted,
+	})
+}
+
+// driftedResources returns the addresses of resources in the plan's resource
+// drift section whose recorded state no longer matches the real infrastructure.
+func driftedResources(plan *tfjson.Plan) []string {
+	var addresses []string
+	for _, rc := range plan.ResourceDrift {
+		if rc == nil || rc.Change == nil || rc.Change.Actions.NoOp() {
+			continue
 		}
+		addresses = append(addresses, rc.Address)
 	}
-	return &WorkspaceResult{
-		Success:    true,
-		Stage:      "apply",
+	return addresses
+}
+
+// planChanges counts the resource changes of a plan summary the way Terraform
+// reports them, with replacements counted as both an add and a destroy.
+func planChanges(summary *plansummary.Summary) *PlanChanges {
+	return &PlanChanges{
+		Add:     summary.Create + summary.Replace,
+		Change:  summary.Update,
+		Destroy: summary.Delete + summary.Replace,
+	}
+}
+
+// withPlan adds a plan and its summary to the output data, and prints the
+// summary in the workspace's output.
+func withPlan(ctx context.Context, data *out.Data, plan *tfjson.Plan) {
+	data.Plan = plan
+	data.Summary = plansummary.New(plan)
+	log.Ctx(ctx).Info().
+		Str("working_dir", data.WorkingDir).
+		Stringer("changes", data.Summary.Counts).
+		Msg("plan summary")
+	if _, err := io.WriteString(output(ctx), data.Summary.Format()); err != nil {
+		log.Ctx(ctx).Warn().Err(err).Msg("failed to write plan summary")
+	}
+}
+
+// newOutputData creates the outputer payload for a working directory.
+func (o *orchestratorConfig) newOutputData(workingDir string) *out.Data {
+	return &out.Data{
+		Workspace:  filepath.Base(workingDir),
 		WorkingDir: workingDir,
-		Error:      nil,
+		Mode:       string(o.plugin.Mode),
 	}
 }
 
-func (o *orchestratorConfig) newTerraform(workingDir string) (*tfexec.Terraform, error) {
-	log.Debug().
-		Str("working_dir", workingDir).
-		Str("terraform_exec_path", o.tExecPath).
-		Msg("creating terraform executor")
-	tf, err := tfexec.NewTerraform(workingDir, o.tExecPath)
+// emit sends the stage to every configured outputer.
+//
+// Outputer errors are logged and joined rather than returned early so that
+// one failing outputer does not prevent the others from running. Outputers
+// still run once the job has been cancelled, so the interruption is reported.
+func (o *orchestratorConfig) emit(ctx context.Context, stage out.Stage, data *out.Data) error {
+	ctx = context.WithoutCancel(ctx)
+	data.Stage = stage
+	var errs []error
+	for _, outputer := range o.outputers {
+		if err := outputer.Ouput(ctx, data.Plan, stage, data); err != nil {
+			log.Ctx(ctx).Error().
+				Err(err).
+				Str("working_dir", data.WorkingDir).
+				Str("stage", string(stage)).
+				Str("outputer", fmt.Sprintf("%T", outputer)).
+				Msg("outputer failed")
+			errs = append(errs, fmt.Errorf("outputer %T failed for stage %s: %w", outputer, stage, err))
+		}
+	}
+	return errors.Join(errs...)
+}
+
+// finish emits the final stage for a workspace and attaches any outputer
+// errors to the result without changing its outcome.
+func (o *orchestratorConfig) finish(
+	ctx context.Context,
+	stage out.Stage,
+	data *out.Data,
+	result *WorkspaceResult,
+	outputErrs ...error,
+) *WorkspaceResult {
+	o.describe(stage, data, result)
+	if result.Error != nil {
+		data.Error = result.Error.Error()
+	}
+	outputErrs = append(outputErrs, o.emit(ctx, stage, data))
+	return withOutputError(result, outputErrs...)
+}
+
+// describe fills in the parts of a result that come from the stage reported
+// for it and the output data gathered along the way.
+func (o *orchestratorConfig) describe(stage out.Stage, data *out.Data, result *WorkspaceResult) {
+	result.Stage = stage
+	result.Validations = data.Validations
+	if data.Summary != nil && o.plugin.Mode != c.Drift {
+		result.HasChanges = true
+		result.Changes = planChanges(data.Summary)
+	}
+}
+
+// withOutputError joins outputer errors onto the result.
+func withOutputError(result *WorkspaceResult, errs ...error) *WorkspaceResult {
+	result.OutputError = errors.Join(append([]error{result.OutputError}, errs...)...)
+	return result
+}
+
+// planResultStage maps a result returned early by planSteps or retrieveSteps to its output stage.
+func (s applyStages) planResultStage(result *WorkspaceResult) out.Stage {
+	if result.Success {
+		return s.noChanges
+	}
+	return s.planFailure
+}
+
+// runStage runs a stage within its timeout, when one is configured, and marks a
+// failed result as timed out or cancelled when the stage was interrupted. Each
+// stage opens a collapsed log group, which is expanded when the stage fails.
+//
+// Terraform processes are sent an interrupt when the stage context ends, so
+// that they stop cleanly and release any state lock they hold.
+func runStage(
+	ctx context.Context,
+	step Step,
+	workingDir string,
+	timeout time.Duration,
+	fn func(ctx context.Context) *WorkspaceResult,
+) *WorkspaceResult {
+	groups := openGroup(ctx, step, workingDir)
+	stageCtx := ctx
+	if timeout > 0 {
+		var cancel context.CancelFunc
+		stageCtx, cancel = context.WithTimeout(ctx, timeout)
+		defer cancel()
+	}
+	start := time.Now()
+	result := fn(stageCtx)
+	if r, ok := ctx.Value(recorderKey{}).(*recorder); ok {
+		r.durations[step] += time.Since(start)
+	}
+	if result == nil || result.Success {
+		return result
+	}
+	groups.OpenCurrent()
+	switch {
+	case ctx.Err() != nil:
+		log.Ctx(ctx).Warn().Str("working_dir", result.WorkingDir).Str("stage", string(step)).Msg("stage was cancelled")
+		result.Cancelled = true
+		result.Error = fmt.Errorf("%s was cancelled: %w", step, result.Error)
+	case errors.Is(stageCtx.Err(), context.DeadlineExceeded):
+		log.Ctx(ctx).Warn().
+			Str("working_dir", result.WorkingDir).
+			Str("stage", string(step)).
+			Dur("timeout", timeout).
+			Msg("stage timed out")
+		result.TimedOut = true
+		result.Error = fmt.Errorf("%s timed out after %s: %w", step, timeout, result.Error)
+	}
+	return result
+}
+
+// recorderKey is the context key of the recorder of a workspace.
+type recorderKey struct{}
+
+// recorder collects the stage durations, the attempts of retried commands and
+// the diagnostics of streamed commands for one workspace.
+type recorder struct {
+	durations   map[Step]time.Duration
+	attempts    []Attempt
+	diagnostics []Diagnostic
+}
+
+// withRecorder attaches a new recorder to ctx.
+func withRecorder(ctx context.Context) (context.Context, *recorder) {
+	r := &recorder{durations: map[Step]time.Duration{}}
+	return context.WithValue(ctx, recorderKey{}, r), r
+}
+
+// attach copies the recorded durations, attempts and diagnostics onto a workspace result.
+func (r *recorder) attach(result **WorkspaceResult) {
+	if *result == nil {
+		return
+	}
+	(*result).Attempts = r.attempts
+	(*result).Diagnostics = r.diagnostics
+	if len(r.durations) > 0 {
+		(*result).Durations = r.durations
+	}
+}
+
+// retry runs a terraform command until it succeeds, fails with an error that
+// does not match a retryable pattern, or runs out of attempts. The wait
+// between attempts starts at the configured backoff and doubles each time.
+// Every attempt is recorded in the workspace's attempt log.
+func (o *orchestratorConfig) retry(
+	ctx context.Context,
+	command string,
+	workingDir string,
+	fn func(ctx context.Context) error,
+) error {
+	r, _ := ctx.Value(recorderKey{}).(*recorder)
+	maxAttempts := o.plugin.Retry.MaxAttempts()
+	backoff := o.plugin.Retry.InitialBackoff()
+	for number := 1; ; number++ {
+		start := time.Now()
+		err := fn(ctx)
+		if r != nil {
+			attempt := Attempt{Command: command, Number: number, Duration: time.Since(start)}
+			if err != nil {
+				attempt.Error = err.Error()
+			}
+			r.attempts = append(r.attempts, attempt)
+		}
+		if err == nil || number >= maxAttempts || ctx.Err() != nil || !o.retryable(err) {
+			return err
+		}
+		log.Ctx(ctx).Warn().
+			Err(err).
+			Str("working_dir", workingDir).
+			Str("command", command).
+			Int("attempt", number).
+			Int("max_attempts", maxAttempts).
+			Dur("backoff", backoff).
+			Msg("retrying terraform command after transient failure")
+		select {
+		case <-ctx.Done():
+			return err
+		case <-time.After(backoff):
+		}
+		backoff *= 2
+	}
+}
+
+// retryable reports whether an error matches a retryable pattern.
+func (o *orchestratorConfig) retryable(err error) bool {
+	message := err.Error()
+	for _, pattern := range o.retryPatterns {
+		if pattern.MatchString(message) {
+			return true
+		}
+	}
+	return false
+}
+
+// initStage runs initSteps within the init timeout.
+func (o *orchestratorConfig) initStage(ctx context.Context, workingDir string) (*tfexec.Terraform, *WorkspaceResult) {
+	var tf *tfexec.Terraform
+	result := runStage(
+		ctx,
+		StepInit,
+		workingDir,
+		o.plugin.Timeouts.InitTimeout(),
+		func(ctx context.Context) *WorkspaceResult {
+			var result *WorkspaceResult
+			tf, result = o.initSteps(ctx, workingDir)
+			return result
+		},
+	)
+	return tf, result
+}
+
+// planStage runs planSteps within the plan timeout.
+func (o *orchestratorConfig) planStage(
+	ctx context.Context,
+	tf *tfexec.Terraform,
+	planFile string,
+	workingDir string,
+	opts ...tfexec.PlanOption,
+) (*tfjson.Plan, *WorkspaceResult) {
+	var plan *tfjson.Plan
+	result := runStage(
+		ctx,
+		StepPlan,
+		workingDir,
+		o.plugin.Timeouts.PlanTimeout(),
+		func(ctx context.Context) *WorkspaceResult {
+			var result *WorkspaceResult
+			plan, result = o.planSteps(ctx, tf, planFile, workingDir, opts...)
+			return result
+		},
+	)
+	return plan, result
+}
+
+// validateStage runs validateSteps within the validation timeout, when there are validators.
+func (o *orchestratorConfig) validateStage(
+	ctx context.Context,
+	plan *tfjson.Plan,
+	workingDir string,
+	data *out.Data,
+) *WorkspaceResult {
+	if len(o.validators) == 0 {
+		return nil
+	}
+	return runStage(
+		ctx,
+		StepValidation,
+		workingDir,
+		o.plugin.Timeouts.ValidationTimeout(),
+		func(ctx context.Context) *WorkspaceResult {
+			return o.validateSteps(ctx, plan, workingDir, data)
+		},
+	)
+}
+
+func (o *orchestratorConfig) newTerraform(ctx context.Context, workingDir string) (*tfexec.Terraform, error) {
+	tf, err := o.runner.New(ctx, workingDir)
 	if err != nil {
-		log.Error().
+		log.Ctx(ctx).Error().
 			Err(err).
 			Str("working_dir", workingDir).
-			Str("terraform_exec_path", o.tExecPath).
-			Msg("failed to create terraform executor")
-		return nil, fmt.Errorf("failed to create Terraform runner: %w", err)
+			Str("flavour", string(o.runner.Flavour())).
+			Str("exec_path", o.runner.ExecPath()).
+			Msg("failed to create executor")
+		return nil, err
 	}
 	return tf, nil
 }
 
 func (o *orchestratorConfig) initSteps(ctx context.Context, workingDir string) (*tfexec.Terraform, *WorkspaceResult) {
-	tf, err := o.newTerraform(workingDir)
+	tf, err := o.newTerraform(ctx, workingDir)
 	if err != nil {
 		return nil, &WorkspaceResult{
 			Success:    false,
-			Stage:      "initialization",
+			Step:       StepInit,
 			WorkingDir: workingDir,
-			Error:      fmt.Sprintf("failed to initialize Terraform: %v", err),
+			Error:      fmt.Errorf("failed to initialize Terraform: %w", err),
 		}
 	}
-	var initOpts []tfexec.InitOption
-	if ti := o.plugin.Terraform; ti != nil && ti.InitOptions != nil {
-		opts := ti.InitOptions
-		if opts.Get != nil {
-			initOpts = append(initOpts, tfexec.Get(*opts.Get))
-		}
-		if opts.PluginDir != nil {
-			initOpts = append(initOpts, tfexec.PluginDir(*opts.PluginDir))
+	if result := o.versionSteps(ctx, tf, workingDir); result != nil {
+		return nil, result
+	}
+	initOpts, err := o.initOptions(workingDir)
+	if err != nil {
+		log.Ctx(ctx).Error().
+			Err(err).
+			Str("working_dir", workingDir).
+			Msg("invalid terraform init options")
+		return nil, &WorkspaceResult{
+			Success:    false,
+			Step:       StepInit,
+			WorkingDir: workingDir,
+			Error:      fmt.Errorf("invalid init options: %w", err),
 		}
 	}
-	if err = tf.Init(ctx, initOpts...); err != nil {
-		log.Error().
+	err = o.retry(ctx, "init", workingDir, func(ctx context.Context) error {
+		return stream(ctx, tf, func(io.Writer) error {
+			return tf.Init(ctx, initOpts...)
+		})
+	})
+	if err != nil {
+		log.Ctx(ctx).Error().
 			Err(err).
 			Str("working_dir", workingDir).
 			Str("tfExecPath", tf.ExecPath()).
@@ -212,80 +703,441 @@ func (o *orchestratorConfig) initSteps(ctx context.Context, workingDir string) (
 			Msg("terraform init failed")
 		return nil, &WorkspaceResult{
 			Success:    false,
-			Stage:      "initialization",
+			Step:       StepInit,
 			WorkingDir: workingDir,
-			Error:      fmt.Sprintf("failed to run terraform init: %v", err),
+			Error:      fmt.Errorf("failed to run terraform init: %w", err),
+		}
+	}
+	if ti := o.plugin.Terraform; ti != nil && ti.Workspace != nil {
+		if result := o.workspaceSteps(ctx, tf, workingDir, ti.Workspace); result != nil {
+			return nil, result
 		}
 	}
 	return tf, nil
 }
 
+// versionSteps checks the binary's version against the working directory's version requirements.
+func (o *orchestratorConfig) versionSteps(
+	ctx context.Context,
+	tf *tfexec.Terraform,
+	workingDir string,
+) *WorkspaceResult {
+	requirements, err := runner.RequiredVersions(workingDir, o.runner.Flavour())
+	if err == nil && len(requirements) == 0 {
+		return nil
+	}
+	if err == nil {
+		var caps *runner.Capabilities
+		if caps, err = o.runner.Capabilities(ctx, tf); err == nil {
+			err = runner.CheckVersion(caps.Version, requirements)
+		}
+	}
+	if err != nil {
+		log.Ctx(ctx).Error().
+			Err(err).
+			Str("working_dir", workingDir).
+			Str("exec_path", tf.ExecPath()).
+			Msg("version requirements not met")
+		return &WorkspaceResult{
+			Success:    false,
+			Step:       StepVersion,
+			WorkingDir: workingDir,
+			Error:      fmt.Errorf("version requirements not met: %w", err),
+		}
+	}
+	sources := make([]string, 0, len(requirements))
+	for _, requirement := range requirements {
+		sources = append(sources, requirement.Source)
+	}
+	log.Ctx(ctx).Info().
+		Str("working_dir", workingDir).
+		Strs("sources", sources).
+		Msg("version requirements satisfied")
+	return nil
+}
+
+// initOptions maps the configured init options for a working directory to terraform init options.
+func (o *orchestratorConfig) initOptions(workingDir string) ([]tfexec.InitOption, error) {
+	ti := o.plugin.Terraform
+	if ti == nil || ti.InitOptions == nil {
+		return nil, nil
+	}
+	opts := ti.InitOptions
+	var initOpts []tfexec.InitOption
+	if opts.Get != nil {
+		initOpts = append(initOpts, tfexec.Get(*opts.Get))
+	}
+	if opts.PluginDir != nil {
+		initOpts = append(initOpts, tfexec.PluginDir(*opts.PluginDir))
+	}
+	backendConfig, err := opts.ResolveBackendConfig(workingDir)
+	if err != nil {
+		return nil, err
+	}
+	for _, config := range backendConfig {
+		initOpts = append(initOpts, tfexec.BackendConfig(config))
+	}
+	if opts.Backend != nil {
+		initOpts = append(initOpts, tfexec.Backend(*opts.Backend))
+	}
+	if opts.Reconfigure != nil {
+		initOpts = append(initOpts, tfexec.Reconfigure(*opts.Reconfigure))
+	}
+	if opts.Upgrade != nil {
+		initOpts = append(initOpts, tfexec.Upgrade(*opts.Upgrade))
+	}
+	if opts.Lock != nil {
+		initOpts = append(initOpts, tfexec.Lock(*opts.Lock))
+	}
+	if opts.LockTimeout != "" {
+		initOpts = append(initOpts, tfexec.LockTimeout(opts.LockTimeout))
+	}
+	return initOpts, nil
+}
+
+// workspaceSteps selects the configured Terraform CLI workspace, creating it when it is missing.
+func (o *orchestratorConfig) workspaceSteps(
+	ctx context.Context,
+	tf *tfexec.Terraform,
+	workingDir string,
+	workspace *terraform.Workspace,
+) *WorkspaceResult {
+	fail := func(err error) *WorkspaceResult {
+		log.Ctx(ctx).Error().
+			Err(err).
+			Str("working_dir", workingDir).
+			Msg("terraform workspace selection failed")
+		return &WorkspaceResult{
+			Success:    false,
+			Step:       StepWorkspace,
+			WorkingDir: workingDir,
+			Error:      fmt.Errorf("failed to select Terraform workspace: %w", err),
+		}
+	}
+	name, err := workspace.ResolveName(workingDir)
+	if err != nil {
+		return fail(err)
+	}
+	existing, current, err := tf.WorkspaceList(ctx)
+	if err != nil {
+		return fail(err)
+	}
+	switch {
+	case current == name:
+		log.Ctx(ctx).Debug().Str("workspace", name).Msg("terraform workspace already selected")
+	case slices.Contains(existing, name):
+		log.Ctx(ctx).Info().Str("workspace", name).Msg("selecting terraform workspace")
+		err = tf.WorkspaceSelect(ctx, name)
+	case workspace.ShouldCreate():
+		log.Ctx(ctx).Info().Str("workspace", name).Msg("creating terraform workspace")
+		err = tf.WorkspaceNew(ctx, name)
+	default:
+		err = fmt.Errorf("workspace %q does not exist", name)
+	}
+	if err != nil {
+		return fail(err)
+	}
+	return nil
+}
+
+// planOptions maps the configured plan options for a working directory to terraform plan options.
+func (o *orchestratorConfig) planOptions(workingDir string) ([]tfexec.PlanOption, error) {
+	ti := o.plugin.Terraform
+	if ti == nil || ti.PlanOptions == nil {
+		return nil, nil
+	}
+	opts := ti.PlanOptions
+	varFiles, err := opts.ResolveVarFiles(workingDir)
+	if err != nil {
+		return nil, err
+	}
+	var planOpts []tfexec.PlanOption
+	for _, file := range varFiles {
+		planOpts = append(planOpts, tfexec.VarFile(file))
+	}
+	for _, name := range slices.Sorted(maps.Keys(opts.Vars)) {
+		planOpts = append(planOpts, tfexec.Var(name+"="+opts.Vars[name]))
+	}
+	for _, target := range opts.Targets {
+		planOpts = append(planOpts, tfexec.Target(target))
+	}
+	// a refresh-only plan can neither replace resources nor disable refreshing
+	if o.plugin.Mode != c.Drift {
+		for _, address := range opts.Replace {
+			planOpts = append(planOpts, tfexec.Replace(address))
+		}
+		if opts.Refresh != nil {
+			planOpts = append(planOpts, tfexec.Refresh(*opts.Refresh))
+		}
+	}
+	if opts.Lock != nil {
+		planOpts = append(planOpts, tfexec.Lock(*opts.Lock))
+	}
+	if opts.LockTimeout != "" {
+		planOpts = append(planOpts, tfexec.LockTimeout(opts.LockTimeout))
+	}
+	if opts.Parallelism != nil {
+		planOpts = append(planOpts, tfexec.Parallelism(*opts.Parallelism))
+	}
+	return planOpts, nil
+}
+
+// checkCapabilities reports an error when planning needs a feature the binary does not support.
+func (o *orchestratorConfig) checkCapabilities(
+	ctx context.Context,
+	tf *tfexec.Terraform,
+	opts ...tfexec.PlanOption,
+) error {
+	caps, err := o.runner.Capabilities(ctx, tf)
+	if err != nil {
+		return err
+	}
+	unsupported := func(feature string) error {
+		return fmt.Errorf("%s %s does not support %s", caps.Flavour, caps.Version, feature)
+	}
+	if !caps.PlanJSON {
+		return unsupported("showing plans as JSON")
+	}
+	if o.plugin.Mode == c.Drift && !caps.ResourceDrift {
+		return unsupported("reporting resource drift in plans")
+	}
+	if ti := o.plugin.Terraform; ti != nil && ti.PlanOptions != nil && len(ti.PlanOptions.Replace) > 0 &&
+		o.plugin.Mode != c.Drift && !caps.Replace {
+		return unsupported("-replace")
+	}
+	for _, opt := range opts {
+		if _, ok := opt.(*tfexec.RefreshOnlyOption); ok && !caps.RefreshOnly {
+			return unsupported("-refresh-only")
+		}
+	}
+	return nil
+}
+
+// jsonOutput reports whether plan and apply stream terraform's -json UI
+// stream. Binaries that do not support it stream their plain output instead.
+func (o *orchestratorConfig) jsonOutput(ctx context.Context, tf *tfexec.Terraform) bool {
+	if ti := o.plugin.Terraform; ti == nil || !ti.JSONOutput {
+		return false
+	}
+	caps, err := o.runner.Capabilities(ctx, tf)
+	if err != nil || !caps.JSONOutput {
+		log.Ctx(ctx).Warn().
+			Err(err).
+			Str("exec_path", tf.ExecPath()).
+			Msg("terraform does not support -json output, streaming plain output instead")
+		return false
+	}
+	return true
+}
+
+// applyOptions maps the configured plan options that still apply to a saved plan to terraform apply options.
+// Variables, targets and replacements are part of the saved plan itself.
+func (o *orchestratorConfig) applyOptions() []tfexec.ApplyOption {
+	ti := o.plugin.Terraform
+	if ti == nil || ti.PlanOptions == nil {
+		return nil
+	}
+	opts := ti.PlanOptions
+	var applyOpts []tfexec.ApplyOption
+	if opts.Lock != nil {
+		applyOpts = append(applyOpts, tfexec.Lock(*opts.Lock))
+	}
+	if opts.LockTimeout != "" {
+		applyOpts = append(applyOpts, tfexec.LockTimeout(opts.LockTimeout))
+	}
+	if opts.Parallelism != nil {
+		applyOpts = append(applyOpts, tfexec.Parallelism(*opts.Parallelism))
+	}
+	return applyOpts
+}
+
 func (o *orchestratorConfig) planSteps(
 	ctx context.Context,
 	tf *tfexec.Terraform,
 	planFile string,
 	workingDir string,
+	opts ...tfexec.PlanOption,
 ) (*tfjson.Plan, *WorkspaceResult) {
-	hasChanges, err := tf.Plan(ctx, tfexec.Out(planFile))
+	configured, err := o.planOptions(workingDir)
+	if err == nil {
+		err = o.checkCapabilities(ctx, tf, opts...)
+	}
 	if err != nil {
-		log.Error().
+		log.Ctx(ctx).Error().
+			Err(err).
+			Str("working_dir", workingDir).
+			Msg("invalid terraform plan options")
+		return nil, &WorkspaceResult{
+			Success:    false,
+			Step:       StepPlan,
+			WorkingDir: workingDir,
+			Error:      fmt.Errorf("invalid plan options: %w", err),
+		}
+	}
+	opts = append(append(configured, opts...), tfexec.Out(planFile))
+	var hasChanges bool
+	err = o.retry(ctx, "plan", workingDir, func(ctx context.Context) error {
+		return stream(ctx, tf, func(w io.Writer) error {
+			var planErr error
+			if !o.jsonOutput(ctx, tf) {
+				hasChanges, planErr = tf.Plan(ctx, opts...)
+				return planErr
+			}
+			events := newEventWriter(w)
+			hasChanges, planErr = tf.PlanJSON(ctx, events, opts...)
+			recordDiagnostics(ctx, events)
+			return events.wrap(planErr)
+		})
+	})
+	if err != nil {
+		log.Ctx(ctx).Error().
 			Err(err).
 			Str("working_dir", workingDir).
 			Str("plan_file", planFile).
 			Msg("terraform plan failed")
 		return nil, &WorkspaceResult{
 			Success:    false,
-			Stage:      "planning",
+			Step:       StepPlan,
 			WorkingDir: workingDir,
-			Error:      fmt.Sprintf("failed to run terraform plan: %v", err),
+			Error:      fmt.Errorf("failed to run terraform plan: %w", err),
 		}
 	}
 	if !hasChanges {
 		return nil, &WorkspaceResult{
 			Success:    true,
-			Stage:      "planning",
+			Step:       StepPlan,
 			WorkingDir: workingDir,
-			Error:      "no changes detected in the Terraform plan",
 		}
 	}
 	plan, err := tf.ShowPlanFile(ctx, planFile)
 	if err != nil {
-		log.Error().
+		log.Ctx(ctx).Error().
 			Err(err).
 			Str("working_dir", workingDir).
 			Str("plan_file", planFile).
 			Msg("failed to show terraform plan file")
 		return nil, &WorkspaceResult{
 			Success:    false,
-			Stage:      "showing plan",
+			Step:       StepShowPlan,
 			WorkingDir: workingDir,
-			Error:      fmt.Sprintf("failed to show plan file: %v", err),
+			Error:      fmt.Errorf("failed to show plan file: %w", err),
 		}
 	}
 	return plan, nil
 }
 
+// publishSteps uploads the plan as artifacts when plan artifacts are configured.
+// The plan JSON is nil when the plan has no changes.
+func (o *orchestratorConfig) publishSteps(
+	ctx context.Context,
+	tf *tfexec.Terraform,
+	planFile string,
+	workingDir string,
+	plan *tfjson.Plan,
+) *WorkspaceResult {
+	if o.planStore == nil {
+		return nil
+	}
+	tfVersion, err := terraformVersion(ctx, tf)
+	if err == nil {
+		err = o.planStore.Upload(ctx, workingDir, planFile, plan, tfVersion)
+	}
+	if err != nil {
+		log.Ctx(ctx).Error().
+			Err(err).
+			Str("working_dir", workingDir).
+			Str("plan_file", planFile).
+			Msg("failed to publish terraform plan")
+		return &WorkspaceResult{
+			Success:    false,
+			Step:       StepPublishPlan,
+			WorkingDir: workingDir,
+			Error:      fmt.Errorf("failed to publish plan: %w", err),
+		}
+	}
+	return nil
+}
+
+// retrieveSteps downloads and verifies the plan uploaded by a previous plan step.
+// It refuses to continue if the plan is missing, has been tampered with, is
+// stale or was created by a different version of Terraform.
+func (o *orchestratorConfig) retrieveSteps(
+	ctx context.Context,
+	tf *tfexec.Terraform,
+	workingDir string,
+) (string, *tfjson.Plan, *WorkspaceResult) {
+	failure := func(err error) (string, *tfjson.Plan, *WorkspaceResult) {
+		log.Ctx(ctx).Error().
+			Err(err).
+			Str("working_dir", workingDir).
+			Msg("refusing to apply saved terraform plan")
+		return "", nil, &WorkspaceResult{
+			Success:    false,
+			Step:       StepRetrievePlan,
+			WorkingDir: workingDir,
+			Error:      fmt.Errorf("failed to retrieve saved plan: %w", err),
+		}
+	}
+	saved, err := o.planStore.Download(ctx, workingDir)
+	if err != nil {
+		return failure(err)
+	}
+	tfVersion, err := terraformVersion(ctx, tf)
+	if err != nil {
+		return failure(err)
+	}
+	if tfVersion != saved.Manifest.TerraformVersion {
+		return failure(fmt.Errorf(
+			"plan was created with terraform %s but terraform %s is installed",
+			saved.Manifest.TerraformVersion, tfVersion,
+		))
+	}
+	if !saved.Manifest.HasChanges {
+		return "", nil, &WorkspaceResult{
+			Success:    true,
+			Step:       StepRetrievePlan,
+			WorkingDir: workingDir,
+		}
+	}
+	plan, err := tf.ShowPlanFile(ctx, saved.PlanFile)
+	if err != nil {
+		return failure(fmt.Errorf("failed to show saved plan file: %w", err))
+	}
+	return saved.PlanFile, plan, nil
+}
+
+// terraformVersion returns the version of the Terraform binary used by tf.
+func terraformVersion(ctx context.Context, tf *tfexec.Terraform) (string, error) {
+	v, _, err := tf.Version(ctx, false)
+	if err != nil {
+		return "", fmt.Errorf("failed to determine terraform version: %w", err)
+	}
+	return v.String(), nil
+}
+
 func (o *orchestratorConfig) validateSteps(
 	ctx context.Context,
 	plan *tfjson.Plan,
 	workingDir string,
+	data *out.Data,
 ) *WorkspaceResult {
 	var validationFalures []v.ValidationResult
 	for _, validator := range o.validators {
 		result, err := validator.Validate(ctx, plan)
 		if err != nil {
-			log.Error().
+			log.Ctx(ctx).Error().
 				Err(err).
 				Str("working_dir", workingDir).
 				Str("validator", fmt.Sprintf("%T", validator)).
 				Msg("validation failed")
 			return &WorkspaceResult{
 				Success:    false,
-				Stage:      "validation",
+				Step:       StepValidation,
 				WorkingDir: workingDir,
-				Error:      fmt.Sprintf("validation failed: %v", err),
+				Error:      fmt.Errorf("validation failed: %w", err),
 			}
 		}
+		data.Validations = append(data.Validations, result)
 		if !result.Passed {
 			validationFalures = append(validationFalures, result)
 		}
@@ -293,9 +1145,9 @@ func (o *orchestratorConfig) validateSteps(
 	if len(validationFalures) > 0 {
 		return &WorkspaceResult{
 			Success:    false,
-			Stage:      "validation",
+			Step:       StepValidation,
 			WorkingDir: workingDir,
-			Error:      fmt.Sprintf("validation failed with %d issues", len(validationFalures)),
+			Error:      fmt.Errorf("validation failed with %d issues", len(validationFalures)),
 		}
 	}
 	return nil
@@ -0,0 +1,174 @@
+package orchestrator
+
+import (
+	"bytes"
+	"errors"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/hashicorp/terraform-exec/tfexec"
+	tfjson "github.com/hashicorp/terraform-json"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/cultureamp/terraform-buildkite-plugin/internal/adapters/plansummary"
+	"github.com/cultureamp/terraform-buildkite-plugin/internal/adapters/terraform"
+	c "github.com/cultureamp/terraform-buildkite-plugin/internal/config"
+)
+
+func TestDriftedResources(t *testing.T) {
+	plan := &tfjson.Plan{
+		ResourceDrift: []*tfjson.ResourceChange{
+			{Address: "aws_s3_bucket.logs", Change: &tfjson.Change{Actions: tfjson.Actions{tfjson.ActionUpdate}}},
+			{Address: "aws_iam_role.app", Change: &tfjson.Change{Actions: tfjson.Actions{tfjson.ActionNoop}}},
+			{Address: "aws_sqs_queue.jobs", Change: &tfjson.Change{Actions: tfjson.Actions{tfjson.ActionDelete}}},
+			nil,
+		},
+	}
+
+	assert.Equal(t, []string{"aws_s3_bucket.logs", "aws_sqs_queue.jobs"}, driftedResources(plan))
+	assert.Empty(t, driftedResources(&tfjson.Plan{}))
+}
+
+func TestPlanChanges(t *testing.T) {
+	plan := &tfjson.Plan{
+		ResourceChanges: []*tfjson.ResourceChange{
+			{Address: "aws_s3_bucket.logs", Change: &tfjson.Change{Actions: tfjson.Actions{tfjson.ActionCreate}}},
+			{Address: "aws_iam_role.app", Change: &tfjson.Change{Actions: tfjson.Actions{tfjson.ActionUpdate}}},
+			{Address: "aws_sqs_queue.jobs", Change: &tfjson.Change{Actions: tfjson.Actions{tfjson.ActionDelete}}},
+			{
+				Address: "aws_instance.web",
+				Change:  &tfjson.Change{Actions: tfjson.Actions{tfjson.ActionDelete, tfjson.ActionCreate}},
+			},
+			{Address: "aws_vpc.main", Change: &tfjson.Change{Actions: tfjson.Actions{tfjson.ActionNoop}}},
+			nil,
+		},
+	}
+
+	changes := planChanges(plansummary.New(plan))
+	assert.Equal(t, &PlanChanges{Add: 2, Change: 1, Destroy: 2}, changes)
+	assert.Equal(t, "2 to add, 1 to change, 2 to destroy", changes.String())
+}
+
+func TestPlanOptions(t *testing.T) {
+	workingDir := t.TempDir()
+	require.NoError(t, os.WriteFile(filepath.Join(workingDir, "prod.tfvars"), nil, 0o600))
+	refresh, lock, parallelism := false, false, 4
+	options := &terraform.PlanOptions{
+		VarFiles:    []string{"prod.tfvars"},
+		Vars:        map[string]string{"region": "us-west-2", "env": "prod"},
+		Targets:     []string{"module.app"},
+		Replace:     []string{"aws_instance.web"},
+		Refresh:     &refresh,
+		Lock:        &lock,
+		LockTimeout: "30s",
+		Parallelism: &parallelism,
+	}
+
+	t.Run("plan options", func(t *testing.T) {
+		o := &orchestratorConfig{plugin: &c.Plugin{Mode: c.Plan, Terraform: &terraform.Options{PlanOptions: options}}}
+		opts, err := o.planOptions(workingDir)
+		require.NoError(t, err)
+		assert.Equal(t, []tfexec.PlanOption{
+			tfexec.VarFile(filepath.Join(workingDir, "prod.tfvars")),
+			tfexec.Var("env=prod"),
+			tfexec.Var("region=us-west-2"),
+			tfexec.Target("module.app"),
+			tfexec.Replace("aws_instance.web"),
+			tfexec.Refresh(false),
+			tfexec.Lock(false),
+			tfexec.LockTimeout("30s"),
+			tfexec.Parallelism(4),
+		}, opts)
+		assert.Equal(t, []tfexec.ApplyOption{
+			tfexec.Lock(false),
+			tfexec.LockTimeout("30s"),
+			tfexec.Parallelism(4),
+		}, o.applyOptions())
+	})
+
+	t.Run("refresh and replace are ignored in drift mode", func(t *testing.T) {
+		o := &orchestratorConfig{plugin: &c.Plugin{Mode: c.Drift, Terraform: &terraform.Options{PlanOptions: options}}}
+		opts, err := o.planOptions(workingDir)
+		require.NoError(t, err)
+		assert.NotContains(t, opts, tfexec.Refresh(false))
+		assert.NotContains(t, opts, tfexec.Replace("aws_instance.web"))
+		assert.Contains(t, opts, tfexec.Target("module.app"))
+	})
+
+	t.Run("missing var file", func(t *testing.T) {
+		o := &orchestratorConfig{plugin: &c.Plugin{Mode: c.Plan, Terraform: &terraform.Options{
+			PlanOptions: &terraform.PlanOptions{VarFiles: []string{"missing.tfvars"}},
+		}}}
+		_, err := o.planOptions(workingDir)
+		require.Error(t, err)
+	})
+
+	t.Run("no options", func(t *testing.T) {
+		o := &orchestratorConfig{plugin: &c.Plugin{Mode: c.Plan}}
+		opts, err := o.planOptions(workingDir)
+		require.NoError(t, err)
+		assert.Empty(t, opts)
+		assert.Empty(t, o.applyOptions())
+	})
+}
+
+func TestInitOptions(t *testing.T) {
+	get, backend, reconfigure, upgrade, lock := true, false, true, true, false
+	pluginDir := "/opt/terraform/plugins"
+	o := &orchestratorConfig{plugin: &c.Plugin{Mode: c.Plan, Terraform: &terraform.Options{
+		InitOptions: &terraform.InitOptions{
+			PluginDir:     &pluginDir,
+			Get:           &get,
+			BackendConfig: map[string]string{"key": "{{.Workspace}}.tfstate"},
+			Backend:       &backend,
+			Reconfigure:   &reconfigure,
+			Upgrade:       &upgrade,
+			Lock:          &lock,
+			LockTimeout:   "1m",
+		},
+	}}}
+
+	opts, err := o.initOptions("stacks/app")
+	require.NoError(t, err)
+	assert.Equal(t, []tfexec.InitOption{
+		tfexec.Get(true),
+		tfexec.PluginDir(pluginDir),
+		tfexec.BackendConfig("key=app.tfstate"),
+		tfexec.Backend(false),
+		tfexec.Reconfigure(true),
+		tfexec.Upgrade(true),
+		tfexec.Lock(false),
+		tfexec.LockTimeout("1m"),
+	}, opts)
+
+	none := &orchestratorConfig{plugin: &c.Plugin{Mode: c.Plan}}
+	opts, err = none.initOptions("stacks/app")
+	require.NoError(t, err)
+	assert.Empty(t, opts)
+}
+
+func TestEventWriter(t *testing.T) {
+	var buf bytes.Buffer
+	events := newEventWriter(&buf)
+
+	stream := `{"type":"version","terraform":"1.9.0"}` + "\n" +
+		`{"type":"apply_errored","hook":{"resource":{"addr":"aws_s3_bucket.logs"},"action":"delete",` +
+		`"elapsed_seconds":1.5}}` + "\n" +
+		"not an event\n" +
+		`{"type":"diagnostic","diagnostic":{"severity":"error","summary":"Bucket not empty"}}`
+	// write the stream in pieces to split lines across writes
+	for _, piece := range []string{stream[:20], stream[20:90], stream[90:]} {
+		_, err := events.Write([]byte(piece))
+		require.NoError(t, err)
+	}
+	require.NoError(t, events.Flush())
+
+	assert.Equal(t, "aws_s3_bucket.logs: delete errored after 1.5s\n"+
+		"not an event\n"+
+		"Error: Bucket not empty\n", buf.String())
+	assert.Equal(t, []Diagnostic{{Severity: "error", Summary: "Bucket not empty"}}, events.Diagnostics())
+	require.EqualError(t, events.wrap(errors.New("exit status 1")), "exit status 1\nError: Bucket not empty")
+	assert.NoError(t, events.wrap(nil))
+}
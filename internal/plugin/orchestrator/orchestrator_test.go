@@ -0,0 +1,659 @@
+package orchestrator_test
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/cultureamp/terraform-buildkite-plugin/internal/adapters/outputs"
+	"github.com/cultureamp/terraform-buildkite-plugin/internal/adapters/terraform"
+	"github.com/cultureamp/terraform-buildkite-plugin/internal/adapters/validators"
+	"github.com/cultureamp/terraform-buildkite-plugin/internal/config"
+	"github.com/cultureamp/terraform-buildkite-plugin/internal/plugin/orchestrator"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestOrchestrator_Run_EmitsStageToOutputers(t *testing.T) {
+	t.Run("unsupported mode emits unexpected failure", func(t *testing.T) {
+		first := &recordingOutputer{}
+		second := &recordingOutputer{}
+		orch, err := orchestrator.NewOrchestrator(
+			t.Context(),
+			&config.Plugin{Mode: "unknown"},
+			nil,
+			[]outputs.Outputer{first, second},
+			orchestrator.WithTerraformExecPath("/usr/bin/true"),
+		)
+		require.NoError(t, err)
+
+		result := orch.Run(t.Context(), "/tmp/stacks/foo")
+
+		require.NotNil(t, result)
+		assert.False(t, result.Success)
+		require.NoError(t, result.OutputError)
+		for _, o := range []*recordingOutputer{first, second} {
+			assert.Equal(t, []outputs.Stage{outputs.UnexpectedFailure}, o.stages)
+			require.Len(t, o.data, 1)
+			assert.Equal(t, "foo", o.data[0].Workspace)
+			assert.Equal(t, "/tmp/stacks/foo", o.data[0].WorkingDir)
+			assert.Contains(t, o.data[0].Error, "unsupported plugin mode")
+		}
+	})
+
+	t.Run("outputer errors do not mask the result", func(t *testing.T) {
+		failing := &recordingOutputer{err: errors.New("boom")}
+		working := &recordingOutputer{}
+		orch, err := orchestrator.NewOrchestrator(
+			t.Context(),
+			&config.Plugin{Mode: "unknown"},
+			nil,
+			[]outputs.Outputer{failing, working},
+			orchestrator.WithTerraformExecPath("/usr/bin/true"),
+		)
+		require.NoError(t, err)
+
+		result := orch.Run(t.Context(), "/tmp/stacks/foo")
+
+		require.NotNil(t, result)
+		assert.False(t, result.Success)
+		assert.Equal(t, outputs.UnexpectedFailure, result.Stage)
+		assert.Equal(t, orchestrator.StepConfiguration, result.Step)
+		require.Error(t, result.OutputError)
+		assert.Contains(t, result.OutputError.Error(), "boom")
+		assert.Equal(t, []outputs.Stage{outputs.UnexpectedFailure}, working.stages)
+	})
+}
+
+func TestOrchestrator_Plan_Stages(t *testing.T) {
+	cases := []struct {
+		name       string
+		planExit   int
+		validators []*recordingValidator
+		success    bool
+		step       orchestrator.Step
+		changes    *orchestrator.PlanChanges
+		want       []outputs.Stage
+	}{
+		{
+			name:     "changes",
+			planExit: 2,
+			success:  true,
+			step:     orchestrator.StepPlan,
+			changes:  &orchestrator.PlanChanges{Add: 1},
+			want:     []outputs.Stage{outputs.PlanSuccessWithChanges},
+		},
+		{
+			name:       "changes that pass validation",
+			planExit:   2,
+			validators: []*recordingValidator{{passed: true}},
+			success:    true,
+			step:       orchestrator.StepPlan,
+			changes:    &orchestrator.PlanChanges{Add: 1},
+			want:       []outputs.Stage{outputs.PlanSuccessWithChanges, outputs.ValidationSuccess},
+		},
+		{
+			name:       "changes that fail validation",
+			planExit:   2,
+			validators: []*recordingValidator{{passed: false}},
+			step:       orchestrator.StepValidation,
+			changes:    &orchestrator.PlanChanges{Add: 1},
+			want:       []outputs.Stage{outputs.PlanSuccessWithChanges, outputs.ValidationFailure},
+		},
+		{
+			name:     "no changes",
+			planExit: 0,
+			success:  true,
+			step:     orchestrator.StepPlan,
+			want:     []outputs.Stage{outputs.PlanSuccessNoChanges},
+		},
+		{
+			name:     "plan failure",
+			planExit: 1,
+			step:     orchestrator.StepPlan,
+			want:     []outputs.Stage{outputs.PlanFailure},
+		},
+	}
+	for _, tc := range cases {
+		t.Run(tc.name, func(t *testing.T) {
+			tf := newFakeTerraform(t, fakeTerraformOptions{PlanExit: tc.planExit})
+			recorder := &recordingOutputer{}
+			var vs []validators.Validator
+			for _, v := range tc.validators {
+				vs = append(vs, v)
+			}
+			orch, err := orchestrator.NewOrchestrator(
+				t.Context(),
+				&config.Plugin{Mode: config.Plan},
+				vs,
+				[]outputs.Outputer{recorder},
+				orchestrator.WithTerraformExecPath(tf.ExecPath),
+			)
+			require.NoError(t, err)
+
+			result := orch.Run(t.Context(), t.TempDir())
+
+			assert.Equal(t, tc.success, result.Success)
+			assert.Equal(t, tc.want, recorder.stages)
+			assert.Equal(t, tc.want[len(tc.want)-1], result.Stage)
+			assert.Equal(t, tc.step, result.Step)
+			assert.Equal(t, tc.changes, result.Changes)
+			assert.Equal(t, tc.changes != nil, result.HasChanges)
+			assert.Len(t, result.Validations, len(tc.validators))
+			assert.Contains(t, result.Durations, orchestrator.StepInit)
+			assert.Contains(t, result.Durations, orchestrator.StepPlan)
+			if tc.success {
+				assert.NoError(t, result.Error)
+			} else {
+				assert.Error(t, result.Error)
+			}
+			assert.Len(t, tf.Calls(t, "init"), 1)
+			assert.Len(t, tf.Calls(t, "plan"), 1)
+			assert.Empty(t, tf.Calls(t, "apply"))
+		})
+	}
+}
+
+func TestOrchestrator_Apply_Stages(t *testing.T) {
+	cases := []struct {
+		name      string
+		planExit  int
+		applyExit int
+		success   bool
+		applied   bool
+		want      []outputs.Stage
+	}{
+		{
+			name:     "applies changes",
+			planExit: 2,
+			success:  true,
+			applied:  true,
+			want:     []outputs.Stage{outputs.PlanSuccessWithChanges, outputs.ApplySuccess},
+		},
+		{
+			name:      "apply failure",
+			planExit:  2,
+			applyExit: 1,
+			applied:   true,
+			want:      []outputs.Stage{outputs.PlanSuccessWithChanges, outputs.ApplyFailure},
+		},
+		{
+			name:     "nothing to apply",
+			planExit: 0,
+			success:  true,
+			want:     []outputs.Stage{outputs.PlanSuccessNoChanges},
+		},
+		{
+			name:     "plan failure",
+			planExit: 1,
+			want:     []outputs.Stage{outputs.PlanFailure},
+		},
+	}
+	for _, tc := range cases {
+		t.Run(tc.name, func(t *testing.T) {
+			tf := newFakeTerraform(t, fakeTerraformOptions{PlanExit: tc.planExit, ApplyExit: tc.applyExit})
+			recorder := &recordingOutputer{}
+			orch, err := orchestrator.NewOrchestrator(
+				t.Context(),
+				&config.Plugin{Mode: config.Apply},
+				nil,
+				[]outputs.Outputer{recorder},
+				orchestrator.WithTerraformExecPath(tf.ExecPath),
+			)
+			require.NoError(t, err)
+
+			workingDir := t.TempDir()
+			result := orch.Run(t.Context(), workingDir)
+
+			assert.Equal(t, tc.success, result.Success)
+			assert.Equal(t, tc.want, recorder.stages)
+			if tc.applied {
+				require.Len(t, tf.Calls(t, "apply"), 1)
+				assert.Contains(t, tf.Calls(t, "apply")[0], filepath.Join(workingDir, "plan.binary"))
+			} else {
+				assert.Empty(t, tf.Calls(t, "apply"))
+			}
+		})
+	}
+}
+
+func TestOrchestrator_Destroy_Stages(t *testing.T) {
+	cases := []struct {
+		name      string
+		planExit  int
+		applyExit int
+		passed    bool
+		success   bool
+		applied   bool
+		want      []outputs.Stage
+	}{
+		{
+			name:     "destroys resources",
+			planExit: 2,
+			passed:   true,
+			success:  true,
+			applied:  true,
+			want: []outputs.Stage{
+				outputs.DestroyPlanSuccessWithChanges, outputs.ValidationSuccess, outputs.DestroySuccess,
+			},
+		},
+		{
+			name:     "validation failure",
+			planExit: 2,
+			want:     []outputs.Stage{outputs.DestroyPlanSuccessWithChanges, outputs.ValidationFailure},
+		},
+		{
+			name:      "destroy failure",
+			planExit:  2,
+			applyExit: 1,
+			passed:    true,
+			applied:   true,
+			want: []outputs.Stage{
+				outputs.DestroyPlanSuccessWithChanges, outputs.ValidationSuccess, outputs.DestroyFailure,
+			},
+		},
+		{
+			name:     "nothing to destroy",
+			planExit: 0,
+			passed:   true,
+			success:  true,
+			want:     []outputs.Stage{outputs.DestroyPlanSuccessNoChanges},
+		},
+		{
+			name:     "destroy plan failure",
+			planExit: 1,
+			passed:   true,
+			want:     []outputs.Stage{outputs.DestroyPlanFailure},
+		},
+	}
+	for _, tc := range cases {
+		t.Run(tc.name, func(t *testing.T) {
+			tf := newFakeTerraform(t, fakeTerraformOptions{PlanExit: tc.planExit, ApplyExit: tc.applyExit})
+			recorder := &recordingOutputer{}
+			validator := &recordingValidator{passed: tc.passed}
+			orch, err := orchestrator.NewOrchestrator(
+				t.Context(),
+				&config.Plugin{Mode: config.Destroy},
+				[]validators.Validator{validator},
+				[]outputs.Outputer{recorder},
+				orchestrator.WithTerraformExecPath(tf.ExecPath),
+			)
+			require.NoError(t, err)
+
+			result := orch.Run(t.Context(), t.TempDir())
+
+			assert.Equal(t, tc.success, result.Success)
+			assert.Equal(t, tc.want, recorder.stages)
+			require.Len(t, tf.Calls(t, "plan"), 1)
+			assert.Contains(t, tf.Calls(t, "plan")[0], "-destroy")
+			if tc.planExit == 2 {
+				assert.Equal(t, 1, validator.calls)
+			}
+			assert.Len(t, tf.Calls(t, "apply"), map[bool]int{true: 1}[tc.applied])
+		})
+	}
+}
+
+func TestOrchestrator_Drift_Stages(t *testing.T) {
+	const driftPlan = `{
+  "format_version": "1.2",
+  "resource_drift": [
+    {"address": "aws_s3_bucket.logs", "type": "aws_s3_bucket", "name": "logs", "change": {"actions": ["update"]}}
+  ]
+}`
+	const outputsOnlyPlan = `{
+  "format_version": "1.2",
+  "output_changes": {"bucket": {"actions": ["update"]}}
+}`
+	cases := []struct {
+		name     string
+		planExit int
+		plan     string
+		drifted  []string
+		want     []outputs.Stage
+	}{
+		{
+			name:     "drifted resources",
+			planExit: 2,
+			plan:     driftPlan,
+			drifted:  []string{"aws_s3_bucket.logs"},
+			want:     []outputs.Stage{outputs.DriftDetected},
+		},
+		{
+			name:     "only outputs changed",
+			planExit: 2,
+			plan:     outputsOnlyPlan,
+			want:     []outputs.Stage{outputs.NoDriftDetected},
+		},
+		{
+			name:     "no changes",
+			planExit: 0,
+			want:     []outputs.Stage{outputs.NoDriftDetected},
+		},
+	}
+	for _, tc := range cases {
+		t.Run(tc.name, func(t *testing.T) {
+			tf := newFakeTerraform(t, fakeTerraformOptions{PlanExit: tc.planExit, Plan: tc.plan})
+			recorder := &recordingOutputer{}
+			orch, err := orchestrator.NewOrchestrator(
+				t.Context(),
+				&config.Plugin{Mode: config.Drift},
+				nil,
+				[]outputs.Outputer{recorder},
+				orchestrator.WithTerraformExecPath(tf.ExecPath),
+			)
+			require.NoError(t, err)
+
+			result := orch.Run(t.Context(), t.TempDir())
+
+			assert.True(t, result.Success)
+			assert.Equal(t, len(tc.drifted) > 0, result.Drifted)
+			assert.Equal(t, tc.drifted, result.DriftedResources)
+			assert.Equal(t, tc.want, recorder.stages)
+			require.Len(t, tf.Calls(t, "plan"), 1)
+			assert.Contains(t, tf.Calls(t, "plan")[0], "-refresh-only")
+			assert.Empty(t, tf.Calls(t, "apply"))
+		})
+	}
+}
+
+func TestOrchestrator_Drift_UnsupportedVersion(t *testing.T) {
+	tf := newFakeTerraform(t, fakeTerraformOptions{PlanExit: 2, Version: "0.15.3"})
+	recorder := &recordingOutputer{}
+	orch, err := orchestrator.NewOrchestrator(
+		t.Context(),
+		&config.Plugin{Mode: config.Drift},
+		nil,
+		[]outputs.Outputer{recorder},
+		orchestrator.WithTerraformExecPath(tf.ExecPath),
+	)
+	require.NoError(t, err)
+
+	result := orch.Run(t.Context(), t.TempDir())
+
+	assert.False(t, result.Success)
+	require.ErrorContains(t, result.Error, "does not support reporting resource drift")
+	assert.Equal(t, []outputs.Stage{outputs.PlanFailure}, recorder.stages)
+	assert.Empty(t, tf.Calls(t, "plan"))
+}
+
+func TestOrchestrator_Timeouts(t *testing.T) {
+	t.Run("plan timeout interrupts terraform", func(t *testing.T) {
+		tf := newFakeTerraform(t, fakeTerraformOptions{PlanSleep: 30})
+		recorder := &recordingOutputer{}
+		orch, err := orchestrator.NewOrchestrator(
+			t.Context(),
+			&config.Plugin{Mode: config.Plan, Timeouts: &config.Timeouts{Plan: "100ms"}},
+			nil,
+			[]outputs.Outputer{recorder},
+			orchestrator.WithTerraformExecPath(tf.ExecPath),
+		)
+		require.NoError(t, err)
+
+		start := time.Now()
+		result := orch.Run(t.Context(), t.TempDir())
+
+		assert.Less(t, time.Since(start), 10*time.Second)
+		assert.False(t, result.Success)
+		assert.True(t, result.TimedOut)
+		assert.False(t, result.Cancelled)
+		require.ErrorContains(t, result.Error, "plan timed out after 100ms")
+		assert.ErrorIs(t, result.Error, context.DeadlineExceeded)
+		assert.Equal(t, []outputs.Stage{outputs.PlanFailure}, recorder.stages)
+	})
+
+	t.Run("cancelled context interrupts terraform", func(t *testing.T) {
+		tf := newFakeTerraform(t, fakeTerraformOptions{PlanSleep: 30})
+		recorder := &recordingOutputer{}
+		orch, err := orchestrator.NewOrchestrator(
+			t.Context(),
+			&config.Plugin{Mode: config.Apply, Timeouts: &config.Timeouts{Plan: "1h"}},
+			nil,
+			[]outputs.Outputer{recorder},
+			orchestrator.WithTerraformExecPath(tf.ExecPath),
+		)
+		require.NoError(t, err)
+
+		ctx, cancel := context.WithTimeout(t.Context(), 100*time.Millisecond)
+		defer cancel()
+		result := orch.Run(ctx, t.TempDir())
+
+		assert.False(t, result.Success)
+		assert.True(t, result.Cancelled)
+		assert.False(t, result.TimedOut)
+		require.ErrorContains(t, result.Error, "plan was cancelled")
+		assert.Empty(t, tf.Calls(t, "apply"))
+	})
+}
+
+func TestOrchestrator_Retry(t *testing.T) {
+	const lockError = "Error acquiring the state lock: ConditionalCheckFailedException"
+	cases := []struct {
+		name      string
+		retry     *config.Retry
+		failures  int
+		planError string
+		success   bool
+		attempts  []string
+	}{
+		{
+			name:      "transient failure is retried",
+			retry:     &config.Retry{Backoff: "1ms"},
+			failures:  2,
+			planError: lockError,
+			success:   true,
+			attempts:  []string{"init 1 ok", "plan 1 failed", "plan 2 failed", "plan 3 ok"},
+		},
+		{
+			name:      "attempts are limited",
+			retry:     &config.Retry{Attempts: 2, Backoff: "1ms"},
+			failures:  2,
+			planError: lockError,
+			attempts:  []string{"init 1 ok", "plan 1 failed", "plan 2 failed"},
+		},
+		{
+			name:      "other failures are not retried",
+			retry:     &config.Retry{Backoff: "1ms"},
+			failures:  1,
+			planError: "Error: Unsupported argument",
+			attempts:  []string{"init 1 ok", "plan 1 failed"},
+		},
+		{
+			name:      "configured patterns are retried",
+			retry:     &config.Retry{Backoff: "1ms", Patterns: []string{`Throttling`}},
+			failures:  1,
+			planError: "Error: Throttling: Rate exceeded",
+			success:   true,
+			attempts:  []string{"init 1 ok", "plan 1 failed", "plan 2 ok"},
+		},
+		{
+			name:      "a single attempt disables retries",
+			retry:     &config.Retry{Attempts: 1},
+			failures:  1,
+			planError: lockError,
+			attempts:  []string{"init 1 ok", "plan 1 failed"},
+		},
+	}
+	for _, tc := range cases {
+		t.Run(tc.name, func(t *testing.T) {
+			tf := newFakeTerraform(t, fakeTerraformOptions{PlanFailures: tc.failures, PlanError: tc.planError})
+			orch, err := orchestrator.NewOrchestrator(
+				t.Context(),
+				&config.Plugin{Mode: config.Plan, Retry: tc.retry},
+				nil,
+				nil,
+				orchestrator.WithTerraformExecPath(tf.ExecPath),
+			)
+			require.NoError(t, err)
+
+			result := orch.Run(t.Context(), t.TempDir())
+
+			assert.Equal(t, tc.success, result.Success)
+			var attempts []string
+			for _, attempt := range result.Attempts {
+				outcome := "ok"
+				if attempt.Error != "" {
+					outcome = "failed"
+					assert.Contains(t, attempt.Error, tc.planError)
+				}
+				attempts = append(attempts, fmt.Sprintf("%s %d %s", attempt.Command, attempt.Number, outcome))
+			}
+			assert.Equal(t, tc.attempts, attempts)
+		})
+	}
+}
+
+func TestOrchestrator_Output(t *testing.T) {
+	t.Run("stages stream terraform output in collapsed groups", func(t *testing.T) {
+		tf := newFakeTerraform(t, fakeTerraformOptions{PlanExit: 2})
+		outputer := &recordingOutputer{}
+		orch, err := orchestrator.NewOrchestrator(
+			t.Context(),
+			&config.Plugin{Mode: config.Apply},
+			nil,
+			[]outputs.Outputer{outputer},
+			orchestrator.WithTerraformExecPath(tf.ExecPath),
+		)
+		require.NoError(t, err)
+
+		var buf bytes.Buffer
+		workingDir := t.TempDir()
+		result := orch.Run(orchestrator.WithOutput(t.Context(), &buf), workingDir)
+
+		require.True(t, result.Success)
+		assert.Equal(t, "--- :terraform: init "+workingDir+"\n"+
+			"Terraform has been successfully initialized!\n"+
+			"--- :terraform: plan "+workingDir+"\n"+
+			"Terraform will perform the following actions:\n"+
+			"Plan summary: 1 to create\n"+
+			"  create   aws_s3_bucket.logs\n"+
+			"  module root: 1 to create\n"+
+			"  type aws_s3_bucket: 1 to create\n"+
+			"--- :terraform: apply "+workingDir+"\n", buf.String())
+		require.NotEmpty(t, outputer.data)
+		summary := outputer.data[0].Summary
+		require.NotNil(t, summary)
+		assert.Equal(t, []string{"aws_s3_bucket.logs"}, summary.Addresses.Create)
+	})
+
+	t.Run("failing stages are expanded", func(t *testing.T) {
+		tf := newFakeTerraform(t, fakeTerraformOptions{PlanFailures: 1, PlanError: "Error: Unsupported argument"})
+		orch, err := orchestrator.NewOrchestrator(
+			t.Context(),
+			&config.Plugin{Mode: config.Plan},
+			nil,
+			nil,
+			orchestrator.WithTerraformExecPath(tf.ExecPath),
+		)
+		require.NoError(t, err)
+
+		var buf bytes.Buffer
+		workingDir := t.TempDir()
+		result := orch.Run(orchestrator.WithOutput(t.Context(), &buf), workingDir)
+
+		require.False(t, result.Success)
+		assert.True(t, strings.HasSuffix(buf.String(), "--- :terraform: plan "+workingDir+"\n"+
+			"Error: Unsupported argument\n"+
+			"^^^ +++\n"), buf.String())
+	})
+}
+
+func TestOrchestrator_JSONOutput(t *testing.T) {
+	const (
+		plannedChange = `{"@level":"info","@message":"aws_s3_bucket.logs: Plan to create","type":"planned_change",` +
+			`"change":{"resource":{"addr":"aws_s3_bucket.logs"},"action":"create"}}`
+		changeSummary = `{"@level":"info","@message":"Plan: 1 to add, 0 to change, 0 to destroy.",` +
+			`"type":"change_summary","changes":{"add":1,"change":0,"remove":0,"operation":"plan"}}`
+		deprecation = `{"@level":"warn","@message":"Warning: Argument is deprecated","type":"diagnostic",` +
+			`"diagnostic":{"severity":"warning","summary":"Argument is deprecated","detail":"Use acl instead.",` +
+			`"address":"aws_s3_bucket.logs","range":{"filename":"main.tf","start":{"line":3},"end":{"line":5}}}}`
+		applyStart = `{"@level":"info","@message":"aws_s3_bucket.logs: Creating...","type":"apply_start",` +
+			`"hook":{"resource":{"addr":"aws_s3_bucket.logs"},"action":"create"}}`
+		applyComplete = `{"@level":"info","type":"apply_complete",` +
+			`"hook":{"resource":{"addr":"aws_s3_bucket.logs"},"action":"create","elapsed_seconds":2}}`
+		lockError = `{"@level":"error","@message":"Error: Error acquiring the state lock","type":"diagnostic",` +
+			`"diagnostic":{"severity":"error","summary":"Error acquiring the state lock","detail":"Lock held."}}`
+	)
+	jsonOutput := &terraform.Options{JSONOutput: true}
+
+	t.Run("plan and apply render progress and collect diagnostics", func(t *testing.T) {
+		tf := newFakeTerraform(t, fakeTerraformOptions{
+			PlanExit:    2,
+			PlanEvents:  plannedChange + "\n" + deprecation + "\n" + changeSummary,
+			ApplyEvents: applyStart + "\n" + applyComplete,
+		})
+		orch, err := orchestrator.NewOrchestrator(
+			t.Context(),
+			&config.Plugin{Mode: config.Apply, Terraform: jsonOutput},
+			nil,
+			nil,
+			orchestrator.WithTerraformExecPath(tf.ExecPath),
+		)
+		require.NoError(t, err)
+
+		var buf bytes.Buffer
+		result := orch.Run(orchestrator.WithOutput(t.Context(), &buf), t.TempDir())
+
+		require.True(t, result.Success, result.Error)
+		assert.Contains(t, buf.String(), "aws_s3_bucket.logs: Plan to create\n"+
+			"Warning: Argument is deprecated (main.tf:3-5)\n"+
+			"Plan: 1 to add, 0 to change, 0 to destroy.\n")
+		assert.Contains(t, buf.String(), "aws_s3_bucket.logs: Creating...\n"+
+			"aws_s3_bucket.logs: create complete after 2s\n")
+		assert.Equal(t, []orchestrator.Diagnostic{{
+			Severity:  "warning",
+			Summary:   "Argument is deprecated",
+			Detail:    "Use acl instead.",
+			Address:   "aws_s3_bucket.logs",
+			Filename:  "main.tf",
+			StartLine: 3,
+			EndLine:   5,
+		}}, result.Diagnostics)
+		require.Len(t, tf.Calls(t, "plan"), 1)
+		assert.Contains(t, tf.Calls(t, "plan")[0], "-json")
+		assert.Contains(t, tf.Calls(t, "apply")[0], "-json")
+	})
+
+	t.Run("error diagnostics are part of the error and retried", func(t *testing.T) {
+		tf := newFakeTerraform(t, fakeTerraformOptions{PlanExit: 1, PlanEvents: lockError})
+		orch, err := orchestrator.NewOrchestrator(
+			t.Context(),
+			&config.Plugin{Mode: config.Plan, Terraform: jsonOutput, Retry: &config.Retry{Attempts: 2, Backoff: "1ms"}},
+			nil,
+			nil,
+			orchestrator.WithTerraformExecPath(tf.ExecPath),
+		)
+		require.NoError(t, err)
+
+		result := orch.Run(orchestrator.WithOutput(t.Context(), io.Discard), t.TempDir())
+
+		assert.False(t, result.Success)
+		require.ErrorContains(t, result.Error, "Error: Error acquiring the state lock")
+		assert.Len(t, tf.Calls(t, "plan"), 2)
+		assert.Len(t, result.Diagnostics, 2)
+	})
+
+	t.Run("older binaries stream plain output", func(t *testing.T) {
+		tf := newFakeTerraform(t, fakeTerraformOptions{PlanExit: 0, Version: "0.15.2"})
+		orch, err := orchestrator.NewOrchestrator(
+			t.Context(),
+			&config.Plugin{Mode: config.Plan, Terraform: jsonOutput},
+			nil,
+			nil,
+			orchestrator.WithTerraformExecPath(tf.ExecPath),
+		)
+		require.NoError(t, err)
+
+		result := orch.Run(orchestrator.WithOutput(t.Context(), io.Discard), t.TempDir())
+
+		require.True(t, result.Success, result.Error)
+		require.Len(t, tf.Calls(t, "plan"), 1)
+		assert.NotContains(t, tf.Calls(t, "plan")[0], "-json")
+	})
+}
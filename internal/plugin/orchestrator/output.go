@@ -0,0 +1,82 @@
+package orchestrator
+
+import (
+	"context"
+	"io"
+	"os"
+	"sync"
+
+	"github.com/cultureamp/terraform-buildkite-plugin/pkg/buildkite/group"
+	"github.com/hashicorp/terraform-exec/tfexec"
+	"github.com/rs/zerolog/log"
+)
+
+// outputKey is the context key of the writer a workspace's terraform output is streamed to.
+type outputKey struct{}
+
+// WithOutput returns a context whose workspace streams terraform output and
+// its log groups to w instead of stdout. Workspaces running concurrently use
+// it to buffer their output alongside their logs.
+func WithOutput(ctx context.Context, w io.Writer) context.Context {
+	return context.WithValue(ctx, outputKey{}, w)
+}
+
+// output returns the writer a workspace's terraform output is streamed to.
+func output(ctx context.Context) io.Writer {
+	if w, ok := ctx.Value(outputKey{}).(io.Writer); ok {
+		return w
+	}
+	return os.Stdout
+}
+
+// openGroup opens a collapsed log group for a stage of a workspace, such as
+// "--- :terraform: plan stacks/foo".
+func openGroup(ctx context.Context, step Step, workingDir string) group.Manager {
+	groups := group.NewLogGroupManager(output(ctx))
+	groups.ClosedF(":terraform: %s %s", step, workingDir)
+	return groups
+}
+
+// stream runs a terraform command with its stdout and stderr streamed to the
+// workspace's output, which is also passed to fn for commands that render
+// their own output. Only commands whose output is meant to be read are
+// streamed, the JSON printed by show and version is parsed instead.
+func stream(ctx context.Context, tf *tfexec.Terraform, fn func(w io.Writer) error) error {
+	// commands check the binary's version first, which is cached once read,
+	// so read it before streaming to keep its JSON out of the log
+	if _, _, err := tf.Version(ctx, false); err != nil {
+		return err
+	}
+	w := &syncWriter{w: output(ctx)}
+	tf.SetStdout(w)
+	tf.SetStderr(w)
+	defer func() {
+		tf.SetStdout(nil)
+		tf.SetStderr(nil)
+	}()
+	return fn(w)
+}
+
+// recordDiagnostics flushes a streamed command's events and adds their
+// diagnostics to the workspace's recorder.
+func recordDiagnostics(ctx context.Context, events *eventWriter) {
+	if err := events.Flush(); err != nil {
+		log.Ctx(ctx).Warn().Err(err).Msg("failed to write terraform output")
+	}
+	if r, ok := ctx.Value(recorderKey{}).(*recorder); ok {
+		r.diagnostics = append(r.diagnostics, events.Diagnostics()...)
+	}
+}
+
+// syncWriter serialises the writes of terraform's stdout and stderr, which are
+// copied from separate goroutines.
+type syncWriter struct {
+	mu sync.Mutex
+	w  io.Writer
+}
+
+func (s *syncWriter) Write(p []byte) (int, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return s.w.Write(p)
+}
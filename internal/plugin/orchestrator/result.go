@@ -0,0 +1,248 @@
+package orchestrator
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"strings"
+	"time"
+
+	out "github.com/cultureamp/terraform-buildkite-plugin/internal/adapters/outputs"
+	v "github.com/cultureamp/terraform-buildkite-plugin/internal/adapters/validators"
+)
+
+// Step identifies the step of a workspace's lifecycle a result was produced in.
+type Step string
+
+const (
+	StepConfiguration Step = "configuration"
+	StepScheduling    Step = "scheduling"
+	StepSkipped       Step = "skipped"
+	StepInit          Step = "init"
+	StepVersion       Step = "version"
+	StepWorkspace     Step = "workspace"
+	StepPlan          Step = "plan"
+	StepShowPlan      Step = "show_plan"
+	StepPublishPlan   Step = "publish_plan"
+	StepRetrievePlan  Step = "retrieve_plan"
+	StepValidation    Step = "validation"
+	StepApply         Step = "apply"
+	StepDestroy       Step = "destroy"
+	StepDrift         Step = "drift"
+)
+
+// WorkspaceResult is the outcome of running a single working directory.
+//
+// Results are marshalled to JSON with snake_case keys, errors as their
+// messages and durations in milliseconds. The JSON form is consumed by
+// reports and external tooling, so keys are only ever added.
+type WorkspaceResult struct {
+	// Success reports whether the workspace completed without failing.
+	Success bool
+	// Stage is the last stage reported to outputers, empty when the workspace never ran.
+	Stage out.Stage
+	// Step is the step the workspace finished in, or failed in.
+	Step Step
+	// WorkingDir is the path of the Terraform working directory.
+	WorkingDir string
+	// Error is the reason the workspace failed, nil when it succeeded.
+	Error error
+	// TimedOut reports whether a stage exceeded its configured timeout.
+	TimedOut bool
+	// Cancelled reports whether the workspace was interrupted because the job was cancelled.
+	Cancelled bool
+	// Drifted reports whether a refresh-only plan found drift, in drift mode.
+	Drifted bool
+	// DriftedResources lists the addresses of resources that drifted, in drift mode.
+	DriftedResources []string
+	// HasChanges reports whether the plan has changes, outside drift mode.
+	HasChanges bool
+	// Changes counts the resource changes in the plan, when it has changes.
+	Changes *PlanChanges
+	// Validations contains the results of every validator that ran.
+	Validations []v.ValidationResult
+	// Durations records how long each timed stage took.
+	Durations map[Step]time.Duration
+	// Attempts records every attempt of the terraform commands that are retried.
+	Attempts []Attempt
+	// Diagnostics contains the errors and warnings terraform reported, when
+	// plan and apply stream their -json output.
+	Diagnostics []Diagnostic
+	// OutputError holds any errors returned by outputers. It never changes Success.
+	OutputError error
+}
+
+// Attempt records one run of a retried terraform command.
+type Attempt struct {
+	// Command is the terraform command, such as init or plan.
+	Command string
+	// Number is the attempt number, starting at 1.
+	Number int
+	// Duration is how long the attempt ran for.
+	Duration time.Duration
+	// Error is the error the attempt failed with, empty when it succeeded.
+	Error string
+}
+
+// Diagnostic is an error or warning reported by terraform.
+type Diagnostic struct {
+	// Severity is either error or warning.
+	Severity string `json:"severity"`
+	Summary  string `json:"summary"`
+	Detail   string `json:"detail,omitempty"`
+	// Address is the address of the resource the diagnostic is about, if any.
+	Address string `json:"address,omitempty"`
+	// Filename, StartLine and EndLine locate the configuration the diagnostic
+	// is about, if any.
+	Filename  string `json:"filename,omitempty"`
+	StartLine int    `json:"start_line,omitempty"`
+	EndLine   int    `json:"end_line,omitempty"`
+}
+
+// String returns the diagnostic the way terraform prints it, followed by its
+// file and line range, such as "Error: Unsupported argument (main.tf:3-5)".
+func (d Diagnostic) String() string {
+	severity := d.Severity
+	if severity != "" {
+		severity = strings.ToUpper(severity[:1]) + severity[1:] + ": "
+	}
+	s := severity + d.Summary
+	switch {
+	case d.Filename == "":
+	case d.EndLine > d.StartLine:
+		s += fmt.Sprintf(" (%s:%d-%d)", d.Filename, d.StartLine, d.EndLine)
+	default:
+		s += fmt.Sprintf(" (%s:%d)", d.Filename, d.StartLine)
+	}
+	return s
+}
+
+// PlanChanges counts the resource changes in a plan the way Terraform reports them,
+// where a replacement counts as both an add and a destroy.
+type PlanChanges struct {
+	Add     int `json:"add"`
+	Change  int `json:"change"`
+	Destroy int `json:"destroy"`
+}
+
+// String returns the counts in Terraform's plan summary format.
+func (p *PlanChanges) String() string {
+	return fmt.Sprintf("%d to add, %d to change, %d to destroy", p.Add, p.Change, p.Destroy)
+}
+
+// workspaceResultJSON is the JSON form of a WorkspaceResult.
+type workspaceResultJSON struct {
+	WorkingDir       string               `json:"working_dir"`
+	Success          bool                 `json:"success"`
+	Stage            out.Stage            `json:"stage,omitempty"`
+	Step             Step                 `json:"step,omitempty"`
+	Error            string               `json:"error,omitempty"`
+	TimedOut         bool                 `json:"timed_out,omitempty"`
+	Cancelled        bool                 `json:"cancelled,omitempty"`
+	Drifted          bool                 `json:"drifted,omitempty"`
+	DriftedResources []string             `json:"drifted_resources,omitempty"`
+	HasChanges       bool                 `json:"has_changes"`
+	Changes          *PlanChanges         `json:"changes,omitempty"`
+	Validations      []v.ValidationResult `json:"validations,omitempty"`
+	DurationsMs      map[Step]int64       `json:"durations_ms,omitempty"`
+	Attempts         []attemptJSON        `json:"attempts,omitempty"`
+	Diagnostics      []Diagnostic         `json:"diagnostics,omitempty"`
+	OutputError      string               `json:"output_error,omitempty"`
+}
+
+// attemptJSON is the JSON form of an Attempt.
+type attemptJSON struct {
+	Command    string `json:"command"`
+	Number     int    `json:"number"`
+	DurationMs int64  `json:"duration_ms"`
+	Error      string `json:"error,omitempty"`
+}
+
+// MarshalJSON encodes the result in its stable JSON form.
+func (r WorkspaceResult) MarshalJSON() ([]byte, error) {
+	j := workspaceResultJSON{
+		WorkingDir:       r.WorkingDir,
+		Success:          r.Success,
+		Stage:            r.Stage,
+		Step:             r.Step,
+		Error:            errorMessage(r.Error),
+		TimedOut:         r.TimedOut,
+		Cancelled:        r.Cancelled,
+		Drifted:          r.Drifted,
+		DriftedResources: r.DriftedResources,
+		HasChanges:       r.HasChanges,
+		Changes:          r.Changes,
+		Validations:      r.Validations,
+		Diagnostics:      r.Diagnostics,
+		OutputError:      errorMessage(r.OutputError),
+	}
+	if len(r.Durations) > 0 {
+		j.DurationsMs = make(map[Step]int64, len(r.Durations))
+		for step, d := range r.Durations {
+			j.DurationsMs[step] = d.Milliseconds()
+		}
+	}
+	for _, attempt := range r.Attempts {
+		j.Attempts = append(j.Attempts, attemptJSON{
+			Command:    attempt.Command,
+			Number:     attempt.Number,
+			DurationMs: attempt.Duration.Milliseconds(),
+			Error:      attempt.Error,
+		})
+	}
+	return json.Marshal(j)
+}
+
+// UnmarshalJSON decodes a result from its stable JSON form. Errors are
+// restored as plain errors carrying the original message.
+func (r *WorkspaceResult) UnmarshalJSON(data []byte) error {
+	var j workspaceResultJSON
+	if err := json.Unmarshal(data, &j); err != nil {
+		return err
+	}
+	*r = WorkspaceResult{
+		WorkingDir:       j.WorkingDir,
+		Success:          j.Success,
+		Stage:            j.Stage,
+		Step:             j.Step,
+		Error:            messageError(j.Error),
+		TimedOut:         j.TimedOut,
+		Cancelled:        j.Cancelled,
+		Drifted:          j.Drifted,
+		DriftedResources: j.DriftedResources,
+		HasChanges:       j.HasChanges,
+		Changes:          j.Changes,
+		Validations:      j.Validations,
+		Diagnostics:      j.Diagnostics,
+		OutputError:      messageError(j.OutputError),
+	}
+	if len(j.DurationsMs) > 0 {
+		r.Durations = make(map[Step]time.Duration, len(j.DurationsMs))
+		for step, ms := range j.DurationsMs {
+			r.Durations[step] = time.Duration(ms) * time.Millisecond
+		}
+	}
+	for _, attempt := range j.Attempts {
+		r.Attempts = append(r.Attempts, Attempt{
+			Command:  attempt.Command,
+			Number:   attempt.Number,
+			Duration: time.Duration(attempt.DurationMs) * time.Millisecond,
+			Error:    attempt.Error,
+		})
+	}
+	return nil
+}
+
+func errorMessage(err error) string {
+	if err == nil {
+		return ""
+	}
+	return err.Error()
+}
+
+func messageError(message string) error {
+	if message == "" {
+		return nil
+	}
+	return errors.New(message)
+}
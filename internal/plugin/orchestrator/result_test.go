@@ -0,0 +1,86 @@
+package orchestrator_test
+
+import (
+	"encoding/json"
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/cultureamp/terraform-buildkite-plugin/internal/adapters/outputs"
+	"github.com/cultureamp/terraform-buildkite-plugin/internal/adapters/validators"
+	"github.com/cultureamp/terraform-buildkite-plugin/internal/plugin/orchestrator"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestWorkspaceResult_JSON(t *testing.T) {
+	result := orchestrator.WorkspaceResult{
+		Success:    false,
+		Stage:      outputs.ValidationFailure,
+		Step:       orchestrator.StepValidation,
+		WorkingDir: "stacks/app",
+		Error:      errors.New("validation failed with 1 issues"),
+		HasChanges: true,
+		Changes:    &orchestrator.PlanChanges{Add: 1, Destroy: 2},
+		Validations: []validators.ValidationResult{{
+			Passed:   false,
+			Failures: []validators.ValidationFailure{{Type: "policy", Message: "buckets must be private"}},
+		}},
+		Durations: map[orchestrator.Step]time.Duration{
+			orchestrator.StepInit: 1500 * time.Millisecond,
+			orchestrator.StepPlan: 20 * time.Second,
+		},
+		Attempts: []orchestrator.Attempt{
+			{Command: "plan", Number: 1, Duration: time.Second, Error: "Error acquiring the state lock"},
+			{Command: "plan", Number: 2, Duration: 2 * time.Second},
+		},
+		Diagnostics: []orchestrator.Diagnostic{
+			{Severity: "warning", Summary: "Argument is deprecated", Filename: "main.tf", StartLine: 3, EndLine: 5},
+		},
+	}
+
+	data, err := json.Marshal(result)
+	require.NoError(t, err)
+	assert.JSONEq(t, `{
+		"working_dir": "stacks/app",
+		"success": false,
+		"stage": "validation_failure",
+		"step": "validation",
+		"error": "validation failed with 1 issues",
+		"has_changes": true,
+		"changes": {"add": 1, "change": 0, "destroy": 2},
+		"validations": [{"passed": false, "failures": [{"type": "policy", "message": "buckets must be private"}]}],
+		"durations_ms": {"init": 1500, "plan": 20000},
+		"attempts": [
+			{"command": "plan", "number": 1, "duration_ms": 1000, "error": "Error acquiring the state lock"},
+			{"command": "plan", "number": 2, "duration_ms": 2000}
+		],
+		"diagnostics": [
+			{
+				"severity": "warning",
+				"summary": "Argument is deprecated",
+				"filename": "main.tf",
+				"start_line": 3,
+				"end_line": 5
+			}
+		]
+	}`, string(data))
+
+	var decoded orchestrator.WorkspaceResult
+	require.NoError(t, json.Unmarshal(data, &decoded))
+	require.EqualError(t, decoded.Error, "validation failed with 1 issues")
+	decoded.Error = result.Error
+	assert.Equal(t, result, decoded)
+}
+
+func TestDiagnostic_String(t *testing.T) {
+	assert.Equal(t, "Error: Unsupported argument (main.tf:3-5)", orchestrator.Diagnostic{
+		Severity: "error", Summary: "Unsupported argument", Filename: "main.tf", StartLine: 3, EndLine: 5,
+	}.String())
+	assert.Equal(t, "Warning: Argument is deprecated (main.tf:3)", orchestrator.Diagnostic{
+		Severity: "warning", Summary: "Argument is deprecated", Filename: "main.tf", StartLine: 3, EndLine: 3,
+	}.String())
+	assert.Equal(t, "Error: Error acquiring the state lock", orchestrator.Diagnostic{
+		Severity: "error", Summary: "Error acquiring the state lock",
+	}.String())
+}
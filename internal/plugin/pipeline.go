@@ -0,0 +1,42 @@
+package plugin
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"os"
+
+	"github.com/cultureamp/terraform-buildkite-plugin/internal/adapters/pipeline"
+	a "github.com/cultureamp/terraform-buildkite-plugin/pkg/buildkite/agent"
+	"github.com/rs/zerolog/log"
+)
+
+// uploadPipeline writes a pipeline to a temporary file and uploads it with the Buildkite agent.
+func uploadPipeline(ctx context.Context, agent a.Agent, p *pipeline.Pipeline) error {
+	data, err := json.MarshalIndent(p, "", "  ")
+	if err != nil {
+		return fmt.Errorf("failed to marshal pipeline: %w", err)
+	}
+	file, err := os.CreateTemp("", "terraform-pipeline-*.json")
+	if err != nil {
+		return fmt.Errorf("failed to create pipeline file: %w", err)
+	}
+	defer func() {
+		if rmErr := os.Remove(file.Name()); rmErr != nil {
+			log.Ctx(ctx).Warn().Err(rmErr).Str("file", file.Name()).Msg("failed to remove pipeline file")
+		}
+	}()
+	_, err = file.Write(data)
+	if closeErr := file.Close(); closeErr != nil {
+		err = errors.Join(err, closeErr)
+	}
+	if err != nil {
+		return fmt.Errorf("failed to write pipeline file: %w", err)
+	}
+	log.Ctx(ctx).Debug().RawJSON("pipeline", data).Msg("uploading pipeline")
+	if _, err = agent.UploadPipeline(ctx, file.Name()); err != nil {
+		return fmt.Errorf("failed to upload pipeline: %w", err)
+	}
+	return nil
+}
@@ -0,0 +1,71 @@
+package plugin
+
+import (
+	"context"
+	"encoding/json"
+	"os"
+	"testing"
+
+	"github.com/cultureamp/terraform-buildkite-plugin/internal/adapters/pipeline"
+	c "github.com/cultureamp/terraform-buildkite-plugin/internal/config"
+	i "github.com/cultureamp/terraform-buildkite-plugin/internal/plugin/initiator"
+	o "github.com/cultureamp/terraform-buildkite-plugin/internal/plugin/orchestrator"
+	"github.com/cultureamp/terraform-buildkite-plugin/pkg/buildkite/agent"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// uploadingAgent records the pipelines uploaded through it.
+type uploadingAgent struct {
+	agent.Agent
+	uploads []pipeline.Pipeline
+}
+
+func (u *uploadingAgent) UploadPipeline(_ context.Context, path string) (*string, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil, err
+	}
+	var p pipeline.Pipeline
+	if err = json.Unmarshal(data, &p); err != nil {
+		return nil, err
+	}
+	u.uploads = append(u.uploads, p)
+	return nil, nil
+}
+
+func TestUploadApproval(t *testing.T) {
+	payload := &i.ParsedPayload{
+		Plugin: &c.Plugin{Mode: c.Plan, Approval: &pipeline.Approval{}},
+		Raw: &c.RawPlugin{
+			Reference: "github.com/cultureamp/terraform-buildkite-plugin#v1.0.0",
+			Config:    json.RawMessage(`{"mode": "plan", "approval": {}}`),
+		},
+	}
+
+	t.Run("uploads steps for workspaces with changes", func(t *testing.T) {
+		uploader := &uploadingAgent{}
+		h := &handlerConfig{agent: uploader}
+		results := []*o.WorkspaceResult{
+			{Success: true, WorkingDir: "stacks/network"},
+			{Success: true, WorkingDir: "stacks/app", HasChanges: true, Changes: &o.PlanChanges{Add: 1}},
+		}
+
+		require.NoError(t, h.uploadApproval(t.Context(), payload, results))
+		require.Len(t, uploader.uploads, 1)
+		steps := uploader.uploads[0].Steps
+		require.Len(t, steps, 2)
+		assert.Equal(t, "terraform-apply", steps[0].Key)
+		assert.Contains(t, steps[0].Prompt, "app: 1 to add, 0 to change, 0 to destroy")
+		assert.Equal(t, "terraform-apply-stacks-app", steps[1].Key)
+	})
+
+	t.Run("uploads nothing without changes", func(t *testing.T) {
+		uploader := &uploadingAgent{}
+		h := &handlerConfig{agent: uploader}
+		results := []*o.WorkspaceResult{{Success: true, WorkingDir: "stacks/network"}}
+
+		require.NoError(t, h.uploadApproval(t.Context(), payload, results))
+		assert.Empty(t, uploader.uploads)
+	})
+}
@@ -0,0 +1,195 @@
+package plugin
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+	"time"
+
+	"github.com/cultureamp/terraform-buildkite-plugin/internal/common"
+	c "github.com/cultureamp/terraform-buildkite-plugin/internal/config"
+	o "github.com/cultureamp/terraform-buildkite-plugin/internal/plugin/orchestrator"
+	"github.com/rs/zerolog/log"
+)
+
+// reportMetaDataPrefix prefixes the meta-data keys reports are stored under.
+const reportMetaDataPrefix = "terraform-report:"
+
+// reportVersion is the version of the report format. It is only increased
+// when keys are removed or change meaning.
+const reportVersion = 1
+
+// Report is the JSON run report written once every working directory has run.
+type Report struct {
+	// Version is the version of the report format.
+	Version int `json:"version"`
+	// Mode is the mode the plugin ran in.
+	Mode c.Mode `json:"mode"`
+	// Status is the name of the exit status of the job.
+	Status string `json:"status"`
+	// ExitStatus is the exit status of the job.
+	ExitStatus int `json:"exit_status"`
+	// Build identifies the Buildkite job that produced the report.
+	Build ReportBuild `json:"build"`
+	// StartedAt is when the first working directory started.
+	StartedAt time.Time `json:"started_at"`
+	// FinishedAt is when the last working directory finished.
+	FinishedAt time.Time `json:"finished_at"`
+	// DurationMs is how long the working directories took to run, in milliseconds.
+	DurationMs int64 `json:"duration_ms"`
+	// Error is why the job failed before running any working directory.
+	Error string `json:"error,omitempty"`
+	// Summary counts the outcomes across every working directory.
+	Summary ReportSummary `json:"summary"`
+	// Workspaces contains the result of every working directory.
+	Workspaces []*o.WorkspaceResult `json:"workspaces"`
+}
+
+// ReportBuild identifies the Buildkite job that produced a report.
+type ReportBuild struct {
+	ID               string `json:"id,omitempty"`
+	Number           string `json:"number,omitempty"`
+	JobID            string `json:"job_id,omitempty"`
+	StepKey          string `json:"step_key,omitempty"`
+	ParallelJob      string `json:"parallel_job,omitempty"`
+	ParallelJobCount string `json:"parallel_job_count,omitempty"`
+}
+
+// ReportSummary counts the outcomes across every working directory.
+type ReportSummary struct {
+	Total          int `json:"total"`
+	Succeeded      int `json:"succeeded"`
+	Failed         int `json:"failed"`
+	TimedOut       int `json:"timed_out"`
+	Cancelled      int `json:"cancelled"`
+	Drifted        int `json:"drifted"`
+	WithChanges    int `json:"with_changes"`
+	PolicyFailures int `json:"policy_failures"`
+}
+
+// newReport builds the report for the results of a run.
+func newReport(
+	mode c.Mode,
+	results []*o.WorkspaceResult,
+	status ExitStatus,
+	started, finished time.Time,
+) *Report {
+	report := &Report{
+		Version:    reportVersion,
+		Mode:       mode,
+		Status:     status.GetName(),
+		ExitStatus: status.ToInt(),
+		Build: ReportBuild{
+			ID:               common.FetchEnv("BUILDKITE_BUILD_ID", ""),
+			Number:           common.FetchEnv("BUILDKITE_BUILD_NUMBER", ""),
+			JobID:            common.FetchEnv("BUILDKITE_JOB_ID", ""),
+			StepKey:          common.FetchEnv("BUILDKITE_STEP_KEY", ""),
+			ParallelJob:      common.FetchEnv("BUILDKITE_PARALLEL_JOB", ""),
+			ParallelJobCount: common.FetchEnv("BUILDKITE_PARALLEL_JOB_COUNT", ""),
+		},
+		StartedAt:  started.UTC(),
+		FinishedAt: finished.UTC(),
+		DurationMs: finished.Sub(started).Milliseconds(),
+		Workspaces: results,
+	}
+	if report.Workspaces == nil {
+		report.Workspaces = []*o.WorkspaceResult{}
+	}
+	for _, result := range results {
+		report.Summary.add(result)
+	}
+	return report
+}
+
+// add counts the outcome of a single working directory.
+func (s *ReportSummary) add(result *o.WorkspaceResult) {
+	s.Total++
+	if result.Success {
+		s.Succeeded++
+	} else {
+		s.Failed++
+	}
+	if result.TimedOut {
+		s.TimedOut++
+	}
+	if result.Cancelled {
+		s.Cancelled++
+	}
+	if result.Drifted {
+		s.Drifted++
+	}
+	if result.HasChanges {
+		s.WithChanges++
+	}
+	for _, validation := range result.Validations {
+		s.PolicyFailures += len(validation.Failures)
+	}
+}
+
+// reportPath returns the path the report is written to. Parallel jobs each
+// write their own report, named after their parallel job index.
+func reportPath(config *c.RunReport) string {
+	path := config.Path
+	if path == "" {
+		path = c.DefaultReportPath
+	}
+	if job := common.FetchEnv("BUILDKITE_PARALLEL_JOB", ""); job != "" {
+		ext := filepath.Ext(path)
+		path = fmt.Sprintf("%s-%s%s", strings.TrimSuffix(path, ext), job, ext)
+	}
+	return path
+}
+
+// reportMetaDataKey returns the meta-data key the report is stored under,
+// named after the step and its parallel job index.
+func reportMetaDataKey() string {
+	step := common.FetchEnv("BUILDKITE_STEP_KEY", "")
+	if step == "" {
+		step = common.FetchEnv("BUILDKITE_STEP_ID", "")
+	}
+	return reportMetaDataPrefix + step + ":" + common.FetchEnv("BUILDKITE_PARALLEL_JOB", "0")
+}
+
+// writeReport writes the run report, uploading it as an artifact and storing
+// it in meta-data when configured. Failing to write, upload or store the
+// report never fails the job.
+func (h *handlerConfig) writeReport(ctx context.Context, config *c.RunReport, report *Report) {
+	if config == nil {
+		return
+	}
+	path := reportPath(config)
+	data, err := json.MarshalIndent(report, "", "  ")
+	if err != nil {
+		log.Warn().Err(err).Msg("failed to encode run report")
+		return
+	}
+	if dir := filepath.Dir(path); dir != "." {
+		if err = os.MkdirAll(dir, 0o755); err != nil {
+			log.Warn().Err(err).Str("path", path).Msg("failed to create run report directory")
+			return
+		}
+	}
+	if err = os.WriteFile(path, data, 0o600); err != nil {
+		log.Warn().Err(err).Str("path", path).Msg("failed to write run report")
+		return
+	}
+	log.Info().Str("path", path).Msg("run report written")
+	if config.Upload {
+		if _, err = h.agent.UploadArtifacts(ctx, path); err != nil {
+			log.Warn().Err(err).Str("path", path).Msg("failed to upload run report")
+		} else {
+			log.Info().Str("path", path).Msg("run report uploaded")
+		}
+	}
+	if config.MetaData {
+		key := reportMetaDataKey()
+		if err = h.agent.SetMetaData(ctx, key, string(data)); err != nil {
+			log.Warn().Err(err).Str("key", key).Msg("failed to store run report in meta-data")
+		} else {
+			log.Info().Str("key", key).Msg("run report stored in meta-data")
+		}
+	}
+}
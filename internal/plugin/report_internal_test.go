@@ -0,0 +1,146 @@
+package plugin
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	v "github.com/cultureamp/terraform-buildkite-plugin/internal/adapters/validators"
+	c "github.com/cultureamp/terraform-buildkite-plugin/internal/config"
+	o "github.com/cultureamp/terraform-buildkite-plugin/internal/plugin/orchestrator"
+	"github.com/cultureamp/terraform-buildkite-plugin/pkg/buildkite/agent"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// artifactAgent records the artifacts uploaded and meta-data stored through it.
+type artifactAgent struct {
+	agent.Agent
+	uploads  []string
+	metaData map[string]string
+	err      error
+}
+
+func (a *artifactAgent) UploadArtifacts(_ context.Context, paths string) (*string, error) {
+	a.uploads = append(a.uploads, paths)
+	return nil, a.err
+}
+
+func (a *artifactAgent) SetMetaData(_ context.Context, key string, value string) error {
+	if a.metaData == nil {
+		a.metaData = map[string]string{}
+	}
+	a.metaData[key] = value
+	return a.err
+}
+
+func TestWriteReport(t *testing.T) {
+	for _, key := range []string{
+		"BUILDKITE_BUILD_NUMBER", "BUILDKITE_JOB_ID", "BUILDKITE_STEP_KEY",
+		"BUILDKITE_PARALLEL_JOB", "BUILDKITE_PARALLEL_JOB_COUNT",
+	} {
+		t.Setenv(key, "")
+	}
+	t.Setenv("BUILDKITE_BUILD_ID", "build-id")
+	started := time.Date(2024, 1, 2, 3, 4, 5, 0, time.UTC)
+	results := []*o.WorkspaceResult{
+		{
+			Success:    true,
+			WorkingDir: "stacks/network",
+			Step:       o.StepPlan,
+			HasChanges: true,
+			Changes:    &o.PlanChanges{Add: 1},
+			Durations:  map[o.Step]time.Duration{o.StepPlan: 2 * time.Second},
+		},
+		{
+			WorkingDir: "stacks/app",
+			Step:       o.StepValidation,
+			Error:      errors.New("policy violations found"),
+			Validations: []v.ValidationResult{{Failures: []v.ValidationFailure{
+				{Type: "policy", Message: "bucket must be private"},
+				{Type: "policy", Message: "bucket must be encrypted"},
+			}}},
+		},
+	}
+	report := newReport(c.Plan, results, HandledFailure, started, started.Add(3*time.Second))
+
+	t.Run("writes every workspace and a summary", func(t *testing.T) {
+		path := filepath.Join(t.TempDir(), "reports", "terraform.json")
+		uploader := &artifactAgent{}
+		h := &handlerConfig{agent: uploader}
+
+		h.writeReport(t.Context(), &c.RunReport{Path: path}, report)
+		assert.Empty(t, uploader.uploads)
+
+		data, err := os.ReadFile(path)
+		require.NoError(t, err)
+		var written map[string]any
+		require.NoError(t, json.Unmarshal(data, &written))
+		assert.Equal(t, "HandledFailure", written["status"])
+		assert.InDelta(t, 2, written["exit_status"], 0)
+		assert.InDelta(t, 3000, written["duration_ms"], 0)
+		assert.Equal(t, map[string]any{"id": "build-id"}, written["build"])
+		assert.Equal(t, map[string]any{
+			"total": 2.0, "succeeded": 1.0, "failed": 1.0, "timed_out": 0.0, "cancelled": 0.0,
+			"drifted": 0.0, "with_changes": 1.0, "policy_failures": 2.0,
+		}, written["summary"])
+
+		var decoded Report
+		require.NoError(t, json.Unmarshal(data, &decoded))
+		require.Len(t, decoded.Workspaces, 2)
+		assert.Equal(t, 2*time.Second, decoded.Workspaces[0].Durations[o.StepPlan])
+		assert.EqualError(t, decoded.Workspaces[1].Error, "policy violations found")
+	})
+
+	t.Run("uploads the report when configured", func(t *testing.T) {
+		path := filepath.Join(t.TempDir(), "terraform.json")
+		uploader := &artifactAgent{}
+		h := &handlerConfig{agent: uploader}
+
+		h.writeReport(t.Context(), &c.RunReport{Path: path, Upload: true}, report)
+		assert.Equal(t, []string{path}, uploader.uploads)
+	})
+
+	t.Run("stores the report in meta-data when configured", func(t *testing.T) {
+		t.Setenv("BUILDKITE_STEP_KEY", "plan")
+		t.Setenv("BUILDKITE_PARALLEL_JOB", "1")
+		path := filepath.Join(t.TempDir(), "terraform.json")
+		uploader := &artifactAgent{}
+		h := &handlerConfig{agent: uploader}
+
+		h.writeReport(t.Context(), &c.RunReport{Path: path, MetaData: true}, report)
+		assert.Empty(t, uploader.uploads)
+		require.Contains(t, uploader.metaData, "terraform-report:plan:1")
+		stored, err := parseReport([]byte(uploader.metaData["terraform-report:plan:1"]))
+		require.NoError(t, err)
+		assert.Equal(t, 2, stored.Summary.Total)
+	})
+
+	t.Run("upload failures do not panic", func(t *testing.T) {
+		path := filepath.Join(t.TempDir(), "terraform.json")
+		uploader := &artifactAgent{err: errors.New("upload failed")}
+		h := &handlerConfig{agent: uploader}
+
+		h.writeReport(t.Context(), &c.RunReport{Path: path, Upload: true}, report)
+		assert.FileExists(t, path)
+	})
+
+	t.Run("nothing is written without configuration", func(t *testing.T) {
+		h := &handlerConfig{agent: &artifactAgent{}}
+		h.writeReport(t.Context(), nil, report)
+	})
+}
+
+func TestReportPath(t *testing.T) {
+	t.Setenv("BUILDKITE_PARALLEL_JOB", "")
+	assert.Equal(t, "terraform-report.json", reportPath(&c.RunReport{}))
+	assert.Equal(t, "out/plan.json", reportPath(&c.RunReport{Path: "out/plan.json"}))
+
+	t.Setenv("BUILDKITE_PARALLEL_JOB", "2")
+	assert.Equal(t, "terraform-report-2.json", reportPath(&c.RunReport{}))
+	assert.Equal(t, "out/plan-2.json", reportPath(&c.RunReport{Path: "out/plan.json"}))
+}
@@ -0,0 +1,287 @@
+package plugin
+
+import (
+	"cmp"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io/fs"
+	"os"
+	"path/filepath"
+	"sort"
+	"strconv"
+	"strings"
+
+	c "github.com/cultureamp/terraform-buildkite-plugin/internal/config"
+	a "github.com/cultureamp/terraform-buildkite-plugin/pkg/buildkite/agent"
+	"github.com/rs/zerolog/log"
+)
+
+// summaryContext is the annotation context of the aggregated summary.
+const summaryContext = "terraform-report"
+
+// handleReport collects the run reports of other jobs, annotates the build
+// with one summary across all of them and exits non-zero when any job failed
+// or any parallel job wrote no report.
+func (h *handlerConfig) handleReport(ctx context.Context, config *c.RunReport) (ExitStatus, error) {
+	reports, err := h.collectReports(ctx, config)
+	if err != nil {
+		return UnexpectedFailure, err
+	}
+	if len(reports) == 0 {
+		return UnexpectedFailure, fmt.Errorf("no run reports found in %s", config.CollectFrom())
+	}
+	status := aggregateStatus(reports)
+	missing := missingJobs(reports, config.Jobs)
+	if len(missing) > 0 {
+		log.Error().Ints("jobs", missing).Msg("parallel jobs did not write a run report")
+		if status == Success || status == DriftDetected {
+			status = HandledFailure
+		}
+	}
+	log.Info().Int("reports", len(reports)).Str("status", status.GetName()).Msg("collected run reports")
+	if _, err = h.agent.Annotate(
+		ctx,
+		a.WithMessage(renderSummary(reports, missing, status)),
+		a.WithStyle(summaryStyle(status)),
+		a.WithContext(summaryContext),
+	); err != nil {
+		log.Warn().Err(err).Msg("failed to annotate build with run report summary")
+	}
+	return status, nil
+}
+
+// collectReports returns the reports collected from the configured source,
+// ordered by parallel job index.
+func (h *handlerConfig) collectReports(ctx context.Context, config *c.RunReport) ([]*Report, error) {
+	var reports []*Report
+	var err error
+	switch config.CollectFrom() {
+	case c.ReportMetaData:
+		reports, err = h.collectMetaDataReports(ctx, config)
+	default:
+		reports, err = h.collectArtifactReports(ctx, config)
+	}
+	if err != nil {
+		return nil, err
+	}
+	sort.SliceStable(reports, func(i, j int) bool {
+		return parallelJobIndex(reports[i]) < parallelJobIndex(reports[j])
+	})
+	return reports, nil
+}
+
+// collectArtifactReports downloads every report artifact matching the
+// configured path, including those named after a parallel job index.
+func (h *handlerConfig) collectArtifactReports(ctx context.Context, config *c.RunReport) ([]*Report, error) {
+	path := config.Path
+	if path == "" {
+		path = c.DefaultReportPath
+	}
+	ext := filepath.Ext(path)
+	query := strings.TrimSuffix(path, ext) + "*" + ext
+	dir, err := os.MkdirTemp("", "terraform-reports-")
+	if err != nil {
+		return nil, fmt.Errorf("failed to create directory for reports: %w", err)
+	}
+	defer os.RemoveAll(dir)
+
+	var opts []a.ArtifactOptions
+	if config.Step != "" {
+		opts = append(opts, a.WithStep(config.Step))
+	}
+	if _, err = h.agent.DownloadArtifacts(ctx, query, dir, opts...); err != nil {
+		return nil, fmt.Errorf("failed to download reports matching %s: %w", query, err)
+	}
+	var reports []*Report
+	err = filepath.WalkDir(dir, func(file string, entry fs.DirEntry, walkErr error) error {
+		if walkErr != nil || entry.IsDir() {
+			return walkErr
+		}
+		data, readErr := os.ReadFile(file)
+		if readErr != nil {
+			return readErr
+		}
+		report, parseErr := parseReport(data)
+		if parseErr != nil {
+			return fmt.Errorf("invalid report %s: %w", filepath.Base(file), parseErr)
+		}
+		reports = append(reports, report)
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+	return reports, nil
+}
+
+// collectMetaDataReports reads every report stored in meta-data, limited to
+// the configured step when there is one.
+func (h *handlerConfig) collectMetaDataReports(ctx context.Context, config *c.RunReport) ([]*Report, error) {
+	keys, err := h.agent.MetaDataKeys(ctx)
+	if err != nil {
+		return nil, fmt.Errorf("failed to list meta-data keys: %w", err)
+	}
+	prefix := reportMetaDataPrefix
+	if config.Step != "" {
+		prefix += config.Step + ":"
+	}
+	var reports []*Report
+	for _, key := range keys {
+		if !strings.HasPrefix(key, prefix) {
+			continue
+		}
+		value, getErr := h.agent.GetMetaData(ctx, key)
+		if getErr != nil {
+			return nil, fmt.Errorf("failed to read report %s: %w", key, getErr)
+		}
+		report, parseErr := parseReport([]byte(value))
+		if parseErr != nil {
+			return nil, fmt.Errorf("invalid report %s: %w", key, parseErr)
+		}
+		reports = append(reports, report)
+	}
+	return reports, nil
+}
+
+// parseReport decodes a report, refusing versions newer than this plugin understands.
+func parseReport(data []byte) (*Report, error) {
+	var report Report
+	if err := json.Unmarshal(data, &report); err != nil {
+		return nil, err
+	}
+	if report.Version > reportVersion {
+		return nil, fmt.Errorf("unsupported report version %d", report.Version)
+	}
+	if report.Version == 0 {
+		return nil, errors.New("missing report version")
+	}
+	return &report, nil
+}
+
+// parallelJobIndex returns the parallel job index of the job that wrote a
+// report, or zero when it did not run in parallel.
+func parallelJobIndex(report *Report) int {
+	index, err := strconv.Atoi(report.Build.ParallelJob)
+	if err != nil {
+		return 0
+	}
+	return index
+}
+
+// missingJobs returns the indexes of the parallel jobs without a report. The
+// configured number of jobs is expected, or otherwise the largest parallel job
+// count recorded in the reports.
+func missingJobs(reports []*Report, expected int) []int {
+	reported := map[int]bool{}
+	for _, report := range reports {
+		reported[parallelJobIndex(report)] = true
+		if count, err := strconv.Atoi(report.Build.ParallelJobCount); err == nil && count > expected {
+			expected = count
+		}
+	}
+	var missing []int
+	for job := range expected {
+		if !reported[job] {
+			missing = append(missing, job)
+		}
+	}
+	return missing
+}
+
+// aggregateStatus returns the exit status across every report. Cancellation
+// takes precedence over timeouts, which take precedence over other failures,
+// which take precedence over drift.
+func aggregateStatus(reports []*Report) ExitStatus {
+	status := Success
+	for _, report := range reports {
+		switch reported := ExitStatus(report.ExitStatus); reported {
+		case Success, NoWorkingDirectories:
+		case Cancelled:
+			return Cancelled
+		case TimedOut:
+			status = TimedOut
+		case DriftDetected:
+			if status == Success {
+				status = DriftDetected
+			}
+		default:
+			if status == Success || status == DriftDetected {
+				status = HandledFailure
+			}
+		}
+	}
+	return status
+}
+
+// summaryStyle returns the annotation style for an aggregated exit status.
+func summaryStyle(status ExitStatus) a.AnnotationStyle {
+	switch status {
+	case Success:
+		return a.StyleSuccess
+	case DriftDetected:
+		return a.StyleWarning
+	default:
+		return a.StyleError
+	}
+}
+
+// renderSummary renders the aggregated summary annotation as Markdown.
+func renderSummary(reports []*Report, missing []int, status ExitStatus) string {
+	var total ReportSummary
+	for _, report := range reports {
+		total.Total += report.Summary.Total
+		total.Succeeded += report.Summary.Succeeded
+		total.Failed += report.Summary.Failed
+		total.Drifted += report.Summary.Drifted
+		total.WithChanges += report.Summary.WithChanges
+		total.PolicyFailures += report.Summary.PolicyFailures
+	}
+
+	var b strings.Builder
+	fmt.Fprintf(&b, "### :terraform: Terraform %s: %s\n\n", reports[0].Mode, status.GetName())
+	fmt.Fprintf(&b, "%d workspaces across %d jobs: %d succeeded, %d failed, %d with changes, %d drifted, "+
+		"%d policy failures.\n\n",
+		total.Total, len(reports), total.Succeeded, total.Failed, total.WithChanges, total.Drifted,
+		total.PolicyFailures)
+
+	b.WriteString("| Job | Status | Workspaces | Failed | With changes | Drifted |\n")
+	b.WriteString("| --- | --- | --- | --- | --- | --- |\n")
+	for _, report := range reports {
+		job := report.Build.ParallelJob
+		if job == "" {
+			job = "0"
+		}
+		fmt.Fprintf(&b, "| %s | %s | %d | %d | %d | %d |\n",
+			job, report.Status, report.Summary.Total, report.Summary.Failed,
+			report.Summary.WithChanges, report.Summary.Drifted)
+	}
+
+	var failures []string
+	for _, job := range missing {
+		failures = append(failures, fmt.Sprintf("- Job %d did not write a run report", job))
+	}
+	for _, report := range reports {
+		if report.Error != "" {
+			failures = append(failures, fmt.Sprintf("- Job %s failed before running any workspace: %s",
+				cmp.Or(report.Build.ParallelJob, "0"), strings.ReplaceAll(report.Error, "\n", " ")))
+		}
+		for _, result := range report.Workspaces {
+			if result.Success {
+				continue
+			}
+			failure := fmt.Sprintf("- `%s` failed in %s", result.WorkingDir, result.Step)
+			if result.Error != nil {
+				failure += ": " + strings.ReplaceAll(result.Error.Error(), "\n", " ")
+			}
+			failures = append(failures, failure)
+		}
+	}
+	if len(failures) > 0 {
+		b.WriteString("\n#### Failures\n\n")
+		b.WriteString(strings.Join(failures, "\n"))
+		b.WriteString("\n")
+	}
+	return b.String()
+}
@@ -0,0 +1,197 @@
+package plugin
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	c "github.com/cultureamp/terraform-buildkite-plugin/internal/config"
+	o "github.com/cultureamp/terraform-buildkite-plugin/internal/plugin/orchestrator"
+	"github.com/cultureamp/terraform-buildkite-plugin/pkg/buildkite/agent"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// reportsAgent serves reports as artifacts or meta-data and records annotations.
+type reportsAgent struct {
+	agent.Agent
+	artifacts   map[string][]byte
+	metaData    map[string]string
+	queries     []string
+	steps       int
+	annotations int
+}
+
+func (r *reportsAgent) DownloadArtifacts(
+	_ context.Context,
+	query string,
+	destination string,
+	opts ...agent.ArtifactOptions,
+) (*string, error) {
+	r.queries = append(r.queries, query)
+	r.steps = len(opts)
+	if len(r.artifacts) == 0 {
+		return nil, errors.New("no artifacts found")
+	}
+	for name, data := range r.artifacts {
+		if err := os.WriteFile(filepath.Join(destination, name), data, 0o600); err != nil {
+			return nil, err
+		}
+	}
+	return nil, nil
+}
+
+func (r *reportsAgent) MetaDataKeys(_ context.Context) ([]string, error) {
+	keys := []string{"terraform-plan:stacks/app"}
+	for key := range r.metaData {
+		keys = append(keys, key)
+	}
+	return keys, nil
+}
+
+func (r *reportsAgent) GetMetaData(_ context.Context, key string) (string, error) {
+	return r.metaData[key], nil
+}
+
+func (r *reportsAgent) Annotate(_ context.Context, _ ...agent.AnnotateOptions) (*string, error) {
+	r.annotations++
+	return nil, nil
+}
+
+func shardReport(t *testing.T, job string, status ExitStatus, results ...*o.WorkspaceResult) []byte {
+	t.Helper()
+	t.Setenv("BUILDKITE_PARALLEL_JOB", job)
+	started := time.Date(2024, 1, 2, 3, 4, 5, 0, time.UTC)
+	data, err := json.Marshal(newReport(c.Plan, results, status, started, started.Add(time.Second)))
+	require.NoError(t, err)
+	return data
+}
+
+func TestHandleReport(t *testing.T) {
+	succeeded := shardReport(t, "0", Success, &o.WorkspaceResult{
+		Success: true, WorkingDir: "stacks/network", HasChanges: true, Changes: &o.PlanChanges{Add: 1},
+	})
+	failed := shardReport(t, "1", HandledFailure,
+		&o.WorkspaceResult{WorkingDir: "stacks/app", Step: o.StepPlan, Error: errors.New("plan failed")})
+
+	t.Run("collects artifacts from every job", func(t *testing.T) {
+		reports := &reportsAgent{artifacts: map[string][]byte{
+			"terraform-report-1.json": failed,
+			"terraform-report-0.json": succeeded,
+		}}
+		h := &handlerConfig{agent: reports}
+
+		status, err := h.handleReport(t.Context(), &c.RunReport{Step: "plan"})
+		require.NoError(t, err)
+		assert.Equal(t, HandledFailure, status)
+		assert.Equal(t, []string{"terraform-report*.json"}, reports.queries)
+		assert.Equal(t, 1, reports.steps)
+		assert.Equal(t, 1, reports.annotations)
+
+		collected, err := h.collectReports(t.Context(), &c.RunReport{})
+		require.NoError(t, err)
+		require.Len(t, collected, 2)
+		assert.Equal(t, "0", collected[0].Build.ParallelJob)
+		assert.Equal(t, "1", collected[1].Build.ParallelJob)
+	})
+
+	t.Run("collects meta-data for the configured step", func(t *testing.T) {
+		reports := &reportsAgent{metaData: map[string]string{
+			"terraform-report:plan:0":  string(succeeded),
+			"terraform-report:other:0": string(failed),
+		}}
+		h := &handlerConfig{agent: reports}
+
+		status, err := h.handleReport(t.Context(), &c.RunReport{Source: c.ReportMetaData, Step: "plan"})
+		require.NoError(t, err)
+		assert.Equal(t, Success, status)
+	})
+
+	t.Run("no reports is an error", func(t *testing.T) {
+		h := &handlerConfig{agent: &reportsAgent{}}
+
+		status, err := h.handleReport(t.Context(), &c.RunReport{Source: c.ReportMetaData})
+		require.ErrorContains(t, err, "no run reports found in meta_data")
+		assert.Equal(t, UnexpectedFailure, status)
+
+		status, err = h.handleReport(t.Context(), &c.RunReport{})
+		require.ErrorContains(t, err, "failed to download reports matching terraform-report*.json")
+		assert.Equal(t, UnexpectedFailure, status)
+	})
+
+	t.Run("missing parallel job reports fail the build", func(t *testing.T) {
+		t.Setenv("BUILDKITE_PARALLEL_JOB_COUNT", "3")
+		first := shardReport(t, "0", Success, &o.WorkspaceResult{Success: true, WorkingDir: "stacks/network"})
+		reports := &reportsAgent{artifacts: map[string][]byte{"terraform-report-0.json": first}}
+		h := &handlerConfig{agent: reports}
+
+		status, err := h.handleReport(t.Context(), &c.RunReport{})
+		require.NoError(t, err)
+		assert.Equal(t, HandledFailure, status)
+	})
+
+	t.Run("newer report versions are refused", func(t *testing.T) {
+		_, err := parseReport([]byte(`{"version": 2}`))
+		require.ErrorContains(t, err, "unsupported report version 2")
+	})
+}
+
+func TestAggregateStatus(t *testing.T) {
+	reports := func(statuses ...ExitStatus) []*Report {
+		var r []*Report
+		for _, status := range statuses {
+			r = append(r, &Report{ExitStatus: status.ToInt()})
+		}
+		return r
+	}
+	assert.Equal(t, Success, aggregateStatus(reports(Success, NoWorkingDirectories)))
+	assert.Equal(t, DriftDetected, aggregateStatus(reports(Success, DriftDetected)))
+	assert.Equal(t, HandledFailure, aggregateStatus(reports(DriftDetected, UnexpectedFailure, Success)))
+	assert.Equal(t, TimedOut, aggregateStatus(reports(TimedOut, HandledFailure)))
+	assert.Equal(t, Cancelled, aggregateStatus(reports(TimedOut, Cancelled, HandledFailure)))
+}
+
+func TestMissingJobs(t *testing.T) {
+	reports := []*Report{
+		{Build: ReportBuild{ParallelJob: "0", ParallelJobCount: "4"}},
+		{Build: ReportBuild{ParallelJob: "2", ParallelJobCount: "4"}},
+	}
+	assert.Equal(t, []int{1, 3}, missingJobs(reports, 0))
+	assert.Equal(t, []int{1, 3, 4}, missingJobs(reports, 5))
+	assert.Empty(t, missingJobs([]*Report{{}}, 0))
+	assert.Equal(t, []int{1}, missingJobs([]*Report{{}}, 2))
+}
+
+func TestRenderSummary(t *testing.T) {
+	report := &Report{
+		Mode:   c.Plan,
+		Status: "HandledFailure",
+		Build:  ReportBuild{ParallelJob: "1"},
+		Summary: ReportSummary{
+			Total: 2, Succeeded: 1, Failed: 1, WithChanges: 1, PolicyFailures: 3,
+		},
+		Workspaces: []*o.WorkspaceResult{
+			{Success: true, WorkingDir: "stacks/network"},
+			{WorkingDir: "stacks/app", Step: o.StepValidation, Error: errors.New("policy\nviolations")},
+		},
+	}
+	failedEarly := &Report{
+		Mode:   c.Plan,
+		Status: "UnexpectedFailure",
+		Build:  ReportBuild{ParallelJob: "2"},
+		Error:  "terraform binary not found in PATH",
+	}
+	summary := renderSummary([]*Report{report, failedEarly}, []int{0}, HandledFailure)
+	assert.Contains(t, summary, "### :terraform: Terraform plan: HandledFailure")
+	assert.Contains(t, summary, "2 workspaces across 2 jobs: 1 succeeded, 1 failed, 1 with changes, 0 drifted, "+
+		"3 policy failures.")
+	assert.Contains(t, summary, "| 1 | HandledFailure | 2 | 1 | 1 | 0 |")
+	assert.Contains(t, summary, "- `stacks/app` failed in validation: policy violations")
+	assert.Contains(t, summary, "- Job 0 did not write a run report")
+	assert.Contains(t, summary, "- Job 2 failed before running any workspace: terraform binary not found in PATH")
+	assert.NotContains(t, summary, "stacks/network")
+}
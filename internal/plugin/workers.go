@@ -0,0 +1,206 @@
+package plugin
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"slices"
+	"sync"
+
+	"github.com/cultureamp/terraform-buildkite-plugin/internal/common"
+	o "github.com/cultureamp/terraform-buildkite-plugin/internal/plugin/orchestrator"
+	"github.com/rs/zerolog/log"
+)
+
+// runWorkspaces runs the orchestrator for every working directory and returns
+// the results in the same order as workingDirs.
+//
+// Working directories run in dependency order: a working directory starts once
+// every working directory it depends on has succeeded, and is skipped if any of
+// them did not. Independent working directories run together in a bounded
+// worker pool of the given concurrency.
+//
+// With a concurrency greater than one, each workspace logs and streams its
+// terraform output to its own buffer which is flushed as a single block once
+// the workspace completes, so output from different workspaces never
+// interleaves. Cancelling ctx stops new workspaces from starting and
+// interrupts every in-flight terraform process.
+func runWorkspaces(
+	ctx context.Context,
+	orchestrator o.PluginOrchestrator,
+	workingDirs []string,
+	dependencies map[string][]string,
+	concurrency int,
+) []*o.WorkspaceResult {
+	concurrency = max(concurrency, 1)
+	if concurrency > 1 {
+		log.Info().Int("concurrency", concurrency).Msg("running workspaces concurrently")
+	}
+	s := newScheduler(workingDirs, dependencies)
+	type completion struct {
+		index  int
+		result *o.WorkspaceResult
+	}
+	var mu sync.Mutex
+	done := make(chan completion)
+	running := 0
+	for s.remaining > 0 {
+		for len(s.ready) > 0 && running < concurrency {
+			i := s.next()
+			if err := ctx.Err(); err != nil {
+				log.Warn().Str("workspace", filepath.Base(workingDirs[i])).Err(err).
+					Msg("workspace was not started")
+				s.complete(i, &o.WorkspaceResult{
+					Success:    false,
+					Step:       o.StepScheduling,
+					WorkingDir: workingDirs[i],
+					Error:      fmt.Errorf("workspace was not started: %w", err),
+					Cancelled:  true,
+				})
+				continue
+			}
+			running++
+			go func() {
+				if concurrency == 1 {
+					log.Info().Str("workspace", filepath.Base(workingDirs[i])).
+						Msg("running orchestrator for workspace")
+					done <- completion{i, orchestrator.Run(ctx, workingDirs[i])}
+					return
+				}
+				done <- completion{i, runBuffered(ctx, orchestrator, workingDirs[i], &mu)}
+			}()
+		}
+		if running == 0 {
+			// Nothing is running and nothing is ready, so the remaining
+			// workspaces can never start. Dependencies are validated up front,
+			// so this only guards against scheduling bugs.
+			s.abandon()
+			break
+		}
+		c := <-done
+		running--
+		s.complete(c.index, c.result)
+	}
+	return s.results
+}
+
+// runBuffered runs a single workspace with its logs and terraform output
+// captured in a buffer and flushed to stdout in one block once the workspace
+// completes.
+func runBuffered(
+	ctx context.Context,
+	orchestrator o.PluginOrchestrator,
+	workingDir string,
+	mu *sync.Mutex,
+) *o.WorkspaceResult {
+	var buf bytes.Buffer
+	logger := log.Logger.Output(common.NewConsoleWriter(&buf))
+	logger.Info().Str("workspace", filepath.Base(workingDir)).
+		Msg("running orchestrator for workspace")
+	result := orchestrator.Run(o.WithOutput(logger.WithContext(ctx), &buf), workingDir)
+	mu.Lock()
+	defer mu.Unlock()
+	if _, err := os.Stdout.Write(buf.Bytes()); err != nil {
+		log.Warn().Err(err).Str("workspace", filepath.Base(workingDir)).
+			Msg("failed to write workspace logs")
+	}
+	return result
+}
+
+// scheduler tracks which working directories are ready to run based on their dependencies.
+type scheduler struct {
+	workingDirs []string
+	results     []*o.WorkspaceResult
+	waiting     []int   // number of unfinished dependencies for each working directory
+	dependents  [][]int // indexes of the working directories that depend on each working directory
+	ready       []int   // indexes ready to run, kept in working directory order
+	remaining   int     // number of working directories without a result
+}
+
+func newScheduler(workingDirs []string, dependencies map[string][]string) *scheduler {
+	s := &scheduler{
+		workingDirs: workingDirs,
+		results:     make([]*o.WorkspaceResult, len(workingDirs)),
+		waiting:     make([]int, len(workingDirs)),
+		dependents:  make([][]int, len(workingDirs)),
+		remaining:   len(workingDirs),
+	}
+	index := make(map[string]int, len(workingDirs))
+	for i, workingDir := range workingDirs {
+		index[workingDir] = i
+	}
+	for i, workingDir := range workingDirs {
+		for _, dependency := range dependencies[workingDir] {
+			j, ok := index[dependency]
+			if !ok || j == i {
+				continue
+			}
+			s.waiting[i]++
+			s.dependents[j] = append(s.dependents[j], i)
+		}
+	}
+	for i := range workingDirs {
+		if s.waiting[i] == 0 {
+			s.ready = append(s.ready, i)
+		}
+	}
+	return s
+}
+
+// next removes and returns the first ready working directory.
+func (s *scheduler) next() int {
+	i := s.ready[0]
+	s.ready = s.ready[1:]
+	return i
+}
+
+// complete records the result of a working directory, releasing dependents
+// when it succeeded and skipping them when it did not.
+func (s *scheduler) complete(i int, result *o.WorkspaceResult) {
+	s.results[i] = result
+	s.remaining--
+	for _, dependent := range s.dependents[i] {
+		if s.results[dependent] != nil {
+			continue
+		}
+		if result == nil || !result.Success {
+			log.Warn().
+				Str("workspace", filepath.Base(s.workingDirs[dependent])).
+				Str("dependency", filepath.Base(s.workingDirs[i])).
+				Msg("skipping workspace because a dependency did not succeed")
+			s.complete(dependent, &o.WorkspaceResult{
+				Success:    false,
+				Step:       o.StepSkipped,
+				WorkingDir: s.workingDirs[dependent],
+				Error: fmt.Errorf(
+					"skipped because dependency %s did not succeed",
+					filepath.Base(s.workingDirs[i]),
+				),
+			})
+			continue
+		}
+		s.waiting[dependent]--
+		if s.waiting[dependent] == 0 {
+			s.ready = append(s.ready, dependent)
+			slices.Sort(s.ready)
+		}
+	}
+}
+
+// abandon records a failure for every working directory that could not be scheduled.
+func (s *scheduler) abandon() {
+	for i, result := range s.results {
+		if result == nil {
+			s.results[i] = &o.WorkspaceResult{
+				Success:    false,
+				Step:       o.StepScheduling,
+				WorkingDir: s.workingDirs[i],
+				Error:      errors.New("workspace could not be scheduled because of a dependency cycle"),
+			}
+		}
+	}
+	s.remaining = 0
+}
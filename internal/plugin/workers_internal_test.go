@@ -0,0 +1,122 @@
+package plugin
+
+import (
+	"context"
+	"sync"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	o "github.com/cultureamp/terraform-buildkite-plugin/internal/plugin/orchestrator"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// countingOrchestrator records how many workspaces run at the same time.
+type countingOrchestrator struct {
+	o.PluginOrchestrator
+	running atomic.Int32
+	peak    atomic.Int32
+}
+
+func (c *countingOrchestrator) Run(ctx context.Context, workingDir string) *o.WorkspaceResult {
+	n := c.running.Add(1)
+	defer c.running.Add(-1)
+	for {
+		peak := c.peak.Load()
+		if n <= peak || c.peak.CompareAndSwap(peak, n) {
+			break
+		}
+	}
+	select {
+	case <-time.After(10 * time.Millisecond):
+		return &o.WorkspaceResult{Success: true, WorkingDir: workingDir}
+	case <-ctx.Done():
+		return &o.WorkspaceResult{Success: false, WorkingDir: workingDir, Error: ctx.Err()}
+	}
+}
+
+func TestRunWorkspaces(t *testing.T) {
+	dirs := []string{"stacks/a", "stacks/b", "stacks/c", "stacks/d", "stacks/e"}
+
+	t.Run("sequential preserves order", func(t *testing.T) {
+		orch := &countingOrchestrator{}
+		results := runWorkspaces(t.Context(), orch, dirs, nil, 1)
+		require.Len(t, results, len(dirs))
+		for i, result := range results {
+			assert.Equal(t, dirs[i], result.WorkingDir)
+		}
+		assert.Equal(t, int32(1), orch.peak.Load())
+	})
+
+	t.Run("concurrent is bounded and preserves order", func(t *testing.T) {
+		orch := &countingOrchestrator{}
+		results := runWorkspaces(t.Context(), orch, dirs, nil, 2)
+		require.Len(t, results, len(dirs))
+		for i, result := range results {
+			assert.Equal(t, dirs[i], result.WorkingDir)
+			assert.True(t, result.Success)
+		}
+		assert.LessOrEqual(t, orch.peak.Load(), int32(2))
+	})
+
+	t.Run("cancelled context does not start workspaces", func(t *testing.T) {
+		ctx, cancel := context.WithCancel(t.Context())
+		cancel()
+		orch := &countingOrchestrator{}
+		results := runWorkspaces(ctx, orch, dirs, nil, 3)
+		require.Len(t, results, len(dirs))
+		for i, result := range results {
+			assert.Equal(t, dirs[i], result.WorkingDir)
+			assert.False(t, result.Success)
+			assert.Equal(t, o.StepScheduling, result.Step)
+			assert.True(t, result.Cancelled)
+		}
+		assert.Equal(t, int32(0), orch.peak.Load())
+	})
+}
+
+// orderedOrchestrator records the order workspaces start in and fails selected workspaces.
+type orderedOrchestrator struct {
+	o.PluginOrchestrator
+	mu      sync.Mutex
+	started []string
+	fail    map[string]bool
+}
+
+func (r *orderedOrchestrator) Run(_ context.Context, workingDir string) *o.WorkspaceResult {
+	r.mu.Lock()
+	r.started = append(r.started, workingDir)
+	r.mu.Unlock()
+	return &o.WorkspaceResult{Success: !r.fail[workingDir], WorkingDir: workingDir}
+}
+
+func TestRunWorkspaces_Dependencies(t *testing.T) {
+	dirs := []string{"stacks/app", "stacks/cluster", "stacks/network", "stacks/dns"}
+	dependencies := map[string][]string{
+		"stacks/app":     {"stacks/cluster"},
+		"stacks/cluster": {"stacks/network"},
+	}
+
+	t.Run("runs in topological order", func(t *testing.T) {
+		orch := &orderedOrchestrator{}
+		results := runWorkspaces(t.Context(), orch, dirs, dependencies, 1)
+		require.Len(t, results, len(dirs))
+		assert.Equal(t, []string{"stacks/network", "stacks/cluster", "stacks/app", "stacks/dns"}, orch.started)
+		for i, result := range results {
+			assert.Equal(t, dirs[i], result.WorkingDir)
+			assert.True(t, result.Success)
+		}
+	})
+
+	t.Run("skips dependents of failed workspaces", func(t *testing.T) {
+		orch := &orderedOrchestrator{fail: map[string]bool{"stacks/network": true}}
+		results := runWorkspaces(t.Context(), orch, dirs, dependencies, 2)
+		require.Len(t, results, len(dirs))
+		assert.ElementsMatch(t, []string{"stacks/network", "stacks/dns"}, orch.started)
+		assert.Equal(t, o.StepSkipped, results[0].Step)
+		assert.Equal(t, o.StepSkipped, results[1].Step)
+		assert.False(t, results[2].Success)
+		assert.True(t, results[3].Success)
+	})
+}
@@ -4,12 +4,18 @@ import (
 	"context"
 	"fmt"
 	"os/exec"
+	"strings"
 )
 
 type Agent interface {
 	UploadPipeline(ctx context.Context, pipeline string) (*string, error)
 	Annotate(ctx context.Context, opts ...AnnotateOptions) (*string, error)
 	AnnotateWithTemplate(ctx context.Context, templatePath string, data any, opts ...AnnotateOptions) (*string, error)
+	UploadArtifacts(ctx context.Context, paths string) (*string, error)
+	DownloadArtifacts(ctx context.Context, query string, destination string, opts ...ArtifactOptions) (*string, error)
+	SetMetaData(ctx context.Context, key string, value string) error
+	GetMetaData(ctx context.Context, key string) (string, error)
+	MetaDataKeys(ctx context.Context) ([]string, error)
 }
 
 type config struct {
@@ -44,6 +50,56 @@ func (a *config) UploadPipeline(ctx context.Context, pipeline string) (*string,
 	return a.runCommand(ctx, "buildkite-agent", "pipeline", "upload", pipeline)
 }
 
+// UploadArtifacts uploads files matching the given path pattern as build artifacts.
+func (a *config) UploadArtifacts(ctx context.Context, paths string) (*string, error) {
+	return a.runCommand(ctx, "buildkite-agent", "artifact", "upload", paths)
+}
+
+// DownloadArtifacts downloads build artifacts matching the query into the destination directory.
+func (a *config) DownloadArtifacts(
+	ctx context.Context,
+	query string,
+	destination string,
+	opts ...ArtifactOptions,
+) (*string, error) {
+	config := artifactConfig{}
+	for _, opt := range opts {
+		opt(&config)
+	}
+	args := []string{"artifact", "download", query, destination}
+	if config.step != "" {
+		args = append(args, "--step", config.step)
+	}
+	if config.build != "" {
+		args = append(args, "--build", config.build)
+	}
+	return a.runCommand(ctx, "buildkite-agent", args...)
+}
+
+// SetMetaData stores a value in the build's meta-data under the given key.
+func (a *config) SetMetaData(ctx context.Context, key string, value string) error {
+	_, err := a.runCommand(ctx, "buildkite-agent", "meta-data", "set", key, value)
+	return err
+}
+
+// GetMetaData returns the value stored in the build's meta-data under the given key.
+func (a *config) GetMetaData(ctx context.Context, key string) (string, error) {
+	out, err := a.runCommand(ctx, "buildkite-agent", "meta-data", "get", key)
+	if err != nil {
+		return "", err
+	}
+	return strings.TrimSpace(*out), nil
+}
+
+// MetaDataKeys returns the keys of every value stored in the build's meta-data.
+func (a *config) MetaDataKeys(ctx context.Context) ([]string, error) {
+	out, err := a.runCommand(ctx, "buildkite-agent", "meta-data", "keys")
+	if err != nil {
+		return nil, err
+	}
+	return strings.Fields(*out), nil
+}
+
 // Annotate allows you to add annotations to the Buildkite build.
 func (a *config) Annotate(ctx context.Context, opts ...AnnotateOptions) (*string, error) {
 	// Set default options
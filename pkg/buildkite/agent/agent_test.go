@@ -29,6 +29,67 @@ func TestAgent_UploadPipeline(t *testing.T) {
 	})
 }
 
+func TestAgent_UploadArtifacts(t *testing.T) {
+	t.Run("calls runCommand", func(t *testing.T) {
+		var gotArgs []string
+		agentWithMock := agent.NewAgent(agent.WithCommandFn(func(_ string, args ...string) *exec.Cmd {
+			gotArgs = args
+			return exec.Command("echo", "artifacts uploaded")
+		}))
+		result, err := agentWithMock.UploadArtifacts(t.Context(), "plans/foo/*")
+		require.NoError(t, err)
+		assert.NotNil(t, result)
+		assert.Equal(t, []string{"artifact", "upload", "plans/foo/*"}, gotArgs)
+	})
+}
+
+func TestAgent_DownloadArtifacts(t *testing.T) {
+	t.Run("passes step and build options", func(t *testing.T) {
+		var gotArgs []string
+		agentWithMock := agent.NewAgent(agent.WithCommandFn(func(_ string, args ...string) *exec.Cmd {
+			gotArgs = args
+			return exec.Command("echo", "artifacts downloaded")
+		}))
+		result, err := agentWithMock.DownloadArtifacts(
+			t.Context(), "plans/foo/*", ".", agent.WithStep("plan"), agent.WithBuild("1234"),
+		)
+		require.NoError(t, err)
+		assert.NotNil(t, result)
+		assert.Equal(t,
+			[]string{"artifact", "download", "plans/foo/*", ".", "--step", "plan", "--build", "1234"},
+			gotArgs,
+		)
+	})
+}
+
+func TestAgent_MetaData(t *testing.T) {
+	var gotArgs [][]string
+	agentWithMock := agent.NewAgent(agent.WithCommandFn(func(_ string, args ...string) *exec.Cmd {
+		gotArgs = append(gotArgs, args)
+		return exec.Command("echo", "abc123")
+	}))
+	require.NoError(t, agentWithMock.SetMetaData(t.Context(), "terraform-plan:network", "abc123"))
+	value, err := agentWithMock.GetMetaData(t.Context(), "terraform-plan:network")
+	require.NoError(t, err)
+	assert.Equal(t, "abc123", value)
+	assert.Equal(t, [][]string{
+		{"meta-data", "set", "terraform-plan:network", "abc123"},
+		{"meta-data", "get", "terraform-plan:network"},
+	}, gotArgs)
+}
+
+func TestAgent_MetaDataKeys(t *testing.T) {
+	var gotArgs []string
+	agentWithMock := agent.NewAgent(agent.WithCommandFn(func(_ string, args ...string) *exec.Cmd {
+		gotArgs = args
+		return exec.Command("printf", "terraform-report:plan:0\nterraform-report:plan:1\n")
+	}))
+	keys, err := agentWithMock.MetaDataKeys(t.Context())
+	require.NoError(t, err)
+	assert.Equal(t, []string{"terraform-report:plan:0", "terraform-report:plan:1"}, keys)
+	assert.Equal(t, []string{"meta-data", "keys"}, gotArgs)
+}
+
 func TestAgent_Annotate(t *testing.T) {
 	t.Run("calls runCommand", func(t *testing.T) {
 		called := false
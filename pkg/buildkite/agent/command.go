@@ -6,16 +6,27 @@ import (
 	"fmt"
 	"os/exec"
 
+	"github.com/rs/zerolog"
 	"github.com/rs/zerolog/log"
 )
 
 // CommandFn is a function type for creating exec.Cmd, allowing DI for testing.
 type CommandFn func(command string, args ...string) *exec.Cmd
 
+// logger returns the logger attached to ctx, falling back to the global logger
+// for contexts without one so that callers that never attach a logger still
+// see agent command logs.
+func logger(ctx context.Context) *zerolog.Logger {
+	if l := zerolog.Ctx(ctx); l.GetLevel() != zerolog.Disabled {
+		return l
+	}
+	return &log.Logger
+}
+
 // runCommand executes a command with the provided arguments and returns its output.
-func (c *config) runCommand(_ context.Context, command string, args ...string) (*string, error) {
+func (c *config) runCommand(ctx context.Context, command string, args ...string) (*string, error) {
 	cmd := c.command(command, args...)
-	log.Debug().Str("command", command).Strs("args", args).Msg("Executing command")
+	logger(ctx).Debug().Str("command", command).Strs("args", args).Msg("Executing command")
 
 	var out bytes.Buffer
 	var stderr bytes.Buffer
@@ -24,7 +35,7 @@ func (c *config) runCommand(_ context.Context, command string, args ...string) (
 
 	err := cmd.Run()
 	if err != nil {
-		log.Error().
+		logger(ctx).Error().
 			Str("command", command).
 			Strs("args", args).
 			Str("stderr", stderr.String()).
@@ -33,6 +44,6 @@ func (c *config) runCommand(_ context.Context, command string, args ...string) (
 		return nil, fmt.Errorf("command `%s` failed: %w: %s", command, err, stderr.String())
 	}
 	output := out.String()
-	log.Debug().Str("command", command).Strs("args", args).Str("stdout", output).Msg("Command executed successfully")
+	logger(ctx).Debug().Str("command", command).Strs("args", args).Str("stdout", output).Msg("Command executed successfully")
 	return &output, nil
 }
@@ -1,9 +1,12 @@
 package agent
 
 import (
+	"bytes"
 	"os/exec"
 	"testing"
 
+	"github.com/rs/zerolog"
+	"github.com/rs/zerolog/log"
 	"github.com/stretchr/testify/assert"
 	"github.com/stretchr/testify/require"
 )
@@ -35,3 +38,16 @@ func TestRunCommand(t *testing.T) {
 		assert.Nil(t, output)
 	})
 }
+
+func TestLogger(t *testing.T) {
+	t.Run("context logger", func(t *testing.T) {
+		var buf bytes.Buffer
+		ctx := zerolog.New(&buf).WithContext(t.Context())
+		logger(ctx).Info().Msg("from context")
+		assert.Contains(t, buf.String(), "from context")
+	})
+
+	t.Run("falls back to the global logger", func(t *testing.T) {
+		assert.Same(t, &log.Logger, logger(t.Context()))
+	})
+}
@@ -48,3 +48,24 @@ func WithAppend(a bool) AnnotateOptions {
 		r.append = a
 	}
 }
+
+type artifactConfig struct {
+	step  string
+	build string
+}
+
+type ArtifactOptions func(*artifactConfig)
+
+// WithStep scopes an artifact download to the step with the given key or ID.
+func WithStep(s string) ArtifactOptions {
+	return func(r *artifactConfig) {
+		r.step = s
+	}
+}
+
+// WithBuild scopes an artifact download to the build with the given ID.
+func WithBuild(b string) ArtifactOptions {
+	return func(r *artifactConfig) {
+		r.build = b
+	}
+}